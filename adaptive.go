@@ -0,0 +1,29 @@
+package main
+
+import "math"
+
+const (
+	// flatVariance is the luminance variance below which a block is considered flat.
+	// The flat blocks are similar to every other flat block, so they are not matched
+	// at all when the adaptive threshold is enabled.
+	flatVariance = 1.0
+	// referenceVariance is the luminance variance up to which the full distance threshold applies.
+	referenceVariance = 100.0
+)
+
+// matchThreshold returns the maximum difference between the features of the two blocks for being matched.
+// With the adaptive threshold the distance threshold decreases with the square root of the lower
+// block variance above the reference variance, and it is zero for the flat blocks.
+func (c Config) matchThreshold(a, b feature) float64 {
+	if !c.AdaptiveThreshold {
+		return c.DistanceThreshold
+	}
+	variance := math.Min(a.variance, b.variance)
+	switch {
+	case variance < flatVariance:
+		return 0
+	case variance <= referenceVariance:
+		return c.DistanceThreshold
+	}
+	return c.DistanceThreshold * math.Sqrt(referenceVariance/variance)
+}
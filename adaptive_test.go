@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestMatchThreshold(t *testing.T) {
+	cfg := DefaultConfig
+	flat, smooth, detailed := feature{variance: 0.5}, feature{variance: 50}, feature{variance: 400}
+	if got := cfg.matchThreshold(flat, detailed); got != cfg.DistanceThreshold {
+		t.Errorf("the fixed threshold is %v, expected %v", got, cfg.DistanceThreshold)
+	}
+
+	cfg.AdaptiveThreshold = true
+	for _, tc := range []struct {
+		name string
+		a, b feature
+		want float64
+	}{
+		{"flat", flat, detailed, 0},
+		{"smooth", smooth, detailed, cfg.DistanceThreshold},
+		// The threshold decreases with the square root of the variance: 400 is 4 times the reference variance.
+		{"detailed", detailed, detailed, cfg.DistanceThreshold / 2},
+	} {
+		if got := cfg.matchThreshold(tc.a, tc.b); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("%s: the adaptive threshold is %v, expected %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAdaptiveThresholdFalsePositives(t *testing.T) {
+	// The authentic image has a nearly flat half, a faint horizontal gradient whose columns repeat vertically,
+	// and a textured half, taken from a part of the synthetic image without the copy.
+	textured, _, _, err := SyntheticImage(256, 256, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 224, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 96; x++ {
+			v := uint8(100 + x/4)
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	draw.Draw(img, image.Rect(96, 0, 224, 128), textured, image.Pt(128, 0), draw.Src)
+
+	falsePositives := func(adaptive bool) int {
+		cfg := DefaultConfig
+		cfg.AdaptiveThreshold = adaptive
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(res.Regions)
+	}
+	fixed, adaptive := falsePositives(false), falsePositives(true)
+	if fixed == 0 || adaptive >= fixed {
+		t.Errorf("the adaptive threshold reports %d false positive regions, the fixed threshold %d", adaptive, fixed)
+	}
+
+	// The textured copies are still detected.
+	forged, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.AdaptiveThreshold = true
+	if res, err := Detect(forged, cfg); err != nil || !res.Forged {
+		t.Errorf("the copy is not detected with the adaptive threshold: %v", err)
+	}
+}
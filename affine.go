@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+const (
+	// ransacIterations is the number of the random samples the affine transform is estimated from.
+	ransacIterations = 256
+	// ransacTolerance is the maximum distance in pixels between the transformed source block
+	// and the destination block of an inlier correspondence, in the analyzed image space.
+	ransacTolerance = 2
+	// minAffineInliers is the number of inliers at which the inlier count factor of the copy confidence reaches 0.5.
+	minAffineInliers = 3
+)
+
+// affine is a two dimensional affine transform: x' = a*x + b*y + c, y' = d*x + e*y + f.
+type affine [6]float64
+
+// apply transforms the point.
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[1]*y + m[2], m[3]*x + m[4]*y + m[5]
+}
+
+// affineFit is the affine transform fitted to the forged block pairs, with the consistency of the pairs.
+type affineFit struct {
+	transform affine
+	// inliers is the number of pairs consistent with the transform.
+	inliers int
+	// residual is the root mean square distance of the inliers to the transform.
+	residual float64
+}
+
+// fitAffine estimates the affine transform mapping the source blocks onto the destination blocks
+// of the pairs with RANSAC: the transforms of random minimal samples of three pairs are scored by
+// their inliers, and the best one is refined by least squares on its inliers. The random
+// generator is seeded, so the fit is deterministic. No transform is fitted for fewer than three pairs,
+// or when every sample is degenerate, like the blocks lying on a line.
+func fitAffine(vect []vector) (affineFit, bool) {
+	if len(vect) < 3 {
+		return affineFit{}, false
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	var best []int
+	for it := 0; it < ransacIterations; it++ {
+		i, j, k := rnd.Intn(len(vect)), rnd.Intn(len(vect)), rnd.Intn(len(vect))
+		if i == j || j == k || i == k {
+			continue
+		}
+		m, ok := solveAffine(vect, []int{i, j, k})
+		if !ok {
+			continue
+		}
+		if inl := affineInliers(vect, m); len(inl) > len(best) {
+			best = inl
+		}
+	}
+	if len(best) < 3 {
+		return affineFit{}, false
+	}
+
+	// The transform is refined on the inliers, which may in turn gain a few inliers.
+	m, ok := solveAffine(vect, best)
+	if !ok {
+		return affineFit{}, false
+	}
+	if inl := affineInliers(vect, m); len(inl) >= len(best) {
+		if refined, ok := solveAffine(vect, inl); ok {
+			m, best = refined, inl
+		}
+	}
+
+	var sum float64
+	for _, i := range best {
+		v := vect[i]
+		x, y := m.apply(float64(v.xa), float64(v.ya))
+		sum += (x-float64(v.xb))*(x-float64(v.xb)) + (y-float64(v.yb))*(y-float64(v.yb))
+	}
+	return affineFit{transform: m, inliers: len(best), residual: math.Sqrt(sum / float64(len(best)))}, true
+}
+
+// affineInliers returns the indices of the pairs whose destination block lies within the tolerance
+// of the transformed source block.
+func affineInliers(vect []vector, m affine) []int {
+	var inliers []int
+	for i, v := range vect {
+		x, y := m.apply(float64(v.xa), float64(v.ya))
+		if math.Hypot(x-float64(v.xb), y-float64(v.yb)) <= ransacTolerance {
+			inliers = append(inliers, i)
+		}
+	}
+	return inliers
+}
+
+// solveAffine fits the affine transform to the selected pairs by least squares. The horizontal and
+// the vertical destination coordinates are fitted separately, solving the 3x3 normal equations.
+func solveAffine(vect []vector, idx []int) (affine, bool) {
+	var ata [3][3]float64
+	var atx, aty [3]float64
+	for _, i := range idx {
+		v := vect[i]
+		row := [3]float64{float64(v.xa), float64(v.ya), 1}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				ata[r][c] += row[r] * row[c]
+			}
+			atx[r] += row[r] * float64(v.xb)
+			aty[r] += row[r] * float64(v.yb)
+		}
+	}
+	px, ok := solve3(ata, atx)
+	if !ok {
+		return affine{}, false
+	}
+	py, _ := solve3(ata, aty)
+	return affine{px[0], px[1], px[2], py[0], py[1], py[2]}, true
+}
+
+// solve3 solves the 3x3 linear system with the Cramer's rule. It fails for the singular systems,
+// whose determinant is negligible relative to the magnitude of the matrix.
+func solve3(m [3][3]float64, b [3]float64) ([3]float64, bool) {
+	det := func(m [3][3]float64) float64 {
+		return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	}
+	var norm float64
+	for _, row := range m {
+		for _, v := range row {
+			norm = math.Max(norm, math.Abs(v))
+		}
+	}
+	d := det(m)
+	if norm == 0 || math.Abs(d) < 1e-12*norm*norm*norm {
+		return [3]float64{}, false
+	}
+	var x [3]float64
+	for c := 0; c < 3; c++ {
+		mc := m
+		for r := 0; r < 3; r++ {
+			mc[r][c] = b[r]
+		}
+		x[c] = det(mc) / d
+	}
+	return x, true
+}
+
+// copyConfidence combines the fraction of the forged pairs consistent with a single affine transform
+// and the number of these inliers into a confidence between 0 and 1. A tightly consistent copy supported
+// by many pairs scores close to 1, while the scattered matches, which only a few of the pairs agree with, score low.
+func copyConfidence(fit affineFit, pairs int) float64 {
+	if pairs == 0 || fit.inliers == 0 {
+		return 0
+	}
+	ratio := float64(fit.inliers) / float64(pairs)
+	return ratio * float64(fit.inliers) / float64(fit.inliers+minAffineInliers)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFitAffine(t *testing.T) {
+	// A clean copy of a 10x10 grid of blocks, translated by 90,60.
+	var clean []vector
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			clean = append(clean, vector{xa: 10 + x, ya: 10 + y, xb: 100 + x, yb: 70 + y})
+		}
+	}
+	fit, ok := fitAffine(clean)
+	if !ok {
+		t.Fatal("expected an affine transform fitted to the clean copy")
+	}
+	if fit.inliers != len(clean) || fit.residual > 1e-6 {
+		t.Errorf("expected all %d pairs to be exact inliers, got %d with the residual %v", len(clean), fit.inliers, fit.residual)
+	}
+	if x, y := fit.transform.apply(0, 0); x < 90-1e-6 || x > 90+1e-6 || y < 60-1e-6 || y > 60+1e-6 {
+		t.Errorf("expected the translation 90,60, got %v,%v", x, y)
+	}
+	if c := copyConfidence(fit, len(clean)); c < 0.9 {
+		t.Errorf("expected the clean copy to score close to 1, got %v", c)
+	}
+
+	r := rand.New(rand.NewSource(5))
+	var scattered []vector
+	for i := 0; i < 100; i++ {
+		scattered = append(scattered, vector{xa: r.Intn(300), ya: r.Intn(300), xb: r.Intn(300), yb: r.Intn(300)})
+	}
+	fit, _ = fitAffine(scattered)
+	if c := copyConfidence(fit, len(scattered)); c > 0.2 {
+		t.Errorf("expected the scattered matches to score low, got %v with %d inliers", c, fit.inliers)
+	}
+
+	line := []vector{{xa: 1, ya: 1, xb: 5, yb: 5}, {xa: 2, ya: 2, xb: 6, yb: 6}, {xa: 3, ya: 3, xb: 7, yb: 7}}
+	if _, ok := fitAffine(line); ok {
+		t.Error("expected no transform fitted to the collinear blocks")
+	}
+	if _, ok := fitAffine(clean[:2]); ok {
+		t.Error("expected no transform fitted to fewer than three pairs")
+	}
+}
+
+func TestDetectCopyConfidence(t *testing.T) {
+	img, _, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CopyConfidence < 0.9 || res.AffineInliers == 0 || res.AffineResidual > 1 {
+		t.Errorf("expected the synthetic copy to be a consistent affine copy, got the confidence %v of %d inliers with the residual %v",
+			res.CopyConfidence, res.AffineInliers, res.AffineResidual)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// flatten composites the image over an opaque background color. The 16-bit images keep their precision.
+func flatten(img image.Image, bg color.NRGBA) image.Image {
+	b := img.Bounds()
+	var dst draw.Image
+	if is16Bit(img) {
+		dst = image.NewRGBA64(b)
+	} else {
+		dst = image.NewNRGBA(b)
+	}
+	bg.A = 255
+	draw.Draw(dst, b, &image.Uniform{bg}, image.ZP, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// meanAlpha returns the mean opacity, between 0 and 1, of the block having its top left corner at bx, by.
+func meanAlpha(img *image.NRGBA, bx, by int, blockSize int) float64 {
+	var sum int
+	for y := 0; y < blockSize; y++ {
+		i := img.PixOffset(bx, by+y)
+		for x := 0; x < blockSize; x++ {
+			sum += int(img.Pix[i+3])
+			i += 4
+		}
+	}
+	return float64(sum) / float64(255*blockSize*blockSize)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// halfTransparentImage returns a noise image whose transparent right half is a copy of its opaque left half.
+func halfTransparentImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rnd := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i+3] = 255
+			if x >= w/2 {
+				copy(img.Pix[i:i+3], img.Pix[img.PixOffset(x-w/2, y):])
+				img.Pix[i+3] = 0
+			}
+		}
+	}
+	return img
+}
+
+func TestMeanAlpha(t *testing.T) {
+	img := halfTransparentImage(16, 8)
+	if a := meanAlpha(img, 0, 0, 4); a != 1 {
+		t.Errorf("the mean alpha of an opaque block is %v, expected 1", a)
+	}
+	if a := meanAlpha(img, 12, 0, 4); a != 0 {
+		t.Errorf("the mean alpha of a transparent block is %v, expected 0", a)
+	}
+	if a := meanAlpha(img, 6, 0, 4); a != 0.5 {
+		t.Errorf("the mean alpha of a half transparent block is %v, expected 0.5", a)
+	}
+}
+
+func TestDetectSkipsTransparentBlocks(t *testing.T) {
+	synth, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The source and the copied regions become mostly transparent. Their colors are premultiplied
+	// by the same opacity, so they still match each other.
+	img := image.NewNRGBA(synth.Bounds())
+	draw.Draw(img, img.Bounds(), synth, image.Point{}, draw.Src)
+	for _, r := range []image.Rectangle{src, dst} {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Pix[img.PixOffset(x, y)+3] = 100
+			}
+		}
+	}
+	shift := dst.Min.Sub(src.Min)
+	hasShift := func(res *Result) bool {
+		for _, s := range res.Shifts {
+			if s.X == float64(shift.X) && s.Y == float64(shift.Y) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cfg := DefaultConfig
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasShift(res) {
+		t.Fatalf("the transparent copy is not matched when every block is analyzed: %+v", res.Shifts)
+	}
+
+	cfg.MinAlpha = 0.5
+	res, err = Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasShift(res) {
+		t.Errorf("the transparent blocks are matched with a minimum opacity of %v: %+v", cfg.MinAlpha, res.Shifts)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	img := halfTransparentImage(16, 8)
+	bg := color.NRGBA{10, 20, 30, 0}
+	flat := flatten(img, bg).(*image.NRGBA)
+	if c := flat.NRGBAAt(12, 0); c != (color.NRGBA{10, 20, 30, 255}) {
+		t.Errorf("the transparent pixel is %v after flattening, expected the opaque background", c)
+	}
+	if c, want := flat.NRGBAAt(2, 0), img.NRGBAAt(2, 0); c != want {
+		t.Errorf("the opaque pixel is %v after flattening, expected %v", c, want)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+const (
+	// arrowHeadAngle is the angle between the arrow shaft and the sides of the arrow head.
+	arrowHeadAngle = math.Pi / 7
+	// minArrowHead is the minimum length in pixels of the arrow head sides.
+	minArrowHead = 6
+)
+
+// Arrow connects a forged region to the region it has been copied to, in the original image space.
+type Arrow struct {
+	From image.Point `json:"from"`
+	To   image.Point `json:"to"`
+}
+
+// regionPair is a forged block pair in the original image space, with its shift vector.
+type regionPair struct {
+	src, dst image.Rectangle
+	shift    offset
+}
+
+// copyArrows aggregates the forged block pairs into one representative arrow per connected region of
+// the forgery mask and shift vector, running from the centroid of the source blocks to the centroid
+// of the destination blocks. A region copied to several places gets an arrow for each copy.
+func copyArrows(mask *image.Gray, pairs []regionPair) []Arrow {
+	type group struct {
+		label int
+		shift offset
+	}
+	type centroid struct {
+		sx, sy, dx, dy, n int
+	}
+
+	labels, _ := connectedComponents(mask)
+	b := mask.Bounds()
+	var order []group
+	groups := make(map[group]*centroid)
+	for _, p := range pairs {
+		c := center(p.src)
+		if !c.In(b) {
+			continue
+		}
+		label := labels[(c.Y-b.Min.Y)*b.Dx()+c.X-b.Min.X]
+		if label < 0 {
+			continue
+		}
+		k := group{label, p.shift}
+		g, ok := groups[k]
+		if !ok {
+			g = &centroid{}
+			groups[k] = g
+			order = append(order, k)
+		}
+		d := center(p.dst)
+		g.sx, g.sy, g.dx, g.dy, g.n = g.sx+c.X, g.sy+c.Y, g.dx+d.X, g.dy+d.Y, g.n+1
+	}
+
+	arrows := make([]Arrow, 0, len(order))
+	for _, k := range order {
+		g := groups[k]
+		arrows = append(arrows, Arrow{
+			From: image.Pt(int(round(float64(g.sx)/float64(g.n))), int(round(float64(g.sy)/float64(g.n)))),
+			To:   image.Pt(int(round(float64(g.dx)/float64(g.n))), int(round(float64(g.dy)/float64(g.n)))),
+		})
+	}
+	sort.SliceStable(arrows, func(i, j int) bool {
+		a, b := arrows[i], arrows[j]
+		switch {
+		case a.From.Y != b.From.Y:
+			return a.From.Y < b.From.Y
+		case a.From.X != b.From.X:
+			return a.From.X < b.From.X
+		case a.To.Y != b.To.Y:
+			return a.To.Y < b.To.Y
+		}
+		return a.To.X < b.To.X
+	})
+	return arrows
+}
+
+// center returns the center point of the rectangle.
+func center(r image.Rectangle) image.Point {
+	return image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+}
+
+// annotateArrows draws an arrow over the original image from each forged region to its copy,
+// in the highlight color and with the configured line thickness (at least one pixel).
+func annotateArrows(src image.Image, res *Result, cfg Config) *image.RGBA {
+	img := imgToNRGBA(src)
+	output := image.NewRGBA(img.Bounds())
+	draw.Draw(output, output.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	thickness := cfg.LineThickness
+	if thickness < 1 {
+		thickness = 1
+	}
+	c := cfg.highlightColor()
+	for _, a := range res.Arrows {
+		drawArrow(output, a.From, a.To, c, thickness)
+	}
+	return output
+}
+
+// drawArrow draws a line from the start to the end point, ended by an arrow head.
+func drawArrow(dst draw.Image, from, to image.Point, c color.Color, thickness int) {
+	drawLine(dst, from, to, c, thickness)
+
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	head := math.Max(minArrowHead, length/5)
+	angle := math.Atan2(dy, dx)
+	for _, side := range []float64{angle + math.Pi - arrowHeadAngle, angle + math.Pi + arrowHeadAngle} {
+		end := image.Pt(to.X+int(round(head*math.Cos(side))), to.Y+int(round(head*math.Sin(side))))
+		drawLine(dst, to, end, c, thickness)
+	}
+}
+
+// drawLine draws a line between two points using the Bresenham algorithm,
+// with a square brush of the provided thickness.
+func drawLine(dst draw.Image, from, to image.Point, c color.Color, thickness int) {
+	u := &image.Uniform{c}
+	brush := func(x, y int) {
+		r := image.Rect(x-thickness/2, y-thickness/2, x-thickness/2+thickness, y-thickness/2+thickness)
+		draw.Draw(dst, r.Intersect(dst.Bounds()), u, image.ZP, draw.Src)
+	}
+
+	dx, dy := to.X-from.X, to.Y-from.Y
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	x, y, e := from.X, from.Y, dx-dy
+	for {
+		brush(x, y)
+		if x == to.X && y == to.Y {
+			return
+		}
+		e2 := 2 * e
+		if e2 > -dy {
+			e -= dy
+			x += sx
+		}
+		if e2 < dx {
+			e += dx
+			y += sy
+		}
+	}
+}
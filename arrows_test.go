@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestCopyArrows(t *testing.T) {
+	var pairs []regionPair
+	var rects []image.Rectangle
+	// A square region copied by 50,30, and a separate region copied to two places.
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			s := image.Rect(x, y, x+8, y+8)
+			pairs = append(pairs, regionPair{src: s, dst: s.Add(image.Pt(50, 30)), shift: offset{50, 30, NoFlip}})
+			rects = append(rects, s)
+		}
+	}
+	for x := 10; x < 14; x++ {
+		s := image.Rect(x, 70, x+8, 78)
+		pairs = append(pairs,
+			regionPair{src: s, dst: s.Add(image.Pt(40, 0)), shift: offset{40, 0, NoFlip}},
+			regionPair{src: s, dst: s.Add(image.Pt(40, 10)), shift: offset{40, 10, NoFlip}},
+		)
+		rects = append(rects, s)
+	}
+	mask := regionMask(image.Rect(0, 0, 100, 100), rects)
+
+	// The arrows run between the centroids of the block centers.
+	want := []Arrow{
+		{From: image.Pt(19, 19), To: image.Pt(69, 49)},
+		{From: image.Pt(16, 74), To: image.Pt(56, 74)},
+		{From: image.Pt(16, 74), To: image.Pt(56, 84)},
+	}
+	if got := copyArrows(mask, pairs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got the arrows %+v, expected %+v", got, want)
+	}
+}
+
+func TestDetectArrows(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, a := range res.Arrows {
+		if a.From.In(src) && a.To.In(dst) && a.To.Sub(a.From) == dst.Min.Sub(src.Min) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("no arrow connects the copied region %v to its copy %v: %+v", src, dst, res.Arrows)
+	}
+
+	cfg := DefaultConfig
+	cfg.HighlightColor = color.RGBA{0, 255, 0, 255}
+	out := annotateArrows(img, &Result{Arrows: []Arrow{{From: image.Pt(20, 20), To: image.Pt(60, 20)}}}, cfg)
+	for _, p := range []image.Point{{20, 20}, {40, 20}, {60, 20}} {
+		if c := out.RGBAAt(p.X, p.Y); c != cfg.HighlightColor {
+			t.Errorf("the arrow pixel %v is %v, expected the highlight color", p, c)
+		}
+	}
+	if c, want := out.RGBAAt(40, 40), color.RGBAModel.Convert(img.At(40, 40)); c != want {
+		t.Errorf("the pixel off the arrow is %v, expected it unchanged", c)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// BatchRecord contains the outcome of the forgery detection on a single image of a batch.
+type BatchRecord struct {
+	// File is the path of the analyzed image.
+	File string `json:"file"`
+	*Result
+	// Error is the reason the image could not be analyzed.
+	Error string `json:"error,omitempty"`
+}
+
+// detectBatch runs the detection on every image of the directory and writes the outcome of each image
+// as a separate line of JSON as soon as it completes, so the results of a huge batch are never buffered.
+// The files which are not images are skipped. Each image is analyzed within the timeout, if provided.
+// It returns the number of analyzed images.
+func detectBatch(dir string, cfg Config, timeout time.Duration, w io.Writer) (int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	var count int
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		img, err := loadImage(path)
+		if errors.Is(err, ErrUnsupportedFormat) {
+			continue
+		}
+
+		record := BatchRecord{File: path}
+		if err == nil {
+			record.Result, err = detectWithTimeout(img, cfg, timeout)
+		}
+		if err == nil {
+			record.JPEGQuality = fileJPEGQuality(path)
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if err := enc.Encode(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// detectWithTimeout runs the detection, which is aborted when it takes longer than the timeout (if not zero).
+func detectWithTimeout(img image.Image, cfg Config, timeout time.Duration) (*Result, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return DetectContext(ctx, img, cfg)
+}
+
+// BatchPlan contains the work the detection would do on a single image of a batch.
+type BatchPlan struct {
+	// File is the path of the image.
+	File string `json:"file"`
+	*Plan
+	// Error is the reason the image could not be analyzed.
+	Error string `json:"error,omitempty"`
+}
+
+// planBatch decodes every image of the directory and writes the work its detection would do as a separate
+// line of JSON, without running the detections. The files which are not images are skipped.
+// It returns the plans of the images which could be analyzed.
+func planBatch(dir string, cfg Config, w io.Writer) ([]*Plan, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(w)
+	var plans []*Plan
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		img, err := loadImage(path)
+		if errors.Is(err, ErrUnsupportedFormat) {
+			continue
+		}
+
+		record := BatchPlan{File: path}
+		if err == nil {
+			record.Plan, err = PlanDetection(img, cfg)
+		}
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			plans = append(plans, record.Plan)
+		}
+		if err := enc.Encode(record); err != nil {
+			return plans, err
+		}
+	}
+	return plans, nil
+}
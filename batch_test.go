@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectBatchJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	const images = 3
+	for i := 0; i < images; i++ {
+		img, _, _, err := SyntheticImage(64, 64, int64(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := saveImage(filepath.Join(dir, fmt.Sprintf("image%d.png", i)), img, "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The files which are not images are skipped.
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := detectBatch(dir, DefaultConfig, 0, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != images {
+		t.Errorf("%d images have been analyzed, expected %d", n, images)
+	}
+
+	var lines int
+	scanner := bufio.NewScanner(&buf)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record BatchRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("the line %d is not valid JSON: %v", lines+1, err)
+		}
+		if want := filepath.Join(dir, fmt.Sprintf("image%d.png", lines)); record.File != want {
+			t.Errorf("the line %d is for the file %q, expected %q", lines+1, record.File, want)
+		}
+		if record.Error != "" || record.Result == nil {
+			t.Errorf("the line %d has no result: %q", lines+1, record.Error)
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if lines != images {
+		t.Errorf("got %d lines, expected one per image (%d)", lines, images)
+	}
+}
+
+// panicFormat is an image format whose decoder panics, like the standard decoders on some malformed images.
+const panicFormat = "PANIC!"
+
+func init() {
+	decode := func(io.Reader) (image.Image, error) { panic("index out of range") }
+	decodeConfig := func(io.Reader) (image.Config, error) { panic("index out of range") }
+	image.RegisterFormat("panic", panicFormat, decode, decodeConfig)
+}
+
+func TestDetectBatchCorruptImages(t *testing.T) {
+	dir := t.TempDir()
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"a-panic.png":     []byte(panicFormat + "corrupt data"),
+		"b-truncated.png": encoded.Bytes()[:encoded.Len()/2],
+		"c-valid.png":     encoded.Bytes(),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := detectBatch(dir, DefaultConfig, 0, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(files) {
+		t.Fatalf("%d images have been reported, expected %d", n, len(files))
+	}
+	dec := json.NewDecoder(&buf)
+	var records []BatchRecord
+	for dec.More() {
+		var record BatchRecord
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected a record per image, got %+v", records)
+	}
+	if r := records[0]; r.Result != nil || !strings.Contains(r.Error, ErrCorruptImage.Error()) {
+		t.Errorf("expected the panicking decoder to be reported as a corrupt image, got %+v", r)
+	}
+	if r := records[1]; r.Result != nil || r.Error == "" {
+		t.Errorf("expected the truncated image to be reported as failed, got %+v", r)
+	}
+	if r := records[2]; r.Result == nil || r.Error != "" {
+		t.Errorf("expected the valid image after the corrupt ones to be analyzed, got %+v", r)
+	}
+
+	if _, err := decodeImage(bytes.NewReader(files["a-panic.png"])); !errors.Is(err, ErrCorruptImage) {
+		t.Errorf("expected ErrCorruptImage, got %v", err)
+	}
+}
@@ -62,4 +62,4 @@ if [ "$NOCOPY" != "1" ]; then
 fi
 
 # build and store objects into original directory.
-go build -ldflags "-X main.Version=$VERSION" -o "$OD/forensic" *.go
\ No newline at end of file
+go build -ldflags "-X main.Version=$VERSION" -o "$OD/forensic" .
\ No newline at end of file
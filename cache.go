@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// featureCacheVersion identifies the layout of the cached features and the feature extraction they come from.
+// It is part of the cache keys, so the files of an incompatible version are never read.
+const featureCacheVersion = "forensic features 1"
+
+// featureRecordSize is the encoded size of a cached feature: its position, coefficient, variance and mirroring.
+const featureRecordSize = 4 + 4 + 8 + 8 + 1
+
+// FeatureCache stores the extracted block features on disk, so the repeated detections of the same image,
+// like the runs tuning the matching thresholds, skip the feature extraction. The features are keyed by
+// the hash of the preprocessed image pixels and of the settings they depend on, so changing the image,
+// the preprocessing or the block settings misses the cache, while the matching settings can be changed freely.
+// It is safe for concurrent use.
+type FeatureCache struct {
+	dir          string
+	hits, misses int64
+}
+
+// NewFeatureCache returns a feature cache storing its files in the directory, which is created if needed.
+func NewFeatureCache(dir string) (*FeatureCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FeatureCache{dir: dir}, nil
+}
+
+// Hits returns the number of the tiles whose features have been loaded from the cache.
+func (c *FeatureCache) Hits() int {
+	return int(atomic.LoadInt64(&c.hits))
+}
+
+// Misses returns the number of the tiles whose features have been extracted and stored in the cache.
+func (c *FeatureCache) Misses() int {
+	return int(atomic.LoadInt64(&c.misses))
+}
+
+// imageKey hashes the sources the features are extracted from, and the settings of the extraction.
+// The preprocessed pixels already reflect the image, its region of interest, the downscaling and the preprocessing.
+func (c *FeatureCache) imageKey(src blockSources, cfg Config) []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%v\n", featureCacheVersion, src.yuv.Bounds())
+	h.Write(src.yuv.Pix)
+	if src.yuv16 != nil {
+		h.Write(src.yuv16.Pix)
+	}
+	if cfg.MinAlpha > 0 {
+		fmt.Fprintf(h, "alpha %v\n", cfg.MinAlpha)
+		h.Write(src.alpha.Pix)
+	}
+	if src.excluded != nil {
+		fmt.Fprintf(h, "excluded %d\n", src.excluded.width)
+		var buf [8]byte
+		for _, s := range src.excluded.sum {
+			binary.LittleEndian.PutUint64(buf[:], uint64(s))
+			h.Write(buf[:])
+		}
+	}
+	fmt.Fprintf(h, "bs %d step %d features %d lowfreq %d zernike %d adaptive %v flips %v texture %v weights %v max %d seed %d\n",
+		cfg.BlockSize, cfg.blockStep(), cfg.featureSet(), cfg.lowFreqCount(), cfg.zernikeOrder(), cfg.AdaptiveThreshold, cfg.DetectFlips, cfg.MinTextureEnergy, cfg.dctWeights(), cfg.MaxFeatures, cfg.SampleSeed)
+	return h.Sum(nil)
+}
+
+// path returns the path of the cache file holding the features of the tile of the hashed image.
+func (c *FeatureCache) path(key []byte, tile image.Rectangle) string {
+	h := sha256.New()
+	h.Write(key)
+	fmt.Fprintf(h, "tile %v", tile)
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".feat")
+}
+
+// load appends the cached features of the file to feats. It reports false when the file is missing or damaged.
+func (c *FeatureCache) load(path string, feats []feature) ([]feature, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return feats, false
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return feats, false
+	}
+	if st, err := f.Stat(); err != nil || uint64(st.Size()) != 8+count*featureRecordSize {
+		debugLog.Printf("Damaged feature cache file: %s", path)
+		return feats, false
+	}
+	first := len(feats)
+	var rec [featureRecordSize]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			return feats[:first], false
+		}
+		feats = append(feats, feature{
+			x:        int(int32(binary.LittleEndian.Uint32(rec[0:]))),
+			y:        int(int32(binary.LittleEndian.Uint32(rec[4:]))),
+			coef:     math.Float64frombits(binary.LittleEndian.Uint64(rec[8:])),
+			variance: math.Float64frombits(binary.LittleEndian.Uint64(rec[16:])),
+			flip:     Flip(rec[24]),
+		})
+	}
+	atomic.AddInt64(&c.hits, 1)
+	return feats, true
+}
+
+// store writes the features to the cache file. The file is written under a temporary name and renamed,
+// so the concurrent detections never read a partially written file.
+func (c *FeatureCache) store(path string, feats []feature) error {
+	atomic.AddInt64(&c.misses, 1)
+	tmp, err := ioutil.TempFile(c.dir, "tmp-*.feat")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = binary.Write(w, binary.LittleEndian, uint64(len(feats)))
+	var rec [featureRecordSize]byte
+	for _, f := range feats {
+		if err != nil {
+			break
+		}
+		binary.LittleEndian.PutUint32(rec[0:], uint32(int32(f.x)))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(int32(f.y)))
+		binary.LittleEndian.PutUint64(rec[8:], math.Float64bits(f.coef))
+		binary.LittleEndian.PutUint64(rec[16:], math.Float64bits(f.variance))
+		rec[24] = byte(f.flip)
+		_, err = w.Write(rec[:])
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("caching the features: %w", err)
+	}
+	return nil
+}
+
+// extractCachedFeatures is like extractFeatures, but it loads the features of the tile from the cache when they
+// are present, and stores the extracted features otherwise. The cache is not used when the key is nil.
+// A cache which cannot be written only costs the speedup, so the errors are reported as diagnostics.
+func extractCachedFeatures(ctx context.Context, feats []feature, src blockSources, tile image.Rectangle, cfg Config, key []byte, bar progressBar, stats *pipelineStats) ([]feature, int, error) {
+	if key == nil {
+		return extractFeatures(ctx, feats, src, tile, cfg, bar, stats)
+	}
+	path := cfg.FeatureCache.path(key, tile)
+	start := time.Now()
+	if cached, ok := cfg.FeatureCache.load(path, feats); ok {
+		stats.add(statsFeatures, start, len(cached)-len(feats))
+		return cached, cfg.tileBlocks(tile), nil
+	}
+	first := len(feats)
+	feats, n, err := extractFeatures(ctx, feats, src, tile, cfg, bar, stats)
+	if err == nil {
+		if err := cfg.FeatureCache.store(path, feats[first:]); err != nil {
+			debugLog.Printf("Error storing the features in the cache: %v", err)
+		}
+	}
+	return feats, n, err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewFeatureCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.Features = FeatureDCT | FeatureZernike
+	cfg.CollectStats = true
+	cfg.FeatureCache = cache
+
+	// extractionTime returns the wall time of the features stage, which loads the cached features on a hit.
+	extractionTime := func(res *Result) float64 {
+		for _, s := range res.Stats {
+			if s.Stage == statsFeatures {
+				return s.Seconds
+			}
+		}
+		t.Fatalf("no features stage in %+v", res.Stats)
+		return 0
+	}
+
+	first, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Hits() != 0 || cache.Misses() != 1 {
+		t.Fatalf("expected the first run to miss the cache, got %d hits and %d misses", cache.Hits(), cache.Misses())
+	}
+	second, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Hits() != 1 || cache.Misses() != 1 {
+		t.Fatalf("expected the second run to hit the cache, got %d hits and %d misses", cache.Hits(), cache.Misses())
+	}
+	if extracted, loaded := extractionTime(first), extractionTime(second); loaded >= extracted {
+		t.Errorf("loading the cached features took %.4fs, longer than extracting them (%.4fs)", loaded, extracted)
+	}
+	first.Stats, second.Stats = nil, nil
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("the cached features changed the result:\n%+v\n%+v", first, second)
+	}
+
+	// The matching settings reuse the features, while the block settings and the image don't.
+	tuned := cfg
+	tuned.DistanceThreshold = 0.2
+	if _, err := Detect(img, tuned); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Hits() != 2 {
+		t.Errorf("expected the changed distance threshold to hit the cache, got %d hits", cache.Hits())
+	}
+	resized := cfg
+	resized.BlockSize = 8
+	if _, err := Detect(img, resized); err != nil {
+		t.Fatal(err)
+	}
+	other, _, _, err := SyntheticImage(128, 128, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Detect(other, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Hits() != 2 || cache.Misses() != 3 {
+		t.Errorf("expected the changed block size and image to miss the cache, got %d hits and %d misses", cache.Hits(), cache.Misses())
+	}
+
+	// The damaged files are extracted again.
+	files, err := filepath.Glob(filepath.Join(dir, "cache", "*.feat"))
+	if err != nil || len(files) != 3 {
+		t.Fatalf("expected a cache file per analyzed image and settings, got %q (%v)", files, err)
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(f, []byte("damaged"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Stats = nil
+	if cache.Misses() != 4 || !reflect.DeepEqual(res, first) {
+		t.Errorf("expected the damaged cache file to be replaced by the extracted features, got %d misses", cache.Misses())
+	}
+}
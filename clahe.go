@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// defaultCLAHEClipLimit is the default clip limit of the adaptive equalization, as a multiple of the mean
+// height of the histogram bins.
+const defaultCLAHEClipLimit = 2
+
+// claheClipLimit returns the clip limit of the contrast-limited adaptive histogram equalization.
+func (c Config) claheClipLimit() float64 {
+	if c.CLAHEClipLimit == 0 {
+		return defaultCLAHEClipLimit
+	}
+	return c.CLAHEClipLimit
+}
+
+// claheLuminance equalizes the luminance of a YUV image locally with the contrast-limited adaptive histogram
+// equalization (CLAHE). The image is split into a grid of tiles×tiles tiles, each equalized by its own histogram,
+// so the dark and the bright areas are stretched separately. The histogram bins are clipped at clipLimit times
+// their mean height and the excess is spread over all the bins, which limits the amplification of the noise
+// in the flat areas. The pixels are mapped by the bilinear interpolation of the mappings of the four nearest
+// tiles, so the tile boundaries don't show. The luminance is stored in the red component, and the chroma
+// is left unchanged.
+func claheLuminance(img *image.RGBA, tiles int, clipLimit float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	nx, ny := tiles, tiles
+	if nx > w {
+		nx = w
+	}
+	if ny > h {
+		ny = h
+	}
+
+	luts := make([][256]uint8, nx*ny)
+	for ty := 0; ty < ny; ty++ {
+		for tx := 0; tx < nx; tx++ {
+			tile := image.Rect(b.Min.X+tx*w/nx, b.Min.Y+ty*h/ny, b.Min.X+(tx+1)*w/nx, b.Min.Y+(ty+1)*h/ny)
+			luts[ty*nx+tx] = clippedEqualization(img, tile, clipLimit)
+		}
+	}
+
+	// The position of the pixel in the grid of the tile centers, clamped to the outer centers.
+	grid := func(v, size, n int) (int, int, float64) {
+		g := (float64(v)+0.5)*float64(n)/float64(size) - 0.5
+		i0 := int(math.Floor(g))
+		switch {
+		case i0 < 0:
+			return 0, 0, 0
+		case i0 >= n-1:
+			return n - 1, n - 1, 0
+		}
+		return i0, i0 + 1, g - float64(i0)
+	}
+	for y := 0; y < h; y++ {
+		y0, y1, fy := grid(y, h, ny)
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x++ {
+			x0, x1, fx := grid(x, w, nx)
+			v := img.Pix[i]
+			top := (1-fx)*float64(luts[y0*nx+x0][v]) + fx*float64(luts[y0*nx+x1][v])
+			bottom := (1-fx)*float64(luts[y1*nx+x0][v]) + fx*float64(luts[y1*nx+x1][v])
+			img.Pix[i] = uint8(round((1-fy)*top + fy*bottom))
+			i += 4
+		}
+	}
+}
+
+// clippedEqualization returns the luminance mapping equalizing the tile of the image, with the histogram bins
+// clipped at clipLimit times their mean height and the clipped excess redistributed over all the bins.
+func clippedEqualization(img *image.RGBA, tile image.Rectangle, clipLimit float64) [256]uint8 {
+	var hist [256]int
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		i := img.PixOffset(tile.Min.X, y)
+		for x := tile.Min.X; x < tile.Max.X; x++ {
+			hist[img.Pix[i]]++
+			i += 4
+		}
+	}
+
+	total := tile.Dx() * tile.Dy()
+	limit := int(clipLimit * float64(total) / 256)
+	if limit < 1 {
+		limit = 1
+	}
+	var excess int
+	for v, n := range hist {
+		if n > limit {
+			excess += n - limit
+			hist[v] = limit
+		}
+	}
+	for v := range hist {
+		hist[v] += excess / 256
+		if v < excess%256 {
+			hist[v]++
+		}
+	}
+
+	var lut [256]uint8
+	var cdf int
+	for v, n := range hist {
+		cdf += n
+		lut[v] = uint8(round(float64(cdf) * 255 / float64(total)))
+	}
+	return lut
+}
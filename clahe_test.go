@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// luminanceStats returns the mean and the standard deviation of the luminance of the region of the YUV image.
+func luminanceStats(img *image.RGBA, r image.Rectangle) (float64, float64) {
+	var sum, sq float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v := float64(img.Pix[img.PixOffset(x, y)])
+			sum += v
+			sq += v * v
+		}
+	}
+	n := float64(r.Dx() * r.Dy())
+	mean := sum / n
+	return mean, math.Sqrt(sq/n - mean*mean)
+}
+
+func TestCLAHELuminance(t *testing.T) {
+	// A textured bright half and a textured dark half.
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			v := 60 + rnd.Intn(190)
+			if x >= 32 {
+				v = 10 + rnd.Intn(16)
+			}
+			copy(img.Pix[img.PixOffset(x, y):], []uint8{uint8(v), 90, 160, 255})
+		}
+	}
+	dark := image.Rect(32, 0, 64, 64)
+	_, before := luminanceStats(img, dark)
+
+	claheLuminance(img, 4, defaultCLAHEClipLimit)
+	_, after := luminanceStats(img, dark)
+	if after < 2*before {
+		t.Errorf("the contrast of the dark region increased from %.1f to %.1f only", before, after)
+	}
+	var saturated int
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] == 0 || img.Pix[i] == 255 {
+			saturated++
+		}
+		if img.Pix[i+1] != 90 || img.Pix[i+2] != 160 {
+			t.Fatalf("the chroma of the pixel %d changed to %d, %d", i/4, img.Pix[i+1], img.Pix[i+2])
+		}
+	}
+	if saturated > len(img.Pix)/4/20 {
+		t.Errorf("%d pixels of %d are saturated", saturated, len(img.Pix)/4)
+	}
+
+	// The noise of a flat image is amplified by the global equalization, but not beyond the clip limit.
+	flat := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range flat.Pix {
+		flat.Pix[i] = uint8(100 + rnd.Intn(4))
+	}
+	global := image.NewRGBA(flat.Bounds())
+	copy(global.Pix, flat.Pix)
+	equalizeLuminance(global)
+	claheLuminance(flat, 4, defaultCLAHEClipLimit)
+	if _, std := luminanceStats(global, global.Bounds()); std < 50 {
+		t.Fatalf("expected the global equalization to blow out the noise, got the deviation %.1f", std)
+	}
+	if _, std := luminanceStats(flat, flat.Bounds()); std > 10 {
+		t.Errorf("the adaptive equalization amplified the noise to the deviation %.1f", std)
+	}
+}
@@ -0,0 +1,1161 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const Banner = `
+  __                          _
+ / _| ___  _ __ ___ _ __  ___(_) ___
+| |_ / _ \| '__/ _ \ '_ \/ __| |/ __|
+|  _| (_) | | |  __/ | | \__ \ | (__
+|_|  \___/|_|  \___|_| |_|___/_|\___|
+
+Image forgery detection library.
+    Version: %s
+
+`
+
+// commandsUsage lists the subcommands of the tool.
+const commandsUsage = `Usage: forensic [command] [flags]
+
+Commands:
+  detect     Detect the copy-move forgeries of an image and print the verdict (default)
+  eval       Evaluate the detection of a forged image against its ground truth mask
+  visualize  Write the image annotated with the detected forgeries
+  batch      Analyze every image of a directory, printing one JSON result per line
+  compare    Compare the regions detected in an image with two configurations
+  selfcheck  Check the DCT implementations against the coefficients of known test patterns
+  version    Print the version, the Go version and the commit of the build
+
+Run forensic [command] -h for the flags of a command.
+
+Flags of the detect command:
+`
+
+// errUsage is returned for the invalid arguments, which the flag set has already reported with its usage.
+var errUsage = errors.New("invalid arguments")
+
+// runner runs a subcommand with its parsed arguments.
+type runner interface {
+	run()
+}
+
+// subcommand returns the function parsing the arguments of the named subcommand, or nil when it is unknown.
+func subcommand(name string) func(args []string) (runner, error) {
+	switch name {
+	case "detect":
+		return parseDetect
+	case "eval":
+		return parseEval
+	case "visualize":
+		return parseVisualize
+	case "batch":
+		return parseBatch
+	case "compare":
+		return parseCompare
+	case "selfcheck":
+		return parseSelfCheck
+	case "version":
+		return parseVersion
+	}
+	return nil
+}
+
+func main() {
+	// The bare invocation, without a subcommand, runs the detection, like before the subcommands existed.
+	parse, args := parseDetect, os.Args[1:]
+	if len(args) > 0 {
+		if p := subcommand(args[0]); p != nil {
+			parse, args = p, args[1:]
+		}
+	}
+	cmd, err := parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return
+	case err == errUsage:
+		os.Exit(2)
+	case err != nil:
+		log.Fatalf("ERROR: %v", err)
+	}
+	cmd.run()
+}
+
+// newFlagSet returns the flag set of a subcommand, printing the usage line before the flags.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: forensic %s %s\n\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the arguments of a subcommand, returning errUsage for the invalid flags.
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && err != flag.ErrHelp {
+		return errUsage
+	}
+	return err
+}
+
+// settingsFlags are the detection settings shared by the subcommands analyzing the images.
+// The numeric and boolean flags are bound directly to the settings, the others are parsed by config.
+type settingsFlags struct {
+	fs  *flag.FlagSet
+	cfg Config
+	// names are the names of the settings flags, the only ones the configuration file can set.
+	names map[string]bool
+
+	features    string
+	weights     string
+	metric      string
+	resampling  string
+	background  string
+	roi         string
+	exclude     string
+	cache       string
+	highlight   string
+	sensitivity float64
+	configPath  string
+	verbose     bool
+}
+
+// addSettingsFlags defines the flags of the detection settings on the flag set.
+func addSettingsFlags(fs *flag.FlagSet) *settingsFlags {
+	s := &settingsFlags{fs: fs, highlight: "#ff0000"}
+	defined := flagNames(fs)
+	c := &s.cfg
+	fs.IntVar(&c.BlurRadius, "blur", DefaultConfig.BlurRadius, "Blur radius")
+	fs.IntVar(&c.BlockSize, "bs", DefaultConfig.BlockSize, "Block size")
+	fs.IntVar(&c.OffsetThreshold, "ot", DefaultConfig.OffsetThreshold, "Offset threshold")
+	fs.IntVar(&c.MatchWindow, "window", 1, "Number of the following features in the sorted order each feature is compared with")
+	fs.Float64Var(&c.OffsetFraction, "otfrac", 0, "Offset threshold as a fraction of the analyzed blocks, replacing -ot when provided (0 to use -ot)")
+	fs.Float64Var(&c.DistanceThreshold, "dt", DefaultConfig.DistanceThreshold, "Distance threshold")
+	fs.BoolVar(&c.AdaptiveThreshold, "adaptive", false, "Scale the distance threshold with the block variance")
+	fs.IntVar(&c.MinForgedBlocks, "minblocks", DefaultConfig.MinForgedBlocks, "Minimum number of forged blocks for reporting the image as forged")
+	fs.Float64Var(&c.MinShift, "minshift", 0, "Minimum shift between the matched blocks (defaults to the block size)")
+	fs.Float64Var(&c.MaxShift, "maxshift", 0, "Maximum shift between the matched blocks (0 to disable)")
+	fs.Float64Var(&c.ShiftBinSize, "shiftbin", 1, "Width in pixels of the bins the shift vectors are accumulated in")
+	fs.IntVar(&c.PyramidLevels, "levels", 0, "Analyze the image at this number of Gaussian pyramid levels and fuse the detections (0 or 1 to analyze the image only)")
+	fs.IntVar(&c.Instances, "instances", 0, "Number of the copy-move instances the shift vectors are clustered into (0 to select automatically)")
+	fs.Float64Var(&c.MinShiftConcentration, "concentration", 0, "Minimum fraction of the matches displaced by the dominant shift for a forged verdict (0 to disable)")
+	fs.Float64Var(&c.ForgeryThreshold, "ft", DefaultConfig.ForgeryThreshold, "Forgery threshold")
+	fs.BoolVar(&c.Equalize, "equalize", false, "Equalize the luminance histogram of the low contrast images")
+	fs.IntVar(&c.CLAHETiles, "clahe", 0, "Equalize the luminance adaptively in this number of tiles in each direction (0 to disable)")
+	fs.Float64Var(&c.CLAHEClipLimit, "clahe-clip", defaultCLAHEClipLimit, "Clip limit of the adaptive equalization, as a multiple of the mean histogram bin")
+	fs.BoolVar(&c.InputYUV, "yuvin", false, "Take the input image as already converted to YUV, with the Y, U and V channels stored as red, green and blue")
+	fs.BoolVar(&c.DetectGrid, "grid", false, "Detect the regions breaking the JPEG blocking-artifact grid")
+	fs.Float64Var(&c.Gamma, "gamma", 1, "Gamma correction applied before the analysis (1 to disable)")
+	fs.IntVar(&c.MedianWindow, "median", 0, "Median filter window size, an odd number (0 to disable)")
+	fs.IntVar(&c.MaxImageSize, "maxdim", DefaultConfig.MaxImageSize, "Downscale the image to this maximum width or height (0 to disable)")
+	fs.StringVar(&s.resampling, "resample", "bilinear", "Interpolation of the downscaling: nearest, bilinear or catmullrom")
+	fs.IntVar(&c.Step, "step", 1, "Distance in pixels between the neighboring blocks")
+	fs.IntVar(&c.MaxFeatures, "maxfeatures", 0, "Sample the blocks so that at most this number of features is extracted from the image or from each tile (0 to extract every block)")
+	fs.Int64Var(&c.SampleSeed, "sampleseed", 0, "Seed of the random sampling of the blocks limited by -maxfeatures (0 to sample the blocks evenly)")
+	fs.IntVar(&c.TileSize, "tile", 0, "Process the image in tiles of this size (0 to disable)")
+	fs.IntVar(&c.TileOverlap, "overlap", 0, "Overlap between the neighboring tiles (at least the block size)")
+	fs.Float64Var(&c.CorrelationThreshold, "corr", 0, "Match the blocks by the correlation of their descriptors above this threshold (0 to disable)")
+	fs.Float64Var(&c.NCCThreshold, "ncc", 0, "Normalized cross-correlation threshold for verifying the matches (0 to disable)")
+	fs.Float64Var(&c.SSIMThreshold, "ssim", 0, "Structural similarity threshold for verifying the matches (0 to disable)")
+	fs.StringVar(&s.features, "features", "dct", "Comma separated block descriptors: dct, sobel, meanvar, entropy, zernike, fm, channels")
+	fs.StringVar(&s.weights, "weights", "", "Weights of the Y, R, G and B channels in the DCT features as y,r,g,b, where 0 drops a channel (equal weights by default)")
+	fs.IntVar(&c.LowFreqCount, "lowfreq", defaultLowFreqCount, "Number of the lowest frequency luminance DCT coefficients of the DCT features")
+	fs.IntVar(&c.ZernikeOrder, "zorder", 4, "Maximum order of the Zernike moment features")
+	fs.BoolVar(&c.Normalize, "normalize", false, "Standardize the block features to zero mean and unit variance")
+	fs.IntVar(&c.PCAComponents, "pca", 0, "Reduce the block features to this number of principal components (0 to disable)")
+	fs.StringVar(&s.metric, "metric", "euclidean", "Distance metric: euclidean, manhattan or chebyshev")
+	fs.IntVar(&c.MaskClosing, "closing", 0, "Structuring element size in pixels for closing the forgery mask (0 to disable)")
+	fs.IntVar(&c.MinRegionArea, "minarea", 0, "Minimum area in pixels of a connected forged region (0 to keep every region)")
+	fs.Float64Var(&c.MinAlpha, "minalpha", 0, "Skip the blocks with a lower mean opacity, between 0 and 1 (0 to analyze every block)")
+	fs.Float64Var(&c.MinTextureEnergy, "texture", 0, "Skip the flat blocks with a lower texture energy, the sum of their high frequency DCT coefficients (0 to analyze every block)")
+	fs.StringVar(&s.background, "bg", "", "Composite the transparent images over this #rrggbb background color")
+	fs.StringVar(&s.roi, "roi", "", "Analyze only the x,y,w,h region of interest")
+	fs.StringVar(&s.exclude, "exclude", "", "Mask image whose white pixels mark the regions excluded from the detection")
+	fs.StringVar(&s.cache, "cache", "", "Cache the extracted block features in this directory, reusing them when the same image is analyzed again")
+	fs.BoolVar(&c.DetectFlips, "flips", false, "Detect the horizontally and vertically mirrored copies as well")
+	fs.IntVar(&c.Workers, "workers", runtime.NumCPU(), "Number of goroutines extracting and comparing the block features")
+	fs.Float64Var(&s.sensitivity, "sensitivity", 0.5, "Detection sensitivity between 0 and 1, overriding the -ot and -minblocks thresholds when provided")
+	fs.StringVar(&s.configPath, "config", "", "Load the flag values from this JSON file, the command line flags override them")
+	fs.BoolVar(&s.verbose, "verbose", false, "Print the diagnostic messages and progress on the standard error")
+
+	// The input, output and the other flags of the subcommand can't be set by the configuration file.
+	s.names = flagNames(fs)
+	for name := range defined {
+		delete(s.names, name)
+	}
+	delete(s.names, "config")
+	return s
+}
+
+// flagNames returns the names of the flags defined on the flag set.
+func flagNames(fs *flag.FlagSet) map[string]bool {
+	names := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		names[f.Name] = true
+	})
+	return names
+}
+
+// addAnnotationFlags defines the flags of the annotated image on the flag set.
+func (s *settingsFlags) addAnnotationFlags() {
+	s.fs.StringVar(&s.highlight, "color", s.highlight, "Annotate the forged regions with this #rrggbb color, the destinations with its complementary color")
+	s.fs.IntVar(&s.cfg.LineThickness, "thickness", 0, "Outline the annotated regions with lines of this thickness (0 to fill the regions)")
+	s.names["color"], s.names["thickness"] = true, true
+}
+
+// config applies the configuration file to the flags of the subcommand and returns the validated detection settings.
+// It must be called after parsing the arguments and before reading the other flags of the subcommand.
+func (s *settingsFlags) config() (Config, error) {
+	if len(s.configPath) > 0 {
+		if err := loadConfigFile(s.fs, s.names, s.configPath); err != nil {
+			return Config{}, err
+		}
+	}
+
+	cfg := s.cfg
+	var err error
+	if cfg.Metric, err = parseMetric(s.metric); err != nil {
+		return cfg, err
+	}
+	if cfg.Resampling, err = parseResampling(s.resampling); err != nil {
+		return cfg, err
+	}
+	if cfg.Features, err = parseFeatureSet(s.features); err != nil {
+		return cfg, err
+	}
+	if len(s.weights) > 0 {
+		if cfg.DCTWeights, err = parseChannelWeights(s.weights); err != nil {
+			return cfg, err
+		}
+	}
+	if len(s.background) > 0 {
+		c, err := parseHexColor(s.background)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Background = &c
+	}
+	if cfg.HighlightColor, err = parseHexColor(s.highlight); err != nil {
+		return cfg, err
+	}
+	if len(s.roi) > 0 {
+		if cfg.ROI, err = parseRect(s.roi); err != nil {
+			return cfg, err
+		}
+	}
+	if len(s.exclude) > 0 {
+		if cfg.Exclude, err = loadImage(s.exclude); err != nil {
+			return cfg, fmt.Errorf("reading the exclusion mask: %v", err)
+		}
+	}
+	if len(s.cache) > 0 {
+		if cfg.FeatureCache, err = NewFeatureCache(s.cache); err != nil {
+			return cfg, fmt.Errorf("creating the feature cache: %v", err)
+		}
+	}
+
+	// The sensitivity is applied only when explicitly provided, so it doesn't override the individual thresholds.
+	var sensitivitySet bool
+	s.fs.Visit(func(f *flag.Flag) {
+		sensitivitySet = sensitivitySet || f.Name == "sensitivity"
+	})
+	if sensitivitySet {
+		if s.sensitivity < 0 || s.sensitivity > 1 {
+			return cfg, errors.New("the sensitivity must be between 0 and 1")
+		}
+		applySensitivity(&cfg, s.sensitivity)
+	}
+
+	// Report the invalid settings, including the ones of the configuration file, before any image is loaded.
+	return cfg, cfg.validate()
+}
+
+// outputFlags are the flags of the output images shared by the detect and visualize subcommands.
+type outputFlags struct {
+	destination string
+	format      string
+	quality     int
+	mask        string
+	yuv         string
+}
+
+// addOutputFlags defines the flags of the output images on the flag set.
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{}
+	fs.StringVar(&o.destination, "out", "", "Output image")
+	fs.StringVar(&o.format, "outformat", "", "Output image format: png or jpeg (inferred from the output file extension by default)")
+	fs.IntVar(&o.quality, "quality", 90, "Quality of the JPEG output image, between 1 and 100")
+	fs.StringVar(&o.mask, "mask", "", "Output the binary forgery mask")
+	fs.StringVar(&o.yuv, "yuvout", "", "Output the YUV converted image, with the Y, U and V channels stored as red, green and blue")
+	return o
+}
+
+// validate checks the output image format and quality.
+func (o *outputFlags) validate() error {
+	if o.format != "" && o.format != "png" && o.format != "jpeg" && o.format != "jpg" {
+		return fmt.Errorf("unknown output image format %q", o.format)
+	}
+	if o.quality < 1 || o.quality > 100 {
+		return errors.New("the JPEG quality must be between 1 and 100")
+	}
+	return nil
+}
+
+// writeYUV writes the YUV converted source image, when requested.
+func (o *outputFlags) writeYUV(src image.Image) {
+	if len(o.yuv) > 0 {
+		if err := saveImage(o.yuv, convertRGBImageToYUV(src), "", o.quality); err != nil {
+			log.Printf("Error saving the YUV image: %v", err)
+		}
+	}
+}
+
+// write writes the annotated image, with the copy directions in the arrows mode, and the forgery mask, when requested.
+func (o *outputFlags) write(src image.Image, res *Result, cfg Config, mode string) {
+	if len(o.destination) > 0 {
+		if err := saveImage(o.destination, annotateMode(src, res, cfg, mode), o.format, o.quality); err != nil {
+			log.Printf("Error saving the output image: %v", err)
+		}
+	}
+	if len(o.mask) > 0 {
+		if err := saveImage(o.mask, res.Mask, "", o.quality); err != nil {
+			log.Printf("Error saving the mask image: %v", err)
+		}
+	}
+}
+
+// annotateMode returns the image annotated with the forged regions, or with the copy directions in the arrows mode.
+func annotateMode(src image.Image, res *Result, cfg Config, mode string) image.Image {
+	if mode == "arrows" {
+		return annotateArrows(src, res, cfg)
+	}
+	return annotate(src, res, cfg)
+}
+
+// loadInput loads the input image, decoding it from the memory mapped file when requested.
+func loadInput(path string, mapped bool) (image.Image, error) {
+	if mapped {
+		return loadMappedImage(path)
+	}
+	return loadImage(path)
+}
+
+// detectionContext returns the context of the detection, which is canceled after the timeout, when provided.
+func detectionContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// detectCommand detects the forgeries of an image and prints the verdict. It is run by the bare invocation too,
+// so it still accepts the flags of the modes which preceded the subcommands.
+type detectCommand struct {
+	settings *settingsFlags
+	output   *outputFlags
+	cfg      Config
+
+	source         string
+	mmap           bool
+	mode           string
+	jsonOutput     bool
+	profile        string
+	embedImage     bool
+	embedAnnotated bool
+	save           string
+	report         string
+	matches        string
+	timeout        time.Duration
+	dryRun         bool
+	dumpDCT        string
+	dumpBlock      string
+	verifyDCT      bool
+
+	batchDir     string
+	dedupDir     string
+	hashDistance int
+	diff         bool
+	version      bool
+
+	benchmarkImage string
+	seed           int64
+}
+
+// parseDetect parses the arguments of the detect subcommand.
+func parseDetect(args []string) (runner, error) {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), Banner, buildInfo().Version)
+		fmt.Fprint(fs.Output(), commandsUsage)
+		fs.PrintDefaults()
+	}
+	c := &detectCommand{settings: addSettingsFlags(fs), output: addOutputFlags(fs)}
+	c.settings.addAnnotationFlags()
+	fs.StringVar(&c.source, "in", "", "Input image path or HTTP(S) URL")
+	fs.BoolVar(&c.mmap, "mmap", false, "Decode the input image from its memory mapped file, which avoids copying the very large files through read buffers")
+	fs.StringVar(&c.mode, "mode", "image", "Detection mode: image, arrows for annotating the copy directions, or gif for analyzing each frame of an animated GIF")
+	fs.BoolVar(&c.jsonOutput, "json", false, "Print the detection result as JSON on the standard output")
+	fs.StringVar(&c.profile, "json-profile", nativeProfile, "Profile of the JSON result: native, or flat for the forensic case management platforms")
+	fs.BoolVar(&c.embedImage, "embed-image", false, "Embed the analyzed image as a base64 PNG data URL into the JSON result")
+	fs.BoolVar(&c.embedAnnotated, "embed-annotated", false, "Embed the annotated image as a base64 PNG data URL into the JSON result")
+	fs.StringVar(&c.save, "save", "", "Save the detection result, including the forgery mask, to this file for the visualize command")
+	fs.StringVar(&c.report, "report", "", "Write a self-contained HTML report of the detection, with the annotated image, to this file")
+	fs.StringVar(&c.matches, "matches", "", "Write the confirmed matched block pairs to this CSV file, one xa,ya,xb,yb,offsetX,offsetY row per pair")
+	fs.BoolVar(&c.settings.cfg.CollectStats, "stats", false, "Report the wall time and the number of items of each pipeline stage")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Abort the detection if it takes longer than this duration (0 to disable)")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Decode the image and report the number of blocks and features the detection would process and its estimated time, without running it")
+	fs.StringVar(&c.dumpDCT, "dump-dct", "", "Write the DCT coefficients of the analyzed blocks to this file (- for the standard output)")
+	fs.StringVar(&c.dumpBlock, "dump-block", "", "Write the DCT coefficients of the x,y block only, in the analyzed image coordinates")
+	fs.BoolVar(&c.verifyDCT, "verify-dct", false, "Verify the DCT implementation by reconstructing the analyzed blocks from their coefficients")
+	fs.StringVar(&c.batchDir, "batch", "", "Analyze every image of a directory, printing one JSON result per line (see the batch command)")
+	fs.StringVar(&c.dedupDir, "dedup", "", "Find the near duplicate images in a directory")
+	fs.IntVar(&c.hashDistance, "hd", 5, "Maximum Hamming distance between duplicate image hashes")
+	fs.BoolVar(&c.diff, "diff", false, "Write the amplified difference of two images: -diff a.png b.png out.png")
+	fs.BoolVar(&c.version, "version", false, "Print the version, the Go version and the commit of the build (see the version command)")
+	fs.StringVar(&c.benchmarkImage, "benchmark-image", "", "Write a reproducible WxH synthetic image holding a known copy to the -out image, and its ground truth to the -mask image")
+	fs.Int64Var(&c.seed, "seed", 1, "Seed of the synthetic image written by -benchmark-image")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	if c.version || len(c.dedupDir) > 0 {
+		return c, nil
+	}
+	if len(c.benchmarkImage) > 0 {
+		if _, err := parseSize(c.benchmarkImage); err != nil {
+			return nil, err
+		}
+		if len(c.output.destination) == 0 {
+			return nil, errors.New("usage: forensic -benchmark-image 512x512 -out synthetic.png")
+		}
+		return c, c.output.validate()
+	}
+	if c.diff {
+		if fs.NArg() != 3 {
+			return nil, errors.New("usage: forensic -diff a.png b.png out.png")
+		}
+		return c, nil
+	}
+
+	var err error
+	if c.cfg, err = c.settings.config(); err != nil {
+		return nil, err
+	}
+	c.cfg.CollectMatches = len(c.matches) > 0
+	if err := c.output.validate(); err != nil {
+		return nil, err
+	}
+	if c.mode != "image" && c.mode != "arrows" && c.mode != "gif" {
+		return nil, fmt.Errorf("unknown detection mode %q", c.mode)
+	}
+	if err := checkJSONProfile(c.profile); err != nil {
+		return nil, err
+	}
+	if (c.embedImage || c.embedAnnotated) && (!c.jsonOutput || c.profile != nativeProfile || c.mode == "gif") {
+		return nil, errors.New("the images are embedded only into the native JSON result of an image, with -json")
+	}
+	// The output image is optional when the result is requested as JSON, saved, reported or its matches are exported, and it is not produced for the GIF frames.
+	// The batch mode prints only the JSON results, and the dry run only the planned work.
+	if len(c.batchDir) == 0 && (len(c.source) == 0 || (len(c.output.destination) == 0 && !c.jsonOutput && len(c.save) == 0 && len(c.report) == 0 && len(c.matches) == 0 && c.mode != "gif" && len(c.dumpDCT) == 0 && !c.verifyDCT && !c.dryRun)) {
+		return nil, errors.New("usage: forensic -in input.jpg -out out.jpg")
+	}
+	return c, nil
+}
+
+func (c *detectCommand) run() {
+	if c.version {
+		if err := printVersion(os.Stdout, c.jsonOutput); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		return
+	}
+	if len(c.dedupDir) > 0 {
+		printDuplicates(c.dedupDir, c.hashDistance)
+		return
+	}
+	if c.diff {
+		writeDifference(c.settings.fs.Arg(0), c.settings.fs.Arg(1), c.settings.fs.Arg(2), c.output)
+		return
+	}
+	if len(c.benchmarkImage) > 0 {
+		writeBenchmarkImage(c.benchmarkImage, c.seed, c.output)
+		return
+	}
+	setVerbose(c.settings.verbose)
+	start := time.Now()
+
+	if len(c.dumpDCT) > 0 {
+		dumpBlockDCT(c.source, c.dumpDCT, c.dumpBlock, c.cfg)
+		return
+	}
+	if c.verifyDCT {
+		verifyBlockDCT(c.source, c.cfg)
+		return
+	}
+	if len(c.batchDir) > 0 {
+		if c.dryRun {
+			planBatchDir(c.batchDir, c.cfg)
+			return
+		}
+		runBatch(c.batchDir, c.cfg, c.timeout)
+		return
+	}
+	if c.dryRun {
+		planImage(c.source, c.mmap, c.cfg, c.jsonOutput)
+		return
+	}
+
+	ctx, cancel := detectionContext(c.timeout)
+	defer cancel()
+	if c.mode == "gif" {
+		detectGIF(ctx, c.source, c.cfg, c.jsonOutput)
+		debugLog.Printf("Done in: %.2fs", time.Since(start).Seconds())
+		return
+	}
+
+	src, err := loadInput(c.source, c.mmap)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	c.output.writeYUV(src)
+	res, err := DetectContext(ctx, src, c.cfg)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	res.JPEGQuality = fileJPEGQuality(c.source)
+	c.output.write(src, res, c.cfg, c.mode)
+	if len(c.save) > 0 {
+		if err := SaveResult(*res, c.save); err != nil {
+			log.Printf("Error saving the result: %v", err)
+		}
+	}
+	if len(c.report) > 0 {
+		if err := saveReport(c.report, c.source, src, res, c.cfg); err != nil {
+			log.Printf("Error writing the report: %v", err)
+		}
+	}
+	if len(c.matches) > 0 {
+		if err := saveMatches(c.matches, res.Matches); err != nil {
+			log.Printf("Error writing the matches: %v", err)
+		}
+	}
+
+	// The standard output is reserved for the JSON result, the summary is printed on the standard error.
+	summary := os.Stdout
+	if c.jsonOutput {
+		summary = os.Stderr
+		var out interface{} = res
+		if c.profile == flatProfile {
+			out = newFlatRecord(c.source, src, res, start)
+		}
+		if c.embedImage || c.embedAnnotated {
+			var img, annotated image.Image
+			if c.embedImage {
+				img = src
+			}
+			if c.embedAnnotated {
+				annotated = annotateMode(src, res, c.cfg, c.mode)
+			}
+			if out, err = newEmbeddedResult(res, img, annotated); err != nil {
+				log.Fatalf("Error embedding the images: %v", err)
+			}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+			log.Fatalf("Error encoding the result: %v", err)
+		}
+	}
+	printSummary(summary, res, c.cfg)
+
+	debugLog.Printf("Done in: %.2fs", time.Since(start).Seconds())
+}
+
+// printSummary prints the verdict and the details of the detection result.
+func printSummary(w io.Writer, res *Result, cfg Config) {
+	if res.InsufficientBlocks {
+		fmt.Fprintln(w, "The image holds a single block, too few for detecting the copies")
+	} else if !res.Analyzed {
+		fmt.Fprintln(w, "WARNING: no block of the image produced features, like the transparent, excluded or flat blocks")
+	}
+	fmt.Fprintln(w, "Number of forged blocks detected:", len(res.Regions))
+	fmt.Fprintf(w, "Forged area: %.2f%% of the image\n", res.ForgedArea)
+	if res.AffineInliers > 0 {
+		fmt.Fprintf(w, "Copy confidence: %.2f (%d affine inliers)\n", res.CopyConfidence, res.AffineInliers)
+	}
+	if len(res.Instances) > 0 {
+		fmt.Fprintln(w, "Copy-move instances:", len(res.Instances))
+	}
+	if cfg.DetectGrid {
+		fmt.Fprintln(w, "Regions breaking the JPEG grid:", len(res.GridMisaligned))
+	}
+	if res.JPEGQuality > 0 {
+		fmt.Fprintf(w, "Estimated JPEG quality: %d\n", res.JPEGQuality)
+	}
+	fmt.Fprintln(w, verdict(res))
+	if len(res.Stats) > 0 {
+		printStats(w, res.Stats)
+	}
+}
+
+// evalCommand evaluates the detection of a forged image against the ground truth mask of its forged regions.
+type evalCommand struct {
+	settings *settingsFlags
+	cfg      Config
+
+	source     string
+	mmap       bool
+	truth      string
+	jsonOutput bool
+	timeout    time.Duration
+}
+
+// parseEval parses the arguments of the eval subcommand.
+func parseEval(args []string) (runner, error) {
+	fs := newFlagSet("eval", "-in forged.png -truth mask.png [flags]")
+	c := &evalCommand{settings: addSettingsFlags(fs)}
+	fs.StringVar(&c.source, "in", "", "Input image path or HTTP(S) URL")
+	fs.BoolVar(&c.mmap, "mmap", false, "Decode the input image from its memory mapped file, which avoids copying the very large files through read buffers")
+	fs.StringVar(&c.truth, "truth", "", "Ground truth mask image whose white pixels mark the forged regions")
+	fs.BoolVar(&c.jsonOutput, "json", false, "Print the evaluation as JSON on the standard output")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Abort the detection if it takes longer than this duration (0 to disable)")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.cfg, err = c.settings.config(); err != nil {
+		return nil, err
+	}
+	if len(c.source) == 0 || len(c.truth) == 0 {
+		return nil, errors.New("usage: forensic eval -in forged.png -truth mask.png")
+	}
+	return c, nil
+}
+
+func (c *evalCommand) run() {
+	setVerbose(c.settings.verbose)
+	src, err := loadInput(c.source, c.mmap)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	truth, err := loadImage(c.truth)
+	if err != nil {
+		log.Fatalf("Error reading the ground truth mask: %v", err)
+	}
+	ctx, cancel := detectionContext(c.timeout)
+	defer cancel()
+	res, err := DetectContext(ctx, src, c.cfg)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	e, err := evaluateMask(res.Mask, truth)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	e.Forged = res.Forged
+
+	if c.jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(e); err != nil {
+			log.Fatalf("Error encoding the evaluation: %v", err)
+		}
+		return
+	}
+	fmt.Println("Forged:", e.Forged)
+	fmt.Printf("Pixels: %d true positive, %d false positive, %d false negative\n", e.TruePositives, e.FalsePositives, e.FalseNegatives)
+	fmt.Printf("Precision: %.3f, recall: %.3f, F1: %.3f\n", e.Precision, e.Recall, e.F1)
+}
+
+// visualizeCommand writes the image annotated with the detected forgeries.
+type visualizeCommand struct {
+	settings *settingsFlags
+	output   *outputFlags
+	cfg      Config
+
+	source  string
+	result  string
+	mmap    bool
+	mode    string
+	timeout time.Duration
+}
+
+// parseVisualize parses the arguments of the visualize subcommand.
+func parseVisualize(args []string) (runner, error) {
+	fs := newFlagSet("visualize", "-in input.jpg -out out.jpg [flags]")
+	c := &visualizeCommand{settings: addSettingsFlags(fs), output: addOutputFlags(fs)}
+	c.settings.addAnnotationFlags()
+	fs.StringVar(&c.source, "in", "", "Input image path or HTTP(S) URL")
+	fs.BoolVar(&c.mmap, "mmap", false, "Decode the input image from its memory mapped file, which avoids copying the very large files through read buffers")
+	fs.StringVar(&c.result, "result", "", "Visualize the result saved by detect -save instead of running the detection")
+	fs.StringVar(&c.mode, "mode", "image", "Annotation mode: image, or arrows for annotating the copy directions")
+	fs.DurationVar(&c.timeout, "timeout", 0, "Abort the detection if it takes longer than this duration (0 to disable)")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.cfg, err = c.settings.config(); err != nil {
+		return nil, err
+	}
+	if err := c.output.validate(); err != nil {
+		return nil, err
+	}
+	if c.mode != "image" && c.mode != "arrows" {
+		return nil, fmt.Errorf("unknown annotation mode %q", c.mode)
+	}
+	if len(c.source) == 0 || (len(c.output.destination) == 0 && len(c.output.mask) == 0 && len(c.output.yuv) == 0) {
+		return nil, errors.New("usage: forensic visualize -in input.jpg -out out.jpg")
+	}
+	return c, nil
+}
+
+func (c *visualizeCommand) run() {
+	setVerbose(c.settings.verbose)
+	src, err := loadInput(c.source, c.mmap)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	c.output.writeYUV(src)
+	if len(c.output.destination) == 0 && len(c.output.mask) == 0 {
+		return
+	}
+	if len(c.result) > 0 {
+		res, err := LoadResult(c.result)
+		if err != nil {
+			log.Fatalf("Error reading the result file: %v", err)
+		}
+		if err := checkResultImage(&res, src); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		c.output.write(src, &res, c.cfg, c.mode)
+		return
+	}
+	ctx, cancel := detectionContext(c.timeout)
+	defer cancel()
+	res, err := DetectContext(ctx, src, c.cfg)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	c.output.write(src, res, c.cfg, c.mode)
+}
+
+// batchCommand analyzes every image of a directory.
+type batchCommand struct {
+	settings *settingsFlags
+	cfg      Config
+
+	dir     string
+	timeout time.Duration
+	dryRun  bool
+}
+
+// parseBatch parses the arguments of the batch subcommand.
+func parseBatch(args []string) (runner, error) {
+	fs := newFlagSet("batch", "[flags] dir")
+	c := &batchCommand{settings: addSettingsFlags(fs)}
+	fs.DurationVar(&c.timeout, "timeout", 0, "Abort the detection of an image if it takes longer than this duration (0 to disable)")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Decode the images and report the number of blocks and features the detections would process and their estimated time, without running them")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.cfg, err = c.settings.config(); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		return nil, errors.New("usage: forensic batch [flags] dir")
+	}
+	c.dir = fs.Arg(0)
+	return c, nil
+}
+
+func (c *batchCommand) run() {
+	setVerbose(c.settings.verbose)
+	if c.dryRun {
+		planBatchDir(c.dir, c.cfg)
+		return
+	}
+	runBatch(c.dir, c.cfg, c.timeout)
+}
+
+// runBatch analyzes every image of the directory, printing one JSON result per line.
+func runBatch(dir string, cfg Config, timeout time.Duration) {
+	start := time.Now()
+	count, err := detectBatch(dir, cfg, timeout, os.Stdout)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	debugLog.Printf("Analyzed images: %d, done in: %.2fs", count, time.Since(start).Seconds())
+}
+
+// planBatchDir prints the work the detection of every image of the directory would do, one JSON plan per line,
+// and the total work on the standard error.
+func planBatchDir(dir string, cfg Config) {
+	plans, err := planBatch(dir, cfg, os.Stdout)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	var total Plan
+	for _, plan := range plans {
+		total.Blocks += plan.Blocks
+		total.Features += plan.Features
+		total.Seconds += plan.Seconds
+	}
+	fmt.Fprintf(os.Stderr, "Planned images: %d, blocks: %d, features: %d, estimated time: %.1fs\n", len(plans), total.Blocks, total.Features, total.Seconds)
+}
+
+// planImage prints the work the detection of the image would do, as JSON when requested.
+func planImage(path string, mapped bool, cfg Config, jsonOutput bool) {
+	src, err := loadInput(path, mapped)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	plan, err := PlanDetection(src, cfg)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(plan); err != nil {
+			log.Fatalf("Error encoding the plan: %v", err)
+		}
+		return
+	}
+	printPlan(os.Stdout, plan)
+}
+
+// printPlan prints the work the detection would do.
+func printPlan(w io.Writer, plan *Plan) {
+	fmt.Fprintf(w, "Analyzed size: %dx%d\n", plan.Width, plan.Height)
+	if plan.Levels > 1 {
+		fmt.Fprintln(w, "Pyramid levels:", plan.Levels)
+	}
+	if plan.Tiles > plan.Levels {
+		fmt.Fprintln(w, "Tiles:", plan.Tiles)
+	}
+	fmt.Fprintln(w, "Blocks:", plan.Blocks)
+	fmt.Fprintln(w, "Features:", plan.Features)
+	fmt.Fprintf(w, "Estimated time: %.1fs\n", plan.Seconds)
+}
+
+// compareCommand compares the regions detected in an image with two configurations.
+type compareCommand struct {
+	settings *settingsFlags
+	cfg      [2]Config
+
+	source     string
+	mmap       bool
+	paths      [2]string
+	jsonOutput bool
+	timeout    time.Duration
+}
+
+// parseCompare parses the arguments of the compare subcommand. The arguments are parsed twice, once for each
+// configuration, so the flags of the command line apply to both and the files of -a and -b to one of them.
+func parseCompare(args []string) (runner, error) {
+	c := &compareCommand{}
+	for i := range c.cfg {
+		fs := newFlagSet("compare", "-in input.jpg -a a.json -b b.json [flags]")
+		c.settings = addSettingsFlags(fs)
+		fs.StringVar(&c.source, "in", "", "Input image path or HTTP(S) URL")
+		fs.BoolVar(&c.mmap, "mmap", false, "Decode the input image from its memory mapped file, which avoids copying the very large files through read buffers")
+		fs.StringVar(&c.paths[0], "a", "", "Configuration file of the baseline detection, in the format of -config")
+		fs.StringVar(&c.paths[1], "b", "", "Configuration file of the compared detection, in the format of -config")
+		fs.BoolVar(&c.jsonOutput, "json", false, "Print the comparison as JSON on the standard output")
+		fs.DurationVar(&c.timeout, "timeout", 0, "Abort each detection if it takes longer than this duration (0 to disable)")
+		if err := parseArgs(fs, args); err != nil {
+			return nil, err
+		}
+		if len(c.settings.configPath) > 0 {
+			return nil, errors.New("the configuration files of the compare command are provided with -a and -b")
+		}
+		c.settings.configPath = c.paths[i]
+		var err error
+		if c.cfg[i], err = c.settings.config(); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.source) == 0 || (len(c.paths[0]) == 0 && len(c.paths[1]) == 0) {
+		return nil, errors.New("usage: forensic compare -in input.jpg -a a.json -b b.json")
+	}
+	return c, nil
+}
+
+func (c *compareCommand) run() {
+	setVerbose(c.settings.verbose)
+	src, err := loadInput(c.source, c.mmap)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	ctx, cancel := detectionContext(c.timeout)
+	defer cancel()
+	cmp, err := CompareDetections(ctx, src, c.cfg[0], c.cfg[1])
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if c.jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(cmp); err != nil {
+			log.Fatalf("Error encoding the comparison: %v", err)
+		}
+		return
+	}
+	printComparison(os.Stdout, cmp, c.paths)
+}
+
+// printComparison prints the comparison like a unified diff: the header of each configuration with its verdict,
+// then the changed regions in their position order, prefixed by + when added and - when removed. The unchanged
+// regions are only counted, since the forged blocks of a copy are usually numerous.
+func printComparison(w io.Writer, cmp *Comparison, paths [2]string) {
+	for i, res := range []*Result{cmp.A, cmp.B} {
+		name := paths[i]
+		if len(name) == 0 {
+			name = "(command line flags)"
+		}
+		verdict := "not forged"
+		if res.Forged {
+			verdict = "forged"
+		}
+		fmt.Fprintf(w, "%s %s: %s, %d regions\n", []string{"---", "+++"}[i], name, verdict, len(res.Regions))
+	}
+
+	type line struct {
+		prefix string
+		region image.Rectangle
+	}
+	var lines []line
+	for _, r := range cmp.Removed {
+		lines = append(lines, line{"-", r})
+	}
+	for _, r := range cmp.Added {
+		lines = append(lines, line{"+", r})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return regionLess(lines[i].region, lines[j].region) })
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s %d,%d %dx%d\n", l.prefix, l.region.Min.X, l.region.Min.Y, l.region.Dx(), l.region.Dy())
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d unchanged regions\n", len(cmp.Added), len(cmp.Removed), len(cmp.Unchanged))
+}
+
+// selfCheckCommand checks the DCT implementations against known test vectors.
+type selfCheckCommand struct {
+	jsonOutput bool
+}
+
+// parseSelfCheck parses the arguments of the selfcheck subcommand.
+func parseSelfCheck(args []string) (runner, error) {
+	fs := newFlagSet("selfcheck", "[flags]")
+	c := &selfCheckCommand{}
+	fs.BoolVar(&c.jsonOutput, "json", false, "Print the outcome of each check as JSON")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *selfCheckCommand) run() {
+	checks := selfCheckDCT(dctTransforms)
+	if c.jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(checks); err != nil {
+			log.Fatalf("Error encoding the checks: %v", err)
+		}
+	} else {
+		printSelfCheck(os.Stdout, checks)
+	}
+	for _, check := range checks {
+		if !check.Passed {
+			log.Fatal("ERROR: the DCT doesn't compute the expected coefficients")
+		}
+	}
+}
+
+// printSelfCheck prints the outcome of each check, one per line.
+func printSelfCheck(w io.Writer, checks []DCTCheck) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\tmax error %.3g\n", status, check.Pattern, check.Transform, check.MaxError)
+	}
+	tw.Flush()
+}
+
+// versionCommand prints the build information.
+type versionCommand struct {
+	jsonOutput bool
+}
+
+// parseVersion parses the arguments of the version subcommand.
+func parseVersion(args []string) (runner, error) {
+	fs := newFlagSet("version", "[flags]")
+	c := &versionCommand{}
+	fs.BoolVar(&c.jsonOutput, "json", false, "Print the build information as JSON")
+	if err := parseArgs(fs, args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *versionCommand) run() {
+	if err := printVersion(os.Stdout, c.jsonOutput); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
+
+// printDuplicates prints the near duplicate images of the directory and their clusters.
+func printDuplicates(dir string, maxDist int) {
+	duplicates, err := findDuplicates(dir, maxDist)
+	if err != nil {
+		log.Fatalf("Error finding the duplicate images: %v", err)
+	}
+	for _, d := range duplicates {
+		fmt.Printf("%s <-> %s (distance: %d)\n", d.a, d.b, d.dist)
+	}
+	fmt.Printf("\nNumber of duplicate pairs found: %d\n", len(duplicates))
+
+	clusters := duplicateClusters(duplicates)
+	for i, c := range clusters {
+		fmt.Printf("\nCluster %d:\n", i+1)
+		for _, path := range c {
+			fmt.Println("  " + path)
+		}
+	}
+	fmt.Printf("\nNumber of duplicate clusters found: %d\n", len(clusters))
+}
+
+// writeDifference writes the amplified difference of the two images.
+func writeDifference(pathA, pathB, dst string, output *outputFlags) {
+	a, err := loadImage(pathA)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	b, err := loadImage(pathB)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	diff, err := difference(a, b)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if err := saveImage(dst, diff, output.format, output.quality); err != nil {
+		log.Fatalf("Error saving the output image: %v", err)
+	}
+}
+
+// writeBenchmarkImage writes the synthetic image of the WxH size and seed, and the ground truth mask of its copy
+// when requested, and prints the copied region and its destination.
+func writeBenchmarkImage(size string, seed int64, output *outputFlags) {
+	p, err := parseSize(size)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	img, src, dst, err := SyntheticImage(p.X, p.Y, seed)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if err := saveImage(output.destination, img, output.format, output.quality); err != nil {
+		log.Fatalf("Error saving the output image: %v", err)
+	}
+	if len(output.mask) > 0 {
+		truth := regionMask(img.Bounds(), []image.Rectangle{src, dst})
+		if err := saveImage(output.mask, truth, "", output.quality); err != nil {
+			log.Fatalf("Error saving the mask image: %v", err)
+		}
+	}
+	fmt.Printf("Copied region %v pasted to %v\n", src, dst)
+}
+
+// detectGIF runs the detection on each frame of an animated GIF and prints the per-frame results.
+func detectGIF(ctx context.Context, path string, cfg Config, jsonOutput bool) {
+	g, err := loadGIF(path)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	results, err := DetectGIF(ctx, g, cfg)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if jsonOutput {
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			log.Fatalf("Error encoding the result: %v", err)
+		}
+		return
+	}
+	for _, r := range results {
+		var note string
+		if r.Suspicious {
+			note = " (differs suspiciously from its neighbors)"
+		}
+		fmt.Printf("Frame %d: forged: %v, forged blocks: %d, change: %.2f%s\n", r.Frame, r.Forged, len(r.Regions), r.Change, note)
+	}
+}
+
+// dumpBlockDCT writes the DCT coefficients of the blocks of the image, preprocessed the same way as for
+// the detection, to the destination file or the standard output. The x,y block selects a single block.
+func dumpBlockDCT(path, dst, blockPos string, cfg Config) {
+	var block *image.Point
+	if len(blockPos) > 0 {
+		p, err := parsePoint(blockPos)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		block = &p
+	}
+
+	img := loadPreprocessed(path, cfg)
+	out := os.Stdout
+	if dst != "-" {
+		var err error
+		if out, err = os.Create(dst); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		defer out.Close()
+	}
+	if err := dumpDCT(out, img, cfg.BlockSize, cfg.blockStep(), block); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
+
+// verifyBlockDCT reconstructs the blocks of the preprocessed image from their DCT coefficients and
+// reports the reconstruction error. It fails when the error exceeds the floating point rounding.
+func verifyBlockDCT(path string, cfg Config) {
+	img := loadPreprocessed(path, cfg)
+	maxErr, meanErr, blocks := dctReconstructionError(img, cfg.BlockSize, cfg.blockStep())
+	fmt.Printf("DCT reconstruction error over %d blocks: max %.3g, mean %.3g\n", blocks, maxErr, meanErr)
+	if maxErr > dctEpsilon {
+		log.Fatal("ERROR: the inverse DCT doesn't reconstruct the blocks")
+	}
+}
+
+// loadPreprocessed loads the image and preprocesses it the same way as for the detection.
+func loadPreprocessed(path string, cfg Config) *image.RGBA {
+	src, err := loadImage(path)
+	if err != nil {
+		log.Fatalf("Error reading the image file: %v", err)
+	}
+	if cfg.InputYUV {
+		if err := checkYUVInput(src); err != nil {
+			log.Fatal(err)
+		}
+		src = yuvChannels(src)
+	}
+	resized, _ := downscale(src, cfg.MaxImageSize, cfg.Resampling)
+	if cfg.Background != nil {
+		resized = flatten(resized, *cfg.Background)
+	}
+	return preprocess(imgToNRGBA(resized), cfg)
+}
@@ -0,0 +1,254 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command line tool instead of the tests when FORENSIC_MAIN holds its arguments,
+// so the tests can check the output of the tool run in a subprocess.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("FORENSIC_MAIN"); ok {
+		os.Args = append([]string{"forensic"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestJSONOutputIsClean(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "forged.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// The diagnostics are enabled too, since they must not leak into the standard output either.
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "FORENSIC_MAIN=-in "+path+" -json -verbose")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("the tool failed: %v\n%s", err, stderr.String())
+	}
+
+	out := stdout.String()
+	dec := json.NewDecoder(strings.NewReader(out))
+	var res Result
+	if err := dec.Decode(&res); err != nil {
+		t.Fatalf("the standard output is not a JSON result: %v\n%s", err, out)
+	}
+	var rest bytes.Buffer
+	rest.ReadFrom(dec.Buffered())
+	if strings.TrimSpace(rest.String()) != "" || dec.More() {
+		t.Errorf("the standard output holds more than the JSON result: %q", rest.String())
+	}
+	if !strings.Contains(stderr.String(), "Number of forged blocks detected:") || !strings.Contains(stderr.String(), "[debug] ") {
+		t.Errorf("the summary and the diagnostics are not printed on the standard error: %q", stderr.String())
+	}
+}
+
+func TestYUVOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A known input, with a pure red corner.
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	draw.Draw(img, image.Rect(0, 0, 8, 8), &image.Uniform{color.RGBA{255, 0, 0, 255}}, image.Point{}, draw.Src)
+	path := filepath.Join(dir, "in.png")
+	if err := saveImage(path, img, "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	yuvPath := filepath.Join(dir, "yuv.png")
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "FORENSIC_MAIN=-in "+path+" -yuvout "+yuvPath+" -json")
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = ioutil.Discard, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("the tool failed: %v\n%s", err, stderr.String())
+	}
+
+	yuv, err := loadImage(yuvPath)
+	if err != nil {
+		t.Fatalf("the YUV image has not been written: %v", err)
+	}
+	if !samePixels(yuv, convertRGBImageToYUV(img)) {
+		t.Error("the written YUV image differs from the converted image")
+	}
+	y, u, v := color.RGBToYCbCr(255, 0, 0)
+	if got, want := color.RGBAModel.Convert(yuv.At(0, 0)), (color.RGBA{y, u, v, 255}); got != want {
+		t.Errorf("the red pixel is stored as %v, expected the Y, U and V channels %v", got, want)
+	}
+}
+
+func TestSubcommandFlags(t *testing.T) {
+	r, err := parseDetect([]string{"-in", "in.png", "-mmap", "-out", "out.png", "-bs", "8", "-metric", "manhattan", "-json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detect := r.(*detectCommand)
+	if detect.source != "in.png" || !detect.mmap || detect.output.destination != "out.png" || !detect.jsonOutput || detect.cfg.BlockSize != 8 || detect.cfg.Metric != Manhattan {
+		t.Errorf("unexpected detect arguments %+v, settings %+v", detect, detect.cfg)
+	}
+
+	r, err = parseEval([]string{"-in", "in.png", "-truth", "truth.png", "-dt", "0.2", "-json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval := r.(*evalCommand)
+	if eval.source != "in.png" || eval.truth != "truth.png" || !eval.jsonOutput || eval.cfg.DistanceThreshold != 0.2 {
+		t.Errorf("unexpected eval arguments %+v, settings %+v", eval, eval.cfg)
+	}
+
+	r, err = parseVisualize([]string{"-in", "in.png", "-out", "out.png", "-mode", "arrows", "-color", "#00ff00", "-thickness", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	visualize := r.(*visualizeCommand)
+	if visualize.source != "in.png" || visualize.mode != "arrows" || visualize.cfg.HighlightColor != (color.NRGBA{0, 255, 0, 255}) || visualize.cfg.LineThickness != 2 {
+		t.Errorf("unexpected visualize arguments %+v, settings %+v", visualize, visualize.cfg)
+	}
+
+	r, err = parseBatch([]string{"-bs", "6", "-timeout", "5s", "images"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := r.(*batchCommand)
+	if batch.dir != "images" || batch.timeout.Seconds() != 5 || batch.cfg.BlockSize != 6 {
+		t.Errorf("unexpected batch arguments %+v, settings %+v", batch, batch.cfg)
+	}
+
+	r, err = parseVersion([]string{"-json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.(*versionCommand).jsonOutput {
+		t.Error("expected the version to be requested as JSON")
+	}
+
+	// Each subcommand has its own flags and required arguments.
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{"batch", []string{"-out", "out.png", "images"}},
+		{"batch", nil},
+		{"version", []string{"-bs", "8"}},
+		{"eval", []string{"-in", "in.png"}},
+		{"eval", []string{"-in", "in.png", "-truth", "truth.png", "-mode", "arrows"}},
+		{"visualize", []string{"-in", "in.png", "-out", "out.png", "-mode", "gif"}},
+		{"visualize", []string{"-in", "in.png", "-out", "out.png", "-json"}},
+		{"detect", []string{"-in", "in.png", "-out", "out.png", "-metric", "cosine"}},
+		{"detect", []string{"-in", "in.png", "-out", "out.png", "-bs", "1"}},
+		{"detect", []string{"-benchmark-image", "512", "-out", "out.png"}},
+		{"detect", []string{"-benchmark-image", "512x512"}},
+	} {
+		if _, err := subcommand(c.name)(c.args); err == nil {
+			t.Errorf("expected the %s arguments %q to be rejected", c.name, c.args)
+		}
+	}
+	if subcommand("unknown") != nil {
+		t.Error("expected no unknown subcommand")
+	}
+}
+
+func TestEvalAndVisualizeCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, src, dst, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "forged.png")
+	if err := saveImage(path, img, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	truth := image.NewGray(img.Bounds())
+	draw.Draw(truth, src, &image.Uniform{color.White}, image.Point{}, draw.Src)
+	draw.Draw(truth, dst, &image.Uniform{color.White}, image.Point{}, draw.Src)
+	truthPath := filepath.Join(dir, "truth.png")
+	if err := saveImage(truthPath, truth, "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args string) []byte {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "FORENSIC_MAIN="+args)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("%s: the tool failed: %v\n%s", args, err, stderr.String())
+		}
+		return stdout.Bytes()
+	}
+
+	var e Evaluation
+	out := run("eval -in " + path + " -truth " + truthPath + " -json")
+	if err := json.Unmarshal(out, &e); err != nil {
+		t.Fatalf("the output is not a JSON evaluation: %v\n%s", err, out)
+	}
+	if !e.Forged || e.Recall < 0.25 || e.Precision < 0.5 {
+		t.Errorf("expected the copy to be detected with a precise mask, got %+v", e)
+	}
+
+	outPath := filepath.Join(dir, "out.png")
+	run("visualize -in " + path + " -out " + outPath + " -mode arrows")
+	if annotated, err := loadImage(outPath); err != nil || annotated.Bounds() != img.Bounds() {
+		t.Errorf("expected the annotated image of the size of the input, got %v", err)
+	}
+
+	// The saved result is visualized without running the detection again.
+	resultPath := filepath.Join(dir, "result.gob")
+	maskPath := filepath.Join(dir, "mask.png")
+	run("detect -in " + path + " -save " + resultPath)
+	run("visualize -in " + path + " -result " + resultPath + " -mask " + maskPath)
+	saved, err := LoadResult(resultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask, err := loadImage(maskPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := evaluateMask(saved.Mask, mask); err != nil || e.FalsePositives+e.FalseNegatives > 0 {
+		t.Errorf("expected the visualized mask to be the saved one, got %+v (%v)", e, err)
+	}
+}
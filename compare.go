@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"image"
+)
+
+// Comparison contains the differences between the detections of the same image with two configurations,
+// the baseline A and the changed B. A region of a detection is matched by a region of the other one when their
+// intersection over union is at least minRegionIoU.
+type Comparison struct {
+	// A and B are the results of the two detections.
+	A *Result `json:"-"`
+	B *Result `json:"-"`
+	// ForgedA and ForgedB are the verdicts of the two detections.
+	ForgedA bool `json:"forged_a"`
+	ForgedB bool `json:"forged_b"`
+	// Added are the regions of B matching no region of A.
+	Added []image.Rectangle `json:"added"`
+	// Removed are the regions of A matching no region of B.
+	Removed []image.Rectangle `json:"removed"`
+	// Unchanged are the regions of B matching a region of A.
+	Unchanged []image.Rectangle `json:"unchanged"`
+}
+
+// minRegionIoU is the minimum intersection over union of two matching regions. The regions are blocks of twice
+// the block size which overlap heavily, so any overlap would match most of the moved, grown or shrunk regions:
+// two regions of the same size match only when they are shifted along an axis by at most a third of their side.
+const minRegionIoU = 0.5
+
+// CompareDetections runs the detection of the image with both configurations and compares their regions.
+func CompareDetections(ctx context.Context, src image.Image, a, b Config) (*Comparison, error) {
+	resA, err := DetectContext(ctx, src, a)
+	if err != nil {
+		return nil, err
+	}
+	resB, err := DetectContext(ctx, src, b)
+	if err != nil {
+		return nil, err
+	}
+	return compareResults(resA, resB), nil
+}
+
+// compareResults compares the regions of the two results.
+func compareResults(a, b *Result) *Comparison {
+	c := &Comparison{A: a, B: b, ForgedA: a.Forged, ForgedB: b.Forged,
+		Added: []image.Rectangle{}, Removed: []image.Rectangle{}, Unchanged: []image.Rectangle{}}
+	for _, r := range b.Regions {
+		if matchesAny(r, a.Regions) {
+			c.Unchanged = append(c.Unchanged, r)
+		} else {
+			c.Added = append(c.Added, r)
+		}
+	}
+	for _, r := range a.Regions {
+		if !matchesAny(r, b.Regions) {
+			c.Removed = append(c.Removed, r)
+		}
+	}
+	return c
+}
+
+// matchesAny reports whether the region matches any of the regions.
+func matchesAny(r image.Rectangle, regions []image.Rectangle) bool {
+	for _, o := range regions {
+		if regionIoU(r, o) >= minRegionIoU {
+			return true
+		}
+	}
+	return false
+}
+
+// regionIoU returns the intersection over union of the two regions.
+func regionIoU(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	i := inter.Dx() * inter.Dy()
+	return float64(i) / float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()-i)
+}
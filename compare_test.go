@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	// The region shifted by a pixel is unchanged, the one shifted by half its side is moved.
+	a := &Result{Forged: true, Regions: []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(20, 0, 28, 8)}}
+	b := &Result{Regions: []image.Rectangle{image.Rect(1, 0, 9, 8), image.Rect(24, 4, 32, 12), image.Rect(40, 40, 48, 48)}}
+	cmp := compareResults(a, b)
+	if !cmp.ForgedA || cmp.ForgedB {
+		t.Errorf("unexpected verdicts %v, %v", cmp.ForgedA, cmp.ForgedB)
+	}
+	if want := []image.Rectangle{image.Rect(24, 4, 32, 12), image.Rect(40, 40, 48, 48)}; !reflect.DeepEqual(cmp.Added, want) {
+		t.Errorf("added %v, expected %v", cmp.Added, want)
+	}
+	if want := []image.Rectangle{image.Rect(20, 0, 28, 8)}; !reflect.DeepEqual(cmp.Removed, want) {
+		t.Errorf("removed %v, expected %v", cmp.Removed, want)
+	}
+	if want := []image.Rectangle{image.Rect(1, 0, 9, 8)}; !reflect.DeepEqual(cmp.Unchanged, want) {
+		t.Errorf("unchanged %v, expected %v", cmp.Unchanged, want)
+	}
+
+	// A grown or shrunk region is changed as well.
+	cmp = compareResults(a, &Result{Regions: []image.Rectangle{image.Rect(0, 0, 16, 16), image.Rect(22, 2, 26, 6)}})
+	if len(cmp.Unchanged) != 0 || len(cmp.Added) != 2 || len(cmp.Removed) != 2 {
+		t.Errorf("unexpected comparison of the resized regions: %+v", cmp)
+	}
+
+	// Comparing a result with itself leaves every region unchanged.
+	cmp = compareResults(a, a)
+	if len(cmp.Added) != 0 || len(cmp.Removed) != 0 || len(cmp.Unchanged) != len(a.Regions) {
+		t.Errorf("unexpected comparison of a result with itself: %+v", cmp)
+	}
+}
+
+func TestCompareSensitivity(t *testing.T) {
+	// The large copy is matched by enough blocks for both sensitivities, the small one only for the high one.
+	img := randomNRGBA(128, 128, 1)
+	copyPatch(img, image.Pt(4, 4), image.Pt(60, 20), 24, func(v uint8) uint8 { return v })
+	copyPatch(img, image.Pt(10, 80), image.Pt(90, 100), 12, func(v uint8) uint8 { return v })
+	low, high := DefaultConfig, DefaultConfig
+	applySensitivity(&low, 0.1)
+	applySensitivity(&high, 0.9)
+	cmp, err := CompareDetections(context.Background(), img, low, high)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The more sensitive detection finds every region of the less sensitive one, and more.
+	if len(cmp.Removed) != 0 {
+		t.Errorf("the high sensitivity removed %d regions", len(cmp.Removed))
+	}
+	if len(cmp.Added) == 0 || len(cmp.Unchanged) != len(cmp.A.Regions) || len(cmp.B.Regions) <= len(cmp.A.Regions) {
+		t.Errorf("the high sensitivity detected %d regions, the low %d: %d added, %d unchanged",
+			len(cmp.B.Regions), len(cmp.A.Regions), len(cmp.Added), len(cmp.Unchanged))
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// BlockConfidence is the confidence of a block being part of a copied region, between 0 and 1.
+type BlockConfidence struct {
+	// Block is the block rectangle in the original image space.
+	Block      image.Rectangle
+	Confidence float64
+}
+
+// supportKey identifies a matched block pair by the position of its source block and its shift vector.
+type supportKey struct {
+	x, y int
+	offset
+}
+
+// blockConfidence grades the blocks of the suspicious pairs by their match support: the fraction of
+// the neighboring block positions, within the block size, matched with a shift vector of the same accumulator bin.
+// The blocks inside a large copied region are surrounded by consistent matches and score close to 1,
+// while the blocks on the region edges and the isolated coincidental matches score lower.
+// Both blocks of a pair receive the confidence of the pair, and a block shared by several pairs keeps the highest one.
+func blockConfidence(vect []vector, cfg Config) map[image.Point]float64 {
+	pairs := make(map[supportKey]bool, len(vect))
+	for _, v := range vect {
+		pairs[supportKey{v.xa, v.ya, cfg.shiftBin(v)}] = true
+	}
+
+	step := cfg.blockStep()
+	radius := cfg.BlockSize / step
+	total := float64((2*radius + 1) * (2*radius + 1))
+
+	conf := make(map[image.Point]float64)
+	for k := range pairs {
+		var support int
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				if pairs[supportKey{k.x + dx*step, k.y + dy*step, k.offset}] {
+					support++
+				}
+			}
+		}
+		c := float64(support) / total
+		a := image.Pt(k.x, k.y)
+		conf[a] = math.Max(conf[a], c)
+	}
+	// The destination blocks share the confidence of their source blocks.
+	for _, v := range vect {
+		c := conf[image.Pt(v.xa, v.ya)]
+		b := image.Pt(v.xb, v.yb)
+		conf[b] = math.Max(conf[b], c)
+	}
+	return conf
+}
+
+// confidenceMap converts the block confidences into the original image space, ordered by the block positions.
+func confidenceMap(conf map[image.Point]float64, blockSize int, scale float64, origin image.Point) []BlockConfidence {
+	blocks := make([]BlockConfidence, 0, len(conf))
+	rects := make([]image.Rectangle, 0, len(conf))
+	for p := range conf {
+		rects = append(rects, image.Rect(p.X, p.Y, p.X+blockSize, p.Y+blockSize))
+	}
+	sortRegions(rects)
+	for _, r := range rects {
+		blocks = append(blocks, BlockConfidence{
+			Block:      scaleRect(r, scale).Add(origin),
+			Confidence: conf[r.Min],
+		})
+	}
+	return blocks
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBlockConfidence(t *testing.T) {
+	// A 10x10 grid of the blocks matched with the same shift, and an isolated match with another shift.
+	var vect []vector
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			vect = append(vect, vector{xa: x, ya: y, xb: x + 50, yb: y, offsetX: 50})
+		}
+	}
+	vect = append(vect, vector{xa: 5, ya: 5, xb: 5, yb: 80, offsetY: 75})
+	cfg := DefaultConfig
+	conf := blockConfidence(vect, cfg)
+
+	// The 9x9 neighborhood of the center block is fully matched, but only 5x5 of the corner block's.
+	if c := conf[image.Pt(5, 5)]; c != 1 {
+		t.Errorf("the center block has the confidence %v, expected 1", c)
+	}
+	if c, want := conf[image.Pt(0, 0)], 25.0/81; c != want {
+		t.Errorf("the corner block has the confidence %v, expected %v", c, want)
+	}
+	// The destination blocks share the confidence of their sources, and the isolated match keeps the highest one.
+	if c := conf[image.Pt(55, 5)]; c != 1 {
+		t.Errorf("the destination of the center block has the confidence %v, expected 1", c)
+	}
+	if c, want := conf[image.Pt(5, 80)], 1.0; c != want {
+		t.Errorf("the destination of the isolated match has the confidence %v, expected the confidence %v of its source", c, want)
+	}
+}
+
+func TestDetectConfidenceCenterAboveEdge(t *testing.T) {
+	img, src, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := src.Inset(src.Dx() / 4)
+	var center, edge []float64
+	for _, b := range res.Confidence {
+		if b.Confidence < 0 || b.Confidence > 1 {
+			t.Fatalf("the block %v has the confidence %v, outside of the 0-1 range", b.Block, b.Confidence)
+		}
+		switch p := b.Block.Min; {
+		case p.In(inner):
+			center = append(center, b.Confidence)
+		case p.In(src) && !p.In(src.Inset(DefaultConfig.BlockSize)):
+			edge = append(edge, b.Confidence)
+		}
+	}
+	mean := func(values []float64) float64 {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+	if len(center) == 0 || len(edge) == 0 {
+		t.Fatalf("got %d center and %d edge blocks of the copied region", len(center), len(edge))
+	}
+	if c, e := mean(center), mean(edge); c <= e {
+		t.Errorf("the center blocks of the copied region have the mean confidence %v, not higher than %v of the edge blocks", c, e)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// loadConfigFile sets the flags from the JSON object of the configuration file, keyed by the flag names,
+// like {"bs": 8, "dt": 0.3, "features": "dct,sobel"}. The values are parsed by the flags themselves, so
+// they are validated exactly like the command line arguments. The flags already set on the command line
+// override the values of the file. Only the allowed flags can be set, the other names are reported as invalid
+// configuration.
+func loadConfigFile(fs *flag.FlagSet, allowed map[string]bool, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values map[string]interface{}
+	if err := dec.Decode(&values); err != nil {
+		return invalidConfig("%s: %v", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// Apply the values in a stable order, so the first invalid value is always the one reported.
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !allowed[name] || fs.Lookup(name) == nil {
+			return invalidConfig("%s: unknown field %q", path, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		var value string
+		switch v := values[name].(type) {
+		case string:
+			value = v
+		case json.Number, bool:
+			value = fmt.Sprint(v)
+		default:
+			return invalidConfig("%s: the field %q must be a string, a number or a boolean", path, name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return invalidConfig("%s: invalid value %q for the field %q: %v", path, value, name, err)
+		}
+	}
+	return nil
+}
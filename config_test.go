@@ -0,0 +1,128 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigFile writes the configuration file into the directory and returns its path.
+func writeConfigFile(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "settings.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newFlags := func() (*flag.FlagSet, *int, *float64, *string, *bool) {
+		fs := flag.NewFlagSet("forensic", flag.ContinueOnError)
+		fs.String("out", "", "")
+		return fs, fs.Int("bs", 4, ""), fs.Float64("dt", 0.4, ""), fs.String("features", "dct", ""), fs.Bool("adaptive", false, "")
+	}
+	names := map[string]bool{"bs": true, "dt": true, "features": true, "adaptive": true}
+
+	fs, bs, dt, features, adaptive := newFlags()
+	if err := fs.Parse([]string{"-dt", "0.2"}); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfigFile(t, dir, `{"bs": 8, "dt": 0.3, "features": "dct,sobel", "adaptive": true}`)
+	if err := loadConfigFile(fs, names, path); err != nil {
+		t.Fatal(err)
+	}
+	if *bs != 8 || *features != "dct,sobel" || !*adaptive {
+		t.Errorf("the file values are not applied: bs %d, features %q, adaptive %v", *bs, *features, *adaptive)
+	}
+	if *dt != 0.2 {
+		t.Errorf("expected the command line distance threshold 0.2 to override the file, got %v", *dt)
+	}
+
+	for _, content := range []string{
+		`{"bs": 8, "blocksize": 8}`,
+		`{"bs": "large"}`,
+		`{"bs": [8]}`,
+		`{"config": "other.json"}`,
+		`{"out": "forged.png"}`,
+		`{"bs": 8`,
+	} {
+		fs, _, _, _, _ := newFlags()
+		if err := loadConfigFile(fs, names, writeConfigFile(t, dir, content)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected the configuration %s to be invalid, got %v", content, err)
+		}
+	}
+}
+
+func TestConfigFileDetection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "forged.png")
+	if err := saveImage(path, img, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	// The copy is reported only when the file doesn't require an unreachable number of forged blocks.
+	cfgPath := writeConfigFile(t, dir, `{"minblocks": 100000}`)
+
+	run := func(args string) (Result, error) {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "FORENSIC_MAIN=-json -in "+path+" -config "+cfgPath+args)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		var res Result
+		if err := cmd.Run(); err != nil {
+			return res, errors.New(stderr.String())
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+			t.Fatalf("the standard output is not a JSON result: %v\n%s", err, stdout.String())
+		}
+		return res, nil
+	}
+
+	res, err := run("")
+	if err != nil {
+		t.Fatalf("the tool failed: %v", err)
+	}
+	if res.Forged {
+		t.Error("expected the minimum number of forged blocks of the file to reject the copy")
+	}
+	res, err = run(" -minblocks 2")
+	if err != nil {
+		t.Fatalf("the tool failed: %v", err)
+	}
+	if !res.Forged {
+		t.Error("expected the command line minimum number of forged blocks to override the file and report the copy")
+	}
+
+	writeConfigFile(t, dir, `{"ncc": 2}`)
+	if _, err := run(""); err == nil {
+		t.Error("expected the invalid NCC threshold of the file to be rejected")
+	}
+	// The file holds the detection settings only, not the flags of the subcommand.
+	writeConfigFile(t, dir, `{"timeout": "1ms"}`)
+	if _, err := run(""); err == nil {
+		t.Error("expected the timeout of the file to be rejected")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// blockDescriptors groups the flat features into the feature vectors of their blocks, standardized
+// to zero mean and unit variance, so the Pearson correlation of two descriptors is the mean product of their values.
+// The features of each block are expected to be stored contiguously, dims values per block.
+// The constant descriptors, like the ones of the flat blocks, have no defined correlation and are skipped.
+func blockDescriptors(features []feature, dims int) []blockFeature {
+	if dims < 2 {
+		return nil
+	}
+	blocks := groupFeatures(features, dims)
+	descs := blocks[:0]
+	for _, b := range blocks {
+		var sum, sqSum float64
+		for _, v := range b.values {
+			sum += v
+			sqSum += v * v
+		}
+		mean := sum / float64(dims)
+		std := math.Sqrt(math.Max(sqSum/float64(dims)-mean*mean, 0))
+		if std < 1e-9 {
+			continue
+		}
+		for j, v := range b.values {
+			b.values[j] = (v - mean) / std
+		}
+		descs = append(descs, b)
+	}
+	return descs
+}
+
+// correlation returns the Pearson correlation coefficient of two standardized descriptors.
+func correlation(a, b blockFeature) float64 {
+	var dot float64
+	for i, v := range a.values {
+		dot += v * b.values[i]
+	}
+	return dot / float64(len(a.values))
+}
+
+// matchCorrelated returns the shift vectors between the blocks whose descriptors are correlated at least
+// by the correlation threshold. The standardized descriptors of the proportional feature vectors are identical,
+// so they are adjacent in the lexicographic order, and only the neighboring descriptors within the match window are compared.
+func matchCorrelated(features []feature, dims int, cfg Config, stats *pipelineStats) []vector {
+	var vectors []vector
+	descs := blockDescriptors(features, dims)
+
+	start := time.Now()
+	sort.Sort(blockVec(descs))
+	stats.add(statsSort, start, len(descs))
+
+	start = time.Now()
+	pairs := len(descs) - 1
+	workers := cfg.workers()
+	if workers == 1 || pairs < workers {
+		vectors = compareCorrelated(vectors, descs, 0, pairs, cfg)
+		stats.add(statsMatch, start, len(vectors))
+		return vectors
+	}
+
+	// The descriptors are split between the workers like the features of matchSorted, so the vectors
+	// are identical to the vectors of the sequential comparison.
+	parts := make([][]vector, workers)
+	chunk := (pairs + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > pairs {
+			hi = pairs
+		}
+		if lo >= hi {
+			break
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			parts[w] = compareCorrelated(nil, descs, lo, hi, cfg)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	for _, part := range parts {
+		vectors = append(vectors, part...)
+	}
+	stats.add(statsMatch, start, len(vectors))
+	return vectors
+}
+
+// compareCorrelated compares the sorted descriptors of the indices between lo and hi with the descriptors following them
+// within the match window, and appends the shift vectors of the correlated blocks to vectors.
+func compareCorrelated(vectors []vector, descs []blockFeature, lo, hi int, cfg Config) []vector {
+	window := cfg.matchWindow()
+	for i := lo; i < hi; i++ {
+		for j := i + 1; j <= i+window && j < len(descs); j++ {
+			a, b := descs[i], descs[j]
+			// The mirrored blocks are matched only with the unmirrored blocks.
+			if a.flip != NoFlip && b.flip != NoFlip {
+				continue
+			}
+			if correlation(a, b) < cfg.CorrelationThreshold {
+				continue
+			}
+			if v := shiftVector(a.feature, b.feature, cfg); v != nil {
+				vectors = append(vectors, *v)
+			}
+		}
+	}
+	return vectors
+}
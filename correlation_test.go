@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCorrelationScaledFeatures(t *testing.T) {
+	base := []float64{3, 7, 1, 9, 4}
+	var feats []feature
+	for _, v := range base {
+		feats = append(feats, feature{x: 0, y: 0, coef: v})
+	}
+	// The features of the second block are proportional to the first ones, scaled and offset.
+	for _, v := range base {
+		feats = append(feats, feature{x: 40, y: 30, coef: 2.5*v + 1})
+	}
+	// The features of the third block are a permutation of the first ones, with the same distribution.
+	for _, v := range []float64{9, 1, 4, 3, 7} {
+		feats = append(feats, feature{x: 80, y: 0, coef: v})
+	}
+	// The flat block has no defined correlation.
+	for range base {
+		feats = append(feats, feature{x: 120, y: 0, coef: 5})
+	}
+
+	descs := blockDescriptors(feats, len(base))
+	if len(descs) != 3 {
+		t.Fatalf("expected the descriptors of the 3 textured blocks, got %d", len(descs))
+	}
+	if c := correlation(descs[0], descs[1]); c < 0.999 {
+		t.Errorf("expected the scaled features to be correlated, got %v", c)
+	}
+	if c := correlation(descs[0], descs[2]); c > 0.5 {
+		t.Errorf("expected the permuted features to be uncorrelated, got %v", c)
+	}
+
+	cfg := DefaultConfig
+	cfg.CorrelationThreshold = 0.99
+	vectors := matchCorrelated(feats, len(base), cfg, nil)
+	if len(vectors) != 1 {
+		t.Fatalf("expected a single match, got %v", vectors)
+	}
+	if v := vectors[0]; v.xa != 0 || v.ya != 0 || v.xb != 40 || v.yb != 30 {
+		t.Errorf("expected the scaled block to match the first one, got %+v", v)
+	}
+}
+
+func TestDetectCorrelationContrastCopy(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Round the gray pixels to even values, so the halved contrast of the copy scales its luminance exactly.
+	for i := 0; i < len(img.Pix); i += 4 {
+		g := img.Pix[i+1] &^ 1
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2] = g, g, g
+	}
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		for x := dst.Min.X; x < dst.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i] /= 2
+			img.Pix[i+1] /= 2
+			img.Pix[i+2] /= 2
+		}
+	}
+
+	// The blur would round the scaled pixels.
+	cfg := DefaultConfig
+	cfg.BlurRadius = 0
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged {
+		t.Fatal("expected the copy of reduced contrast to be missed by the feature distance")
+	}
+
+	cfg.CorrelationThreshold = 0.99
+	res, err = Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift := dst.Min.Sub(src.Min)
+	if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("expected the copy of reduced contrast to be detected by the correlation with the shift %v, got %+v", shift, res.Shifts)
+	}
+}
+
+func TestMatchCorrelatedParallel(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Features = FeatureDCT | FeatureMeanVar
+	cfg.CorrelationThreshold = 0.9
+	feats := extractedFeatures(t, cfg)
+	dims := cfg.featureDims()
+
+	for _, window := range []int{1, 3} {
+		cfg.Workers, cfg.MatchWindow = 1, window
+		want := matchCorrelated(feats, dims, cfg, nil)
+		if len(want) == 0 {
+			t.Fatal("expected shift vectors from the sequential matching")
+		}
+
+		for _, workers := range []int{2, 7, 16} {
+			cfg.Workers = workers
+			got := matchCorrelated(feats, dims, cfg, nil)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("window %d, %d workers: got %d vectors, different from the %d sequential vectors", window, workers, len(got), len(want))
+			}
+		}
+	}
+}
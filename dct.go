@@ -0,0 +1,205 @@
+package main
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// fftDCTMinSize is the smallest block size for which blockDCT computes the DCT through the FFT, measured by
+// BenchmarkDCT: for the 2x2 blocks the overhead of the FFT outweighs the direct computation, while from the 4x4
+// blocks the direct computation is several times slower, and the gap widens with the block size. The blocks of the
+// features are transformed by separableDCT instead, which BenchmarkDCT measures faster than the FFT up to the 64x64
+// blocks, so blockDCT, and the FFT with it, only transform the perceptual hash signatures.
+const fftDCTMinSize = 4
+
+// defaultLowFreqCount is the default number of the low frequency luminance DCT coefficients of the block features:
+// the DC coefficient and the two lowest frequency AC coefficients.
+const defaultLowFreqCount = 3
+
+// blockDCT computes the orthonormal two dimensional DCT of an n×n block stored in row major order.
+// The coefficient of the u horizontal and v vertical frequency is stored at index v*n+u.
+// The FFT is used for the power of two block sizes from fftDCTMinSize, otherwise the DCT is computed directly.
+// The blocks of the features, which are transformed in bulk, use the faster separableDCT.
+func blockDCT(block []float64, n int) []float64 {
+	if n >= fftDCTMinSize && n&(n-1) == 0 {
+		return fftDCT(block, n)
+	}
+	return directDCT(block, n)
+}
+
+// dctBases caches the DCT basis of each block size, shared by the extraction workers.
+var dctBases sync.Map
+
+// dctBasis returns the orthonormal DCT basis of the n×n blocks in row major order, where the row u
+// holds the samples of the cosine of the u frequency, scaled by its orthonormal factor.
+func dctBasis(n int) []float64 {
+	if basis, ok := dctBases.Load(n); ok {
+		return basis.([]float64)
+	}
+	basis := make([]float64, n*n)
+	for u := 0; u < n; u++ {
+		for x := 0; x < n; x++ {
+			basis[u*n+x] = dctAlpha(u, n) * math.Cos(float64(2*x+1)*float64(u)*math.Pi/float64(2*n))
+		}
+	}
+	dctBases.Store(n, basis)
+	return basis
+}
+
+// separableDCT computes the orthonormal two dimensional DCT of the n×n block stored in row major order into coefs,
+// laid out like the coefficients of blockDCT, with tmp holding the n×n intermediate products. The rows and then
+// the columns are multiplied by the cached basis, so the inner loops run over contiguous flat slices, without
+// the cosine evaluations and the allocations of the other transforms, which lets the compiler keep them tight.
+// The coefficients are rounded differently than by the FFT in their last bits, which decides whether the equal
+// coefficients of different blocks are the same float, and so the order of the sorted features: the features of
+// the 8-bit blocks tie less often, and the identical features of a copy end up next to each other more often.
+func separableDCT(block, coefs, tmp []float64, n int) {
+	basis := dctBasis(n)
+	for y := 0; y < n; y++ {
+		row := block[y*n : y*n+n]
+		for u := 0; u < n; u++ {
+			b := basis[u*n : u*n+n]
+			var sum float64
+			for x, p := range row {
+				sum += p * b[x]
+			}
+			tmp[y*n+u] = sum
+		}
+	}
+	for v := 0; v < n; v++ {
+		b := basis[v*n : v*n+n]
+		out := coefs[v*n : v*n+n]
+		for u := range out {
+			out[u] = 0
+		}
+		for y, w := range b {
+			for u, t := range tmp[y*n : y*n+n] {
+				out[u] += w * t
+			}
+		}
+	}
+}
+
+// zigzag returns the first count frequencies of an n×n block in the zigzag order, from the lowest to the highest,
+// as points of the u horizontal and v vertical frequency. The anti-diagonals of equal u+v are traversed
+// alternately, starting with the DC coefficient followed by the lowest vertical frequency.
+func zigzag(n, count int) []image.Point {
+	freqs := make([]image.Point, 0, count)
+	for d := 0; d <= 2*(n-1) && len(freqs) < count; d++ {
+		lo, hi := 0, d
+		if d >= n {
+			lo, hi = d-n+1, n-1
+		}
+		for i := lo; i <= hi && len(freqs) < count; i++ {
+			u := i
+			if d%2 == 0 {
+				u = lo + hi - i
+			}
+			freqs = append(freqs, image.Pt(u, d-u))
+		}
+	}
+	return freqs
+}
+
+// dctAlpha returns the orthonormal DCT scale factor of a frequency.
+func dctAlpha(u, n int) float64 {
+	if u == 0 {
+		return math.Sqrt(1.0 / float64(n))
+	}
+	return math.Sqrt(2.0 / float64(n))
+}
+
+// directDCT computes the two dimensional DCT of the block by summing the cosine basis functions.
+func directDCT(block []float64, n int) []float64 {
+	coefs := make([]float64, n*n)
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += block[y*n+x] * dct(float64(x), float64(y), float64(u), float64(v), float64(n))
+				}
+			}
+			coefs[v*n+u] = sum * dctAlpha(u, n) * dctAlpha(v, n)
+		}
+	}
+	return coefs
+}
+
+// fftDCT computes the two dimensional DCT of the block as separable one dimensional DCTs
+// over the rows and the columns, each of them computed through an FFT of the same length.
+func fftDCT(block []float64, n int) []float64 {
+	coefs := make([]float64, n*n)
+	line := make([]float64, n)
+
+	for y := 0; y < n; y++ {
+		copy(line, block[y*n:(y+1)*n])
+		copy(coefs[y*n:(y+1)*n], fftDCT1(line))
+	}
+	for u := 0; u < n; u++ {
+		for y := 0; y < n; y++ {
+			line[y] = coefs[y*n+u]
+		}
+		col := fftDCT1(line)
+		for v := 0; v < n; v++ {
+			coefs[v*n+u] = col[v]
+		}
+	}
+	return coefs
+}
+
+// fftDCT1 computes the orthonormal one dimensional DCT of a power of two length sequence using Makhoul's method:
+// the even samples followed by the reversed odd samples are transformed with an FFT, then the result
+// is rotated by a quarter sample phase.
+func fftDCT1(x []float64) []float64 {
+	n := len(x)
+	re, im := make([]float64, n), make([]float64, n)
+	for k := 0; k < n/2; k++ {
+		re[k] = x[2*k]
+		re[n-1-k] = x[2*k+1]
+	}
+	fft(re, im)
+
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		a := -math.Pi * float64(k) / float64(2*n)
+		out[k] = (re[k]*math.Cos(a) - im[k]*math.Sin(a)) * dctAlpha(k, n)
+	}
+	return out
+}
+
+// fft computes in place the discrete Fourier transform of a power of two length
+// complex sequence, using the iterative radix-2 Cooley-Tukey algorithm.
+func fft(re, im []float64) {
+	n := len(re)
+
+	// Reorder the samples in the bit reversed order.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		a := -2 * math.Pi / float64(size)
+		wr, wi := math.Cos(a), math.Sin(a)
+		for start := 0; start < n; start += size {
+			cr, ci := 1.0, 0.0
+			for k := 0; k < size/2; k++ {
+				i, j := start+k, start+k+size/2
+				tr := re[j]*cr - im[j]*ci
+				ti := re[j]*ci + im[j]*cr
+				re[j], im[j] = re[i]-tr, im[i]-ti
+				re[i], im[i] = re[i]+tr, im[i]+ti
+				cr, ci = cr*wr-ci*wi, cr*wi+ci*wr
+			}
+		}
+	}
+}
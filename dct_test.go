@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomBlock returns an n×n block of random pixel values.
+func randomBlock(n int, seed int64) []float64 {
+	rnd := rand.New(rand.NewSource(seed))
+	block := make([]float64, n*n)
+	for i := range block {
+		block[i] = rnd.Float64() * 255
+	}
+	return block
+}
+
+func TestFFTDCTMatchesDirect(t *testing.T) {
+	for _, n := range []int{2, 4, 8, 16, 32, 64} {
+		block := randomBlock(n, int64(n))
+		direct, fast := directDCT(block, n), fftDCT(block, n)
+		for i := range direct {
+			if math.Abs(direct[i]-fast[i]) > 1e-9*math.Max(1, math.Abs(direct[i])) {
+				t.Fatalf("%dx%d: coefficient %d is %v through the FFT, %v directly", n, n, i, fast[i], direct[i])
+			}
+		}
+	}
+}
+
+func TestBlockDCTSelection(t *testing.T) {
+	for _, n := range []int{3, 4, 5, 8, 12, 16, 32} {
+		block := randomBlock(n, 1)
+		got, want := blockDCT(block, n), directDCT(block, n)
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-9*math.Max(1, math.Abs(want[i])) {
+				t.Fatalf("%dx%d: coefficient %d is %v, expected %v", n, n, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// referencePlaneCoefs computes the quantized coefficients of the planes like blockPlaneCoefs,
+// transforming each plane separately with blockDCT.
+func referencePlaneCoefs(n int, planes ...[]float64) []float64 {
+	var coefs []float64
+	for _, plane := range planes {
+		c := blockDCT(plane, n)
+		for i := range c {
+			if n <= 4 {
+				c[i] /= q4x4[i%n][i/n]
+			}
+		}
+		coefs = append(coefs, c...)
+	}
+	return coefs
+}
+
+func TestSeparableDCTMatchesReference(t *testing.T) {
+	for _, n := range []int{2, 3, 4, 5, 8, 16, 32} {
+		planes := [][]float64{randomBlock(n, 1), randomBlock(n, 2), randomBlock(n, 3), randomBlock(n, 4)}
+		got, want := blockPlaneCoefs(n, planes[0], planes[1], planes[2], planes[3]), referencePlaneCoefs(n, planes...)
+		if len(got) != len(want) {
+			t.Fatalf("%dx%d: got %d coefficients, expected %d", n, n, len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-9*math.Max(1, math.Abs(want[i])) {
+				t.Fatalf("%dx%d: coefficient %d of the plane %d is %v, expected %v", n, n, i%(n*n), i/(n*n), got[i], want[i])
+			}
+		}
+	}
+}
+
+// BenchmarkDCT compares the direct, the FFT based and the separable DCT of the power of two block sizes,
+// showing the block size from which the FFT is faster than the direct DCT, and that the separable one is faster still.
+func BenchmarkDCT(b *testing.B) {
+	for _, n := range []int{2, 4, 8, 16, 32, 64} {
+		block := randomBlock(n, 1)
+		for _, t := range []struct {
+			name      string
+			transform func([]float64, int) []float64
+		}{
+			{"direct", directDCT},
+			{"fft", fftDCT},
+			{"separable", func(block []float64, n int) []float64 {
+				coefs := make([]float64, n*n)
+				separableDCT(block, coefs, make([]float64, n*n), n)
+				return coefs
+			}},
+		} {
+			b.Run(fmt.Sprintf("%s/%d", t.name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					t.transform(block, n)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBlockPlaneCoefs compares the transform of the Y,R,G,B planes of a block over the flat buffers
+// with the separate transforms of each plane.
+func BenchmarkBlockPlaneCoefs(b *testing.B) {
+	for _, n := range []int{4, 8, 16} {
+		planes := [][]float64{randomBlock(n, 1), randomBlock(n, 2), randomBlock(n, 3), randomBlock(n, 4)}
+		b.Run(fmt.Sprintf("planes/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				referencePlaneCoefs(n, planes...)
+			}
+		})
+		b.Run(fmt.Sprintf("flat/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				blockPlaneCoefs(n, planes[0], planes[1], planes[2], planes[3])
+			}
+		})
+	}
+}
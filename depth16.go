@@ -0,0 +1,140 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// The 16-bit images are analyzed in a separate YUV image with 16 bits per channel, which preserves
+// the low order bits discarded by the 8-bit pipeline through the blur, the YUV conversion and the DCT.
+// The 16-bit YUV image takes 8 bytes per pixel of the downscaled image, in addition to the 4 bytes
+// per pixel of the 8-bit YUV image, which is still used by the other block descriptors and the verification.
+
+// yuvOffset16 is the chrominance offset of the 16-bit YUV values.
+const yuvOffset16 = 128 * 257
+
+// is16Bit reports whether the image stores 16 bits per color channel.
+func is16Bit(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64:
+		return true
+	}
+	return false
+}
+
+// toRGBA64 converts the image to an RGBA64 image having its origin at 0,0.
+func toRGBA64(img image.Image) *image.RGBA64 {
+	b := img.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// blur16 applies the same triangular blur as StackBlur on a 16-bit image, without rounding the intermediate values.
+func blur16(img *image.RGBA64, radius int) *image.RGBA64 {
+	if radius < 1 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	weights := make([]float64, 2*radius+1)
+	var sum float64
+	for i := range weights {
+		weights[i] = float64(radius+1) - math.Abs(float64(i-radius))
+		sum += weights[i]
+	}
+
+	// The horizontal pass is kept in floating point for the vertical pass.
+	tmp := make([]float64, w*h*3)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var rs, gs, bs float64
+			for k, wt := range weights {
+				i := img.PixOffset(clampInt(x+k-radius, 0, w-1), y)
+				rs += wt * float64(uint16(img.Pix[i+0])<<8|uint16(img.Pix[i+1]))
+				gs += wt * float64(uint16(img.Pix[i+2])<<8|uint16(img.Pix[i+3]))
+				bs += wt * float64(uint16(img.Pix[i+4])<<8|uint16(img.Pix[i+5]))
+			}
+			j := (y*w + x) * 3
+			tmp[j], tmp[j+1], tmp[j+2] = rs/sum, gs/sum, bs/sum
+		}
+	}
+
+	dst := image.NewRGBA64(b)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var c [3]float64
+			for k, wt := range weights {
+				j := (clampInt(y+k-radius, 0, h-1)*w + x) * 3
+				c[0] += wt * tmp[j]
+				c[1] += wt * tmp[j+1]
+				c[2] += wt * tmp[j+2]
+			}
+			i := dst.PixOffset(b.Min.X+x, b.Min.Y+y)
+			for ch := 0; ch < 3; ch++ {
+				v := uint16(math.Min(math.Round(c[ch]/sum), 0xffff))
+				dst.Pix[i+2*ch], dst.Pix[i+2*ch+1] = uint8(v>>8), uint8(v)
+			}
+			dst.Pix[i+6], dst.Pix[i+7] = 0xff, 0xff
+		}
+	}
+	return dst
+}
+
+// convertRGBImageToYUV16 converts the 16-bit image from RGB to YUV color space, using the same JFIF conversion
+// as the 8-bit pipeline. The luminance and the chrominance are stored in the red, green and blue components.
+func convertRGBImageToYUV16(img *image.RGBA64) *image.RGBA64 {
+	b := img.Bounds()
+	dst := image.NewRGBA64(b)
+	for i := 0; i < len(img.Pix); i += 8 {
+		r := float64(uint16(img.Pix[i+0])<<8 | uint16(img.Pix[i+1]))
+		g := float64(uint16(img.Pix[i+2])<<8 | uint16(img.Pix[i+3]))
+		bl := float64(uint16(img.Pix[i+4])<<8 | uint16(img.Pix[i+5]))
+
+		yc := 0.299*r + 0.587*g + 0.114*bl
+		cb := yuvOffset16 - 0.168736*r - 0.331264*g + 0.5*bl
+		cr := yuvOffset16 + 0.5*r - 0.418688*g - 0.081312*bl
+		for ch, v := range [3]float64{yc, cb, cr} {
+			u := uint16(math.Max(0, math.Min(math.Round(v), 0xffff)))
+			dst.Pix[i+2*ch], dst.Pix[i+2*ch+1] = uint8(u>>8), uint8(u)
+		}
+		dst.Pix[i+6], dst.Pix[i+7] = 0xff, 0xff
+	}
+	return dst
+}
+
+// dctFeatures16 computes the DCT features of the 16-bit YUV block having its top left corner at bx, by.
+// The planes are scaled to the 8-bit range, so the features are comparable with the thresholds of the 8-bit
+// pipeline, but they keep the fractional precision of the 16-bit values.
+func dctFeatures16(img *image.RGBA64, bx, by int, blockSize, lowFreq int) ([]feature, float64) {
+	size := blockSize * blockSize
+	yPlane, rPlane, gPlane, bPlane := make([]float64, size), make([]float64, size), make([]float64, size), make([]float64, size)
+
+	var avr, avg, avb float64
+	for y := 0; y < blockSize; y++ {
+		i := img.PixOffset(bx, by+y)
+		for x := 0; x < blockSize; x++ {
+			yc := float64(uint16(img.Pix[i+0])<<8 | uint16(img.Pix[i+1]))
+			cb := float64(uint16(img.Pix[i+2])<<8|uint16(img.Pix[i+3])) - yuvOffset16
+			cr := float64(uint16(img.Pix[i+4])<<8|uint16(img.Pix[i+5])) - yuvOffset16
+
+			r := math.Max(0, math.Min(yc+1.402*cr, 0xffff)) / 257
+			g := math.Max(0, math.Min(yc-0.344136*cb-0.714136*cr, 0xffff)) / 257
+			bl := math.Max(0, math.Min(yc+1.772*cb, 0xffff)) / 257
+
+			j := y*blockSize + x
+			yPlane[j], rPlane[j], gPlane[j], bPlane[j] = yc/257, r, g, bl
+			avr += r
+			avg += g
+			avb += bl
+			i += 8
+		}
+	}
+	avr /= float64(size)
+	avg /= float64(size)
+	avb /= float64(size)
+
+	return planeDCTFeatures(bx, by, blockSize, lowFreq, yPlane, rPlane, gPlane, bPlane, avr, avg, avb)
+}
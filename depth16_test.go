@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestDepth16Precision(t *testing.T) {
+	// The gradient rises by 20 per pixel in 16 bits, which is below a single 8-bit step over a block.
+	g := image.NewRGBA64(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			v := uint16(30000 + 20*x)
+			g.SetRGBA64(x, y, color.RGBA64{v, v, v, 0xffff})
+		}
+	}
+	const n = 4
+	// The mean luminance of the second block is higher by 4*20 in 16 bits, and the DC coefficient of a 4x4 block
+	// is 4 times its mean, divided by the quantization of the DC coefficient.
+	want := 4 * (4 * 20.0 / 257) / q4x4[0][0]
+
+	yuv16 := convertRGBImageToYUV16(g)
+	a16, _ := dctFeatures16(yuv16, 0, 0, n, defaultLowFreqCount)
+	b16, _ := dctFeatures16(yuv16, 4, 0, n, defaultLowFreqCount)
+	if got := b16[0].coef - a16[0].coef; math.Abs(got-want) > 0.01*want {
+		t.Errorf("the 16-bit DC coefficients differ by %v, expected %v", got, want)
+	}
+
+	// The 8-bit pipeline truncates the gradient, so both blocks have the same features.
+	yuv8 := image.NewRGBA(g.Bounds())
+	draw.Draw(yuv8, yuv8.Bounds(), convertRGBImageToYUV(g), image.Point{}, draw.Src)
+	block := func(x int) *image.RGBA { return yuv8.SubImage(image.Rect(x, 0, x+n, n)).(*image.RGBA) }
+	a8, _ := dctFeatures(block(0), 0, 0, n, defaultLowFreqCount)
+	b8, _ := dctFeatures(block(4), 4, 0, n, defaultLowFreqCount)
+	if got := b8[0].coef - a8[0].coef; got != 0 {
+		t.Errorf("the 8-bit DC coefficients differ by %v, expected the truncated gradient to be flat", got)
+	}
+}
+
+func TestDetect16BitImage(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img16 := image.NewNRGBA64(img.Bounds())
+	draw.Draw(img16, img16.Bounds(), img, image.Point{}, draw.Src)
+	if !is16Bit(img16) {
+		t.Fatal("the NRGBA64 image is not detected as a 16-bit image")
+	}
+	res, err := Detect(img16, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shift := dst.Min.Sub(src.Min); !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("the copy of the 16-bit image is not detected at the shift %v: %+v", shift, res.Shifts)
+	}
+}
@@ -0,0 +1,1150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config contains the settings used for detecting the image forgeries.
+type Config struct {
+	BlurRadius      int
+	BlockSize       int
+	OffsetThreshold int
+	// OffsetFraction is the offset threshold as a fraction of the analyzed blocks, between 0 and 1. When provided,
+	// it replaces OffsetThreshold, so the same setting suits the small and the large images, whose copies are
+	// matched by very different numbers of blocks (0 uses the absolute OffsetThreshold).
+	OffsetFraction float64
+	// DistanceThreshold is the maximum difference between the features of two matched blocks.
+	DistanceThreshold float64
+	// AdaptiveThreshold scales the distance threshold with the luminance variance of the matched blocks,
+	// so the detailed blocks require tighter matches and the flat blocks are not matched at all.
+	AdaptiveThreshold bool
+	// MatchWindow is the number of the following features in the sorted order each feature is compared with
+	// (defaults to 1, the adjacent feature only). The similar features of a copy are not always adjacent after
+	// the lexicographic sorting, and the wider windows catch them, at the cost of more comparisons.
+	MatchWindow int
+	// ForgeryThreshold is the minimum displacement between the blocks of a suspicious pair for the pair to be
+	// reported as forged, so the similar neighboring blocks of the smooth areas are not taken for copies.
+	// Unlike MinShift, which keeps the overlapping blocks out of the matches before their shifts are voted for,
+	// it applies to the pairs of the dominant shifts, so a short shift shared by the many blocks of a repeated
+	// texture is not reported. The default is well below the shift of the small copies.
+	ForgeryThreshold float64
+	// MedianWindow is the size of the median filter window applied to the luminance. The window is centered
+	// on each pixel, so its size must be odd (0 or 1 disables the filter).
+	MedianWindow int
+	// Gamma is the gamma correction applied to the pixels before the YUV conversion,
+	// where the values above 1 brighten and the values below 1 darken the midtones (0 or 1 disables it).
+	Gamma float64
+	// Equalize spreads the luminance over the full tonal range by histogram equalization before
+	// extracting the features, which strengthens the features of the low contrast images.
+	Equalize bool
+	// CLAHETiles is the number of the tiles in each direction the luminance is equalized in by the contrast-limited
+	// adaptive histogram equalization (0 disables it). Unlike the global equalization, it stretches the dark and
+	// the bright areas separately, which reveals the copies in the locally dark regions.
+	CLAHETiles int
+	// CLAHEClipLimit limits the contrast amplification of the adaptive equalization, as a multiple of the mean
+	// height of the histogram bins (defaults to 2). The lower limits amplify the noise of the flat areas less.
+	CLAHEClipLimit float64
+	// InputYUV takes the input image as already converted to the YUV color space, with the Y, U and V channels
+	// stored in the red, green and blue channels like the images saved by -yuvout, and skips the conversion.
+	// The YCbCr images, like the decoded JPEG images, are analyzed in their own planes, and the grayscale
+	// images are taken as the luminance with neutral chroma.
+	InputYUV bool
+	// MinForgedBlocks is the minimum number of forged blocks required to report the image
+	// as forged, so that a single spurious match doesn't flag the whole image (defaults to 2).
+	MinForgedBlocks int
+	// MinShift is the minimum displacement between two matched blocks (defaults to the block size).
+	MinShift float64
+	// MaxShift is the maximum displacement between two matched blocks (0 disables the limit).
+	// The very distant matches are often coincidences rather than copies.
+	MaxShift     float64
+	MaxImageSize int
+	// Resampling is the interpolation the images larger than MaxImageSize are downscaled with (defaults to bilinear).
+	Resampling Resampling
+	// Step is the distance in pixels between the neighboring blocks. The default step of 1 extracts
+	// fully overlapping blocks, which is the most sensitive, but also the slowest. Greater steps
+	// reduce the number of blocks quadratically, at the cost of missing the smaller copied regions.
+	Step int
+	// MaxFeatures is the maximum number of the features extracted from the image, or from each tile when the image
+	// is processed in tiles, which bounds the memory of the huge images (0 to extract every block). The blocks
+	// above the limit are sampled, and the copies are matched only when both of their blocks are sampled, so
+	// sampling a fraction f of the blocks keeps about f² of the matched pairs and the small copies can be missed.
+	MaxFeatures int
+	// SampleSeed seeds the random sampling of the blocks limited by MaxFeatures, so the same seed samples the
+	// same blocks. The blocks are sampled uniformly, evenly spaced in the extraction order, when it is zero.
+	SampleSeed int64
+	// CorrelationThreshold is the minimum Pearson correlation between the whole descriptor vectors of two matched
+	// blocks. When provided, the blocks are matched by the correlation of their descriptors, which is insensitive
+	// to the scaling of the features, instead of the distance between their individual features.
+	CorrelationThreshold float64
+	// NCCThreshold is the minimum normalized cross-correlation between the pixels of
+	// two matched blocks for the match to be confirmed (0 disables the verification).
+	NCCThreshold float64
+	// SSIMThreshold is the minimum structural similarity between the luminance of
+	// two matched blocks for the match to be confirmed (0 disables the verification).
+	SSIMThreshold float64
+	// Features selects the block descriptors used for matching (defaults to the DCT features).
+	Features FeatureSet
+	// LowFreqCount is the number of the lowest frequency luminance DCT coefficients, in zigzag order, among
+	// the DCT features (defaults to 3). The low frequencies survive the compression, while the high
+	// frequencies are mostly noise, so the fewer coefficients are more robust to recompression.
+	LowFreqCount int
+	// ZernikeOrder is the maximum order of the Zernike moment features (defaults to 4).
+	ZernikeOrder int
+	// Normalize standardizes each feature dimension to zero mean and unit variance before matching.
+	Normalize bool
+	// PCAComponents is the number of principal components the per-block feature vectors
+	// are reduced to before matching (0 disables the reduction).
+	PCAComponents int
+	// Metric is the distance metric used for comparing the block positions.
+	Metric Metric
+	// TileSize is the width and height of the tiles the image is processed in (0 disables tiling).
+	// The blocks are only matched within a tile, so a copy is detected only when its source and destination
+	// blocks lie in the same tile, which holds for the shifts up to the tile overlap minus the block size.
+	// The farther copies are missed, unless MaxShift is set, which widens the overlap to cover every allowed shift.
+	TileSize int
+	// TileOverlap is the overlap between the neighboring tiles. It is at least the block size,
+	// otherwise the copies straddling the tile boundaries would be missed, and at least
+	// the maximum shift plus the block size when MaxShift is set.
+	TileOverlap int
+	// MaskClosing is the size in pixels of the square structuring element used to close the forgery mask,
+	// which merges the nearby detections and fills the small holes (0 disables the closing).
+	MaskClosing int
+	// MinRegionArea is the minimum area in pixels of a connected forged region in the mask.
+	// The smaller regions are discarded from the result (0 keeps every region).
+	MinRegionArea int
+	// MinAlpha is the minimum mean opacity, between 0 and 1, of the analyzed blocks. The mostly transparent
+	// blocks have no visible content, so they are skipped (0 analyzes every block).
+	MinAlpha float64
+	// DCTWeights are the weights of the Y, R, G and B channels in the DCT features, when provided. The luminance
+	// survives the recompression better than the chroma, so it can be emphasized, and the features of the channels
+	// weighted by zero are dropped. The normalization standardizes each feature, which cancels the non-zero weights.
+	DCTWeights *ChannelWeights
+	// MinTextureEnergy is the minimum texture energy of the analyzed blocks, the sum of the magnitudes of their
+	// high frequency luminance DCT coefficients. The flat blocks, like the sky or the walls, match each other
+	// without being copies, so they are skipped (0 analyzes every block). It requires the DCT features.
+	MinTextureEnergy float64
+	// Background is the color the transparent images are composited over before the analysis, when provided.
+	Background *color.NRGBA
+	// ROI is the region of interest relative to the image origin. When provided, only this region
+	// is analyzed, but the results are still reported in the full image space.
+	ROI image.Rectangle
+	// Exclude is the mask of the regions excluded from the detection, like watermarks or timestamps,
+	// which would produce repetitive false matches. It has the size of the image, and its white
+	// pixels mark the excluded regions. The blocks overlapping the excluded pixels are dropped.
+	Exclude image.Image
+	// DetectFlips matches the blocks with the horizontally and vertically mirrored blocks as well,
+	// to detect the mirrored copies. The Sobel features are not supported for the mirrored blocks.
+	DetectFlips bool
+	// DetectGrid checks the alignment of the JPEG blocking-artifact grid, which the regions spliced from
+	// another JPEG image usually break. It is measured on the image before the downscaling.
+	DetectGrid bool
+	// MinShiftConcentration is the minimum fraction, between 0 and 1, of the matches which must be displaced
+	// by the most frequent shift vector to report the image as forged. A copy-move produces a dominant shift,
+	// while the coincidental matches of the noise are scattered over many shifts (0 disables the check).
+	MinShiftConcentration float64
+	// ShiftBinSize is the width in pixels of the bins the shift vectors are accumulated in (defaults to 1).
+	// The wider bins group the slightly different shifts of a copy, caused by the resampling and
+	// quantization noise, into a single peak, at the cost of merging the distinct nearby shifts.
+	ShiftBinSize float64
+	// Instances is the number of the independent copy-move instances the forged block pairs are clustered
+	// into by their shift vectors. It is selected automatically by the elbow heuristic when zero.
+	Instances int
+	// PyramidLevels is the number of the Gaussian pyramid levels the image is analyzed at, each of them half
+	// the size of the previous one, and the detections are fused in the full resolution. The coarse levels catch
+	// the large and far copies, the fine levels the small copies (0 or 1 analyzes the image only).
+	PyramidLevels int
+	// Workers is the number of goroutines extracting and comparing the block features concurrently (defaults to 1).
+	Workers int
+	// HighlightColor is the color the forged source regions are annotated with (defaults to red).
+	// The destination regions are annotated with its complementary color, so the copies can be told apart.
+	HighlightColor color.Color
+	// LineThickness is the thickness in pixels of the annotated region outlines.
+	// The regions are filled instead when it is zero.
+	LineThickness int
+	// CollectStats measures the wall time and the number of items of each pipeline stage into the result.
+	CollectStats bool
+	// CollectMatches keeps the matched block pairs confirmed by the verification in the result.
+	CollectMatches bool
+	// FeatureCache stores the extracted block features on disk and reuses them when the same image is analyzed
+	// again with the same preprocessing and block settings, when provided.
+	FeatureCache *FeatureCache
+}
+
+// DefaultConfig contains the default detection settings.
+var DefaultConfig = Config{
+	BlurRadius:        1,
+	BlockSize:         4,
+	OffsetThreshold:   72,
+	DistanceThreshold: 0.4,
+	ForgeryThreshold:  32,
+	MinForgedBlocks:   2,
+	MaxImageSize:      MaxImageSize,
+}
+
+// Result contains the outcome of the forgery detection.
+type Result struct {
+	// Forged reports whether forged regions have been detected.
+	Forged bool `json:"forged"`
+	// Precision indicates the detection accuracy, as the percentage of the suspicious blocks reported as forged.
+	Precision float64 `json:"precision"`
+	// CopyConfidence is the confidence of the forged block pairs forming a single geometric copy, between 0 and 1.
+	// It combines the fraction and the number of the pairs consistent with the same affine transform.
+	CopyConfidence float64 `json:"copy_confidence"`
+	// AffineInliers is the number of the forged block pairs consistent with the affine transform fitted by RANSAC.
+	AffineInliers int `json:"affine_inliers"`
+	// AffineResidual is the root mean square distance in pixels of the inliers to the fitted affine transform.
+	AffineResidual float64 `json:"affine_residual"`
+	// Regions are the detected forged regions in the original image space.
+	Regions []image.Rectangle `json:"regions"`
+	// ForgedArea is the percentage of the image area covered by the detected forged regions.
+	ForgedArea float64 `json:"forged_area"`
+	// Destinations are the regions the forged regions have been copied to, in the original image space.
+	// The destination i is the copy of the region i.
+	Destinations []image.Rectangle `json:"destinations,omitempty"`
+	// Instances are the independent copy-move forgeries, separated by the clustering of their shift vectors.
+	Instances []Instance `json:"instances,omitempty"`
+	// Arrows connect the center of each forged region to the center of its copy, one per region and shift vector.
+	Arrows []Arrow `json:"arrows,omitempty"`
+	// MeanSSIM is the mean structural similarity of the matches confirmed by the SSIM verification.
+	MeanSSIM float64 `json:"mean_ssim,omitempty"`
+	// Mask is the binary forgery mask in the original image space, where the forged pixels are white.
+	Mask *image.Gray `json:"-"`
+	// ShiftConcentration is the fraction of the matches displaced by the most frequent shift vector.
+	ShiftConcentration float64 `json:"shift_concentration"`
+	// Shifts are the most frequent shift vectors between the matched blocks, sorted by decreasing count.
+	// A copy-move shows up as a sharp peak at the displacement of the copy.
+	Shifts []Shift `json:"shifts,omitempty"`
+	// GridMisaligned are the regions whose JPEG blocking-artifact grid is not aligned with the grid
+	// of the whole image, in the original image space. They are reported when the grid detection is enabled.
+	GridMisaligned []image.Rectangle `json:"grid_misaligned,omitempty"`
+	// JPEGQuality is the estimated quality factor the JPEG input image has been saved at, when known.
+	// It is set by the command line tool, since it requires the encoded image file.
+	JPEGQuality int `json:"jpeg_quality,omitempty"`
+	// Stats are the wall time and the number of items of each pipeline stage, when collected.
+	Stats []StageStats `json:"stats,omitempty"`
+	// InsufficientBlocks reports that the analyzed image holds a single block, like an image of the block size,
+	// so there are no blocks to be matched. The image is reported as not forged, with no regions.
+	InsufficientBlocks bool `json:"insufficient_blocks,omitempty"`
+	// Analyzed reports that the blocks of the image produced features to be matched. The degenerate images, like
+	// the fully transparent, excluded or flat images gated out by their texture energy, produce no features, so
+	// their verdict means that the image could not be analyzed rather than that it is authentic.
+	Analyzed bool `json:"analyzed"`
+	// Confidence grades the blocks of the suspicious pairs by their match support, ordered by the block positions.
+	// Like the mask it is meant for the visualizations, and it is not encoded, since it may contain many blocks.
+	Confidence []BlockConfidence `json:"-"`
+	// Matches are the matched block pairs confirmed by the verification, ordered by the block positions, when
+	// collected. They are meant for the external geometric analysis, and they are not encoded, since they may be many.
+	Matches []Match `json:"-"`
+}
+
+// Shift is the number of matched block pairs displaced by the same shift vector in the original image space.
+// For the mirrored copies the offset along the mirroring direction is the sum of the block coordinates.
+type Shift struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Count int     `json:"count"`
+	// Flip is the mirroring of the copies, horizontal or vertical, if any.
+	Flip string `json:"flip,omitempty"`
+}
+
+// maxBlockSize is the largest supported block size. The larger blocks cannot locate the copied regions.
+const maxBlockSize = 64
+
+// maxShifts is the number of the most frequent shift vectors reported in the result.
+const maxShifts = 10
+
+// validate checks the consistency of the detection settings.
+func (c Config) validate() error {
+	switch {
+	case c.BlockSize <= 1:
+		return invalidConfig("the block size must be greater then 1")
+	case c.BlockSize > maxBlockSize:
+		return invalidConfig("the block size must be at most %d", maxBlockSize)
+	case c.BlurRadius < 0:
+		return invalidConfig("the blur radius cannot be negative")
+	case c.OffsetFraction < 0 || c.OffsetFraction > 1:
+		return invalidConfig("the offset fraction must be between 0 and 1")
+	case c.OffsetThreshold < 0:
+		return invalidConfig("the offset threshold cannot be negative")
+	case c.DistanceThreshold < 0:
+		return invalidConfig("the distance threshold cannot be negative")
+	case c.ForgeryThreshold < 0:
+		return invalidConfig("the forgery threshold cannot be negative")
+	case c.MedianWindow < 0:
+		return invalidConfig("the median filter window cannot be negative")
+	case c.MedianWindow > 1 && c.MedianWindow%2 == 0:
+		return invalidConfig("the median filter window must be odd")
+	case c.MinForgedBlocks < 0:
+		return invalidConfig("the minimum number of forged blocks cannot be negative")
+	case c.MinShift < 0:
+		return invalidConfig("the minimum shift cannot be negative")
+	case c.MaxShift < 0:
+		return invalidConfig("the maximum shift cannot be negative")
+	case c.MaxShift > 0 && c.MaxShift < c.minShift():
+		return invalidConfig("the maximum shift must be at least the minimum shift")
+	case c.Step < 0:
+		return invalidConfig("the block step cannot be negative")
+	case c.MaxFeatures < 0:
+		return invalidConfig("the maximum number of features cannot be negative")
+	case c.CorrelationThreshold < 0 || c.CorrelationThreshold > 1:
+		return invalidConfig("the correlation threshold must be between 0 and 1")
+	case c.NCCThreshold < 0 || c.NCCThreshold > 1:
+		return invalidConfig("the NCC threshold must be between 0 and 1")
+	case c.SSIMThreshold < 0 || c.SSIMThreshold > 1:
+		return invalidConfig("the SSIM threshold must be between 0 and 1")
+	case c.ZernikeOrder < 0 || c.ZernikeOrder > 12:
+		return invalidConfig("the Zernike moments order must be between 0 and 12")
+	case c.LowFreqCount < 0 || c.LowFreqCount > c.BlockSize*c.BlockSize:
+		return invalidConfig("the number of low frequency coefficients must be between 0 and %d", c.BlockSize*c.BlockSize)
+	case c.PCAComponents < 0 || c.PCAComponents > c.featureDims():
+		return invalidConfig("the number of principal components must be between 0 and %d", c.featureDims())
+	case c.Metric < Euclidean || c.Metric > Chebyshev:
+		return invalidConfig("unknown distance metric")
+	case c.Resampling < Bilinear || c.Resampling > CatmullRom:
+		return invalidConfig("unknown resampling")
+	case c.MaxImageSize < 0:
+		return invalidConfig("the maximum image size cannot be negative")
+	case c.MaxImageSize > 0 && c.MaxImageSize < c.BlockSize:
+		return invalidConfig("the maximum image size must be at least the block size")
+	case c.TileSize < 0 || c.TileOverlap < 0:
+		return invalidConfig("the tile size and overlap cannot be negative")
+	case c.TileSize > 0 && c.TileSize <= c.BlockSize:
+		return invalidConfig("the tile size must be greater then the block size")
+	case c.TileSize > 0 && c.TileOverlap >= c.TileSize:
+		return invalidConfig("the tile overlap must be smaller then the tile size")
+	case c.TileSize > 0 && c.tileOverlap() >= c.TileSize:
+		return invalidConfig("the tile size must be greater then the maximum shift plus the block size")
+	case c.MaskClosing < 0:
+		return invalidConfig("the mask closing size cannot be negative")
+	case c.MinRegionArea < 0:
+		return invalidConfig("the minimum region area cannot be negative")
+	case c.MinAlpha < 0 || c.MinAlpha > 1:
+		return invalidConfig("the minimum opacity must be between 0 and 1")
+	case c.DCTWeights != nil && (c.DCTWeights.Y < 0 || c.DCTWeights.R < 0 || c.DCTWeights.G < 0 || c.DCTWeights.B < 0):
+		return invalidConfig("the channel weights cannot be negative")
+	case c.DCTWeights != nil && !c.featureSet().has(FeatureDCT):
+		return invalidConfig("the channel weights require the DCT features")
+	case c.featureDims() == 0:
+		return invalidConfig("the channel weights cannot drop every feature")
+	case c.MinTextureEnergy < 0:
+		return invalidConfig("the minimum texture energy cannot be negative")
+	case c.MinTextureEnergy > 0 && !c.featureSet().has(FeatureDCT):
+		return invalidConfig("the texture energy requires the DCT features")
+	case c.MatchWindow < 0:
+		return invalidConfig("the match window cannot be negative")
+	case c.MinShiftConcentration < 0 || c.MinShiftConcentration > 1:
+		return invalidConfig("the minimum shift concentration must be between 0 and 1")
+	case c.Gamma < 0:
+		return invalidConfig("the gamma cannot be negative")
+	case c.CLAHETiles < 0:
+		return invalidConfig("the number of CLAHE tiles cannot be negative")
+	case c.CLAHEClipLimit != 0 && c.CLAHEClipLimit < 1:
+		return invalidConfig("the CLAHE clip limit must be at least 1")
+	case c.CLAHETiles > 0 && c.Equalize:
+		return invalidConfig("the global and the adaptive histogram equalization cannot be combined")
+	case c.InputYUV && c.Gamma > 0 && c.Gamma != 1:
+		return invalidConfig("the gamma correction cannot be applied to a YUV input")
+	case c.InputYUV && c.Background != nil:
+		return invalidConfig("the background color cannot be composited with a YUV input")
+	case c.ShiftBinSize < 0:
+		return invalidConfig("the shift bin size cannot be negative")
+	case c.Instances < 0:
+		return invalidConfig("the number of instances cannot be negative")
+	case c.PyramidLevels < 0 || c.PyramidLevels > maxPyramidLevels:
+		return invalidConfig("the number of pyramid levels must be between 0 and %d", maxPyramidLevels)
+	case c.Workers < 0:
+		return invalidConfig("the number of workers cannot be negative")
+	case c.LineThickness < 0:
+		return invalidConfig("the line thickness cannot be negative")
+	case c.DetectFlips && c.featureSet().has(FeatureSobel):
+		return invalidConfig("the mirrored copies cannot be detected with the Sobel features")
+	case c.MaxFeatures > 0 && c.MaxFeatures < 2*featuresPerBlock(c):
+		return invalidConfig("the maximum number of features must hold at least two blocks of %d features", featuresPerBlock(c))
+	}
+	return nil
+}
+
+// Detect analyzes the image and detects the copy-move forgeries.
+// It returns ErrInvalidConfig when the settings are not valid and
+// ErrImageTooSmall when the image cannot hold a single block.
+func Detect(src image.Image, cfg Config) (*Result, error) {
+	return DetectContext(context.Background(), src, cfg)
+}
+
+// DetectContext is like Detect, but aborts the detection when the context is done.
+// The returned error wraps the context error and reports the number of processed blocks.
+func DetectContext(ctx context.Context, src image.Image, cfg Config) (*Result, error) {
+	d := &Detector{Config: cfg}
+	return d.DetectContext(ctx, src)
+}
+
+// DetectContext analyzes the image with the detector settings and detects the copy-move forgeries.
+// The detection is aborted when the context is done. The buffers of the detector are reused,
+// so the Reset method must be called between the runs.
+func (d *Detector) DetectContext(ctx context.Context, src image.Image) (*Result, error) {
+	cfg := d.Config
+	if cfg.PyramidLevels > 1 {
+		return d.detectPyramid(ctx, src)
+	}
+	d.progress(StagePreprocess, 0)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if src == nil || src.Bounds().Dx() < cfg.BlockSize || src.Bounds().Dy() < cfg.BlockSize {
+		return nil, ErrImageTooSmall
+	}
+
+	if cfg.Exclude != nil {
+		if err := checkExcludeMask(cfg.Exclude, src); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.InputYUV {
+		if err := checkYUVInput(src); err != nil {
+			return nil, err
+		}
+	}
+
+	// Analyze only the region of interest, if provided.
+	analyzed := src
+	if !cfg.ROI.Empty() {
+		roi := cfg.ROI.Add(src.Bounds().Min)
+		if !roi.In(src.Bounds()) {
+			return nil, invalidConfig("the region of interest %v lies outside of the image bounds", cfg.ROI)
+		}
+		if roi.Dx() < cfg.BlockSize || roi.Dy() < cfg.BlockSize {
+			return nil, ErrImageTooSmall
+		}
+		analyzed = cropImage(src, roi)
+	}
+	// The planes of the YUV input are extracted before the downscaling, which would convert them to RGB.
+	if cfg.InputYUV {
+		analyzed = yuvChannels(analyzed)
+	}
+
+	// Downscale the large images to keep the number of analyzed blocks manageable.
+	resized, scale := downscale(analyzed, cfg.MaxImageSize, cfg.Resampling)
+	if resized.Bounds().Dx() < cfg.BlockSize || resized.Bounds().Dy() < cfg.BlockSize {
+		return nil, ErrImageTooSmall
+	}
+	// Composite the transparent images over the background color, if provided.
+	if cfg.Background != nil {
+		resized = flatten(resized, *cfg.Background)
+	}
+	// The preprocessing is applied on a copy, since the original pixels are needed for the match verification.
+	stats := newPipelineStats(cfg.CollectStats)
+	start := time.Now()
+	orig := imgToNRGBA(resized)
+	newImg := preprocess(orig, cfg)
+
+	dx, dy := newImg.Bounds().Max.X, newImg.Bounds().Max.Y
+
+	// The DCT features of the 16-bit images are extracted with the full precision. The median filter,
+	// the gamma correction and the equalization are implemented only for the 8-bit pixels and the mirrored
+	// blocks are extracted from the 8-bit image, so the 16-bit path is not used together with them.
+	var img16 *image.RGBA64
+	if is16Bit(resized) && cfg.MedianWindow <= 1 && (cfg.Gamma == 0 || cfg.Gamma == 1) && !cfg.Equalize && cfg.CLAHETiles == 0 && !cfg.DetectFlips && cfg.featureSet().has(FeatureDCT) {
+		img16 = convertRGBImageToYUV16(blur16(toRGBA64(resized), cfg.BlurRadius))
+	}
+	stats.add(statsYUV, start, newImg.Bounds().Dx()*newImg.Bounds().Dy())
+
+	var grad *gradient
+	if cfg.featureSet().has(FeatureSobel) {
+		grad = sobel(newImg)
+	}
+
+	tiles := splitTiles(newImg.Bounds(), cfg.TileSize, cfg.tileOverlap(), cfg.BlockSize)
+	var blocksNum int
+	for _, tile := range tiles {
+		blocksNum += cfg.tileBlocks(tile)
+	}
+
+	debugLog.Printf("Image size: %dx%d, tiles: %d, blocks: %d", dx, dy, len(tiles), blocksNum)
+
+	// A single block has no other block to be matched with, so no copy can be searched for.
+	if blocksNum < 2 {
+		bounds := image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+		return &Result{InsufficientBlocks: true, Mask: image.NewGray(bounds), Stats: stats.result()}, nil
+	}
+
+	var bar progressBar = newProgressBar(blocksNum, "Generate: ")
+	if d.events != nil {
+		bar = &eventBar{progressBar: bar, d: d, total: blocksNum}
+	}
+	d.progress(StageExtract, extractStart)
+
+	// Only the matches are accumulated over the tiles, the features are discarded after each tile.
+	var featuresNum, processed int
+	sources := blockSources{yuv: newImg, yuv16: img16, grad: grad, alpha: orig}
+	if cfg.Exclude != nil {
+		roi := cfg.ROI
+		if roi.Empty() {
+			roi = image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+		}
+		sources.excluded = newExclusionMap(cfg.Exclude, roi, newImg.Bounds().Size(), scale)
+	}
+	var cacheKey []byte
+	if cfg.FeatureCache != nil {
+		cacheKey = cfg.FeatureCache.imageKey(sources, cfg)
+	}
+	for _, tile := range tiles {
+		features, n, err := extractCachedFeatures(ctx, d.features[:0], sources, tile, cfg, cacheKey, bar, stats)
+		// Retain the feature buffer for the next tile and the next run.
+		d.features = features[:0]
+		processed += n
+		if err != nil {
+			bar.Finish()
+			return nil, fmt.Errorf("detection aborted after processing %d of %d blocks: %w", processed, blocksNum, err)
+		}
+		if cfg.Normalize {
+			normalizeFeatures(features, cfg.featureDims())
+		}
+		if cfg.PCAComponents > 0 {
+			features = pcaReduce(features, cfg.featureDims(), cfg.PCAComponents)
+		}
+		featuresNum += len(features)
+		if cfg.CorrelationThreshold > 0 {
+			dims := cfg.featureDims()
+			if cfg.PCAComponents > 0 {
+				dims = cfg.PCAComponents
+			}
+			d.vectors = append(d.vectors, matchCorrelated(features, dims, cfg, stats)...)
+		} else {
+			d.vectors = append(d.vectors, matchFeatures(features, cfg, stats)...)
+		}
+	}
+	bar.Finish()
+	if featuresNum == 0 {
+		debugLog.Printf("No block of the image produced features, the image could not be analyzed")
+	}
+	// A pair of blocks is matched on each of its similar features, and the pairs lying in the overlap of the
+	// neighboring tiles are matched in each of them, so every pair is counted once, whether tiled or not.
+	vectors := dedupVectors(d.vectors)
+	debugLog.Printf("Features: %d, shift vectors: %d", featuresNum, len(vectors))
+
+	// The verification is expensive as well, so the context is checked once more before it.
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("detection aborted after processing %d of %d blocks: %w", processed, blocksNum, err)
+	}
+
+	d.progress(StageVerify, extractEnd)
+	start = time.Now()
+	if cfg.NCCThreshold > 0 {
+		vectors = verifyNCC(orig, vectors, cfg)
+		debugLog.Printf("Shift vectors confirmed by NCC: %d", len(vectors))
+	}
+
+	var meanSSIM float64
+	if cfg.SSIMThreshold > 0 {
+		vectors, meanSSIM = verifySSIM(orig, vectors, cfg)
+		debugLog.Printf("Shift vectors confirmed by SSIM: %d", len(vectors))
+	}
+
+	d.progress(StageAnalyze, 0.9)
+	if cfg.OffsetFraction > 0 {
+		cfg.OffsetThreshold = cfg.offsetThreshold(blocksNum)
+		debugLog.Printf("Offset threshold: %d", cfg.OffsetThreshold)
+	}
+	simBlocks, shiftHist := getSuspiciousBlocks(vectors, cfg)
+	forgedBlocks, isForged := filterOutNeighbors(simBlocks, cfg)
+	stats.add(statsFilter, start, len(forgedBlocks))
+
+	// Estimate the geometric transformation of the copies matched by the scale and rotation invariant descriptor.
+	if cfg.featureSet().has(FeatureFourierMellin) {
+		for i, v := range forgedBlocks {
+			forgedBlocks[i].scale, forgedBlocks[i].rotation = estimateScaleRotation(newImg, image.Pt(v.xa, v.ya), image.Pt(v.xb, v.yb), cfg.BlockSize)
+		}
+	}
+
+	simBlocksNum := len(simBlocks)
+	forgedBlocksNum := len(forgedBlocks)
+	debugLog.Printf("Suspicious blocks: %d, forged blocks: %d", simBlocksNum, forgedBlocksNum)
+
+	// A copy-move concentrates the matches on a dominant shift, while the coincidental matches are scattered.
+	var concentration float64
+	shifts := topShifts(shiftHist, maxShifts, scale)
+	if len(shifts) > 0 {
+		concentration = float64(shifts[0].Count) / float64(len(vectors))
+	}
+
+	res := &Result{
+		Forged:             forgedVerdict(forgedBlocks, isForged, concentration, cfg),
+		Precision:          detectionPrecision(forgedBlocksNum, simBlocksNum),
+		MeanSSIM:           meanSSIM,
+		Shifts:             shifts,
+		ShiftConcentration: concentration,
+		Stats:              stats.result(),
+		Analyzed:           featuresNum > 0,
+	}
+	if fit, ok := fitAffine(forgedBlocks); ok {
+		res.AffineInliers, res.AffineResidual = fit.inliers, fit.residual*scale
+		res.CopyConfidence = copyConfidence(fit, len(forgedBlocks))
+	}
+	if cfg.CollectMatches {
+		res.Matches = collectMatches(vectors, scale, cfg.ROI.Min)
+	}
+	if len(simBlocks) > 0 {
+		res.Confidence = confidenceMap(blockConfidence(simBlocks, cfg), cfg.BlockSize, scale, cfg.ROI.Min)
+	}
+	pairs := make([]regionPair, 0, len(forgedBlocks))
+	for _, bl := range forgedBlocks {
+		srcRect := image.Rect(bl.xa, bl.ya, bl.xa+cfg.BlockSize*2, bl.ya+cfg.BlockSize*2)
+		dstRect := image.Rect(bl.xb, bl.yb, bl.xb+cfg.BlockSize*2, bl.yb+cfg.BlockSize*2)
+		pairs = append(pairs, regionPair{
+			src:   scaleRect(srcRect, scale).Add(cfg.ROI.Min),
+			dst:   scaleRect(dstRect, scale).Add(cfg.ROI.Min),
+			shift: cfg.shiftBin(bl),
+		})
+		res.Regions = append(res.Regions, pairs[len(pairs)-1].src)
+	}
+	bounds := image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+	res.Mask = regionMask(bounds, res.Regions)
+	if cfg.MaskClosing > 0 {
+		res.Mask = closeMask(res.Mask, cfg.MaskClosing)
+	}
+	// The small isolated regions are usually false positives.
+	if cfg.MinRegionArea > 0 {
+		kept := removeSmallRegions(res.Mask, res.Regions, cfg.MinRegionArea)
+		// Keep the pairs of the kept regions only.
+		isKept := make(map[image.Rectangle]bool, len(kept))
+		for _, r := range kept {
+			isKept[r] = true
+		}
+		var keptPairs []regionPair
+		for _, p := range pairs {
+			if isKept[p.src.Intersect(bounds)] {
+				keptPairs = append(keptPairs, p)
+			}
+		}
+		res.Regions, pairs = kept, keptPairs
+		res.Forged = res.Forged && len(res.Regions) >= cfg.minForgedBlocks()
+	}
+	for _, p := range pairs {
+		res.Destinations = append(res.Destinations, p.dst)
+	}
+	res.Arrows = copyArrows(res.Mask, pairs)
+	res.Instances = copyInstances(pairs, scale, cfg.Instances, cfg.minForgedBlocks())
+	res.ForgedArea = areaPercentage(bounds, res.Regions)
+	sortRegionPairs(res.Regions, res.Destinations)
+
+	// The blocking artifacts are lost by the downscaling, so the grid is measured on the analyzed image.
+	if cfg.DetectGrid {
+		for _, r := range misalignedGrid(analyzed) {
+			res.GridMisaligned = append(res.GridMisaligned, r.Add(cfg.ROI.Min))
+		}
+	}
+	return res, nil
+}
+
+// preprocess converts the image into the YUV color space the block features are extracted from,
+// after the optional gamma correction and blur. The YUV input is not converted again. The equalization and the median filter are applied
+// on the luminance channel, stored in the red component. The source image is left unchanged.
+func preprocess(src *image.NRGBA, cfg Config) *image.RGBA {
+	img := cloneNRGBA(src)
+
+	// Correct the unusual gamma, which skews the luminance based features.
+	if cfg.Gamma > 0 && cfg.Gamma != 1 {
+		adjustGamma(img, cfg.Gamma)
+	}
+
+	// Blur the image to eliminate the details.
+	if cfg.BlurRadius > 0 {
+		img = StackBlur(img, uint32(cfg.BlurRadius))
+	}
+
+	// Convert image to YUV color space, unless it is converted already.
+	var yuv image.Image = img
+	if !cfg.InputYUV {
+		yuv = convertRGBImageToYUV(img)
+	}
+	newImg := image.NewRGBA(yuv.Bounds())
+	draw.Draw(newImg, image.Rect(0, 0, yuv.Bounds().Dx(), yuv.Bounds().Dy()), yuv, image.ZP, draw.Src)
+
+	// Spread the luminance of the low contrast images, which produce weak features.
+	if cfg.Equalize {
+		equalizeLuminance(newImg)
+	}
+	if cfg.CLAHETiles > 0 {
+		claheLuminance(newImg, cfg.CLAHETiles, cfg.claheClipLimit())
+	}
+
+	// Remove the impulse noise from the luminance channel.
+	if cfg.MedianWindow > 1 {
+		newImg = medianFilter(newImg, cfg.MedianWindow)
+	}
+	return newImg
+}
+
+// sortRegions orders the regions by their top left corner, row by row, so the result
+// doesn't depend on the order the blocks have been matched in and is reproducible across runs.
+func sortRegions(regions []image.Rectangle) {
+	sort.SliceStable(regions, func(i, j int) bool { return regionLess(regions[i], regions[j]) })
+}
+
+// sortRegionPairs orders the regions like sortRegions, and moves their destinations along with them,
+// so each destination stays at the index of the region it has been copied from.
+func sortRegionPairs(regions, destinations []image.Rectangle) {
+	if len(regions) != len(destinations) {
+		sortRegions(regions)
+		sortRegions(destinations)
+		return
+	}
+	idx := make([]int, len(regions))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := regions[idx[i]], regions[idx[j]]
+		if a == b {
+			return regionLess(destinations[idx[i]], destinations[idx[j]])
+		}
+		return regionLess(a, b)
+	})
+	srcs := append([]image.Rectangle(nil), regions...)
+	dsts := append([]image.Rectangle(nil), destinations...)
+	for i, k := range idx {
+		regions[i], destinations[i] = srcs[k], dsts[k]
+	}
+}
+
+// regionLess reports whether the region a comes before the region b, ordering them by their top left corner.
+func regionLess(a, b image.Rectangle) bool {
+	switch {
+	case a.Min.Y != b.Min.Y:
+		return a.Min.Y < b.Min.Y
+	case a.Min.X != b.Min.X:
+		return a.Min.X < b.Min.X
+	case a.Max.Y != b.Max.Y:
+		return a.Max.Y < b.Max.Y
+	}
+	return a.Max.X < b.Max.X
+}
+
+// splitTiles splits the bounds into overlapping tiles. The overlap is at least the block size,
+// to catch the copies straddling the tile boundaries. If the tile size is zero a single tile is returned.
+func splitTiles(bounds image.Rectangle, size, overlap, blockSize int) []image.Rectangle {
+	if size <= 0 || (size >= bounds.Dx() && size >= bounds.Dy()) {
+		return []image.Rectangle{bounds}
+	}
+	if overlap < blockSize {
+		overlap = blockSize
+	}
+	step := size - overlap
+	if step < 1 {
+		step = 1
+	}
+
+	var tiles []image.Rectangle
+	for y := bounds.Min.Y; ; y += step {
+		for x := bounds.Min.X; ; x += step {
+			tiles = append(tiles, image.Rect(x, y, x+size, y+size).Intersect(bounds))
+			if x+size >= bounds.Max.X {
+				break
+			}
+		}
+		if y+size >= bounds.Max.Y {
+			break
+		}
+	}
+	return tiles
+}
+
+// pairKey identifies a matched block pair by the global positions of its blocks and its mirroring.
+type pairKey struct {
+	xa, ya, xb, yb int
+	flip           Flip
+}
+
+// dedupVectors removes the repeated block pairs, matched on several features or in several overlapping tiles,
+// keeping the first occurrence of each pair. The blocks are identified by their global positions, which the tiles share.
+// The vectors are filtered in place.
+func dedupVectors(vect []vector) []vector {
+	seen := make(map[pairKey]bool, len(vect))
+	unique := vect[:0]
+	for _, v := range vect {
+		key := pairKey{v.xa, v.ya, v.xb, v.yb, v.flip}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// tileOverlap returns the overlap between the neighboring tiles. With a maximum shift, the overlap is widened
+// to the maximum shift plus the block size, so both blocks of every pair within the maximum shift lie in a common tile.
+func (c Config) tileOverlap() int {
+	if c.MaxShift > 0 {
+		if overlap := int(math.Ceil(c.MaxShift)) + c.BlockSize; overlap > c.TileOverlap {
+			return overlap
+		}
+	}
+	return c.TileOverlap
+}
+
+// minForgedBlocks returns the minimum number of forged blocks required for a forged verdict.
+func (c Config) minForgedBlocks() int {
+	if c.MinForgedBlocks < 1 {
+		return DefaultConfig.MinForgedBlocks
+	}
+	return c.MinForgedBlocks
+}
+
+// detectionPrecision indicates the detection accuracy, as the percentage of the suspicious blocks displaced
+// farther than the forgery threshold, or 0 when no block is forged.
+func detectionPrecision(forgedBlocks, suspiciousBlocks int) float64 {
+	if forgedBlocks == 0 {
+		return 0
+	}
+	return float64(forgedBlocks) / float64(suspiciousBlocks) * 100
+}
+
+// forgedVerdict reports whether the blocks kept by filterOutNeighbors are reported as forged: they must be at least
+// the minimum number of forged blocks, so a single stray match is not a forgery, and their shifts concentrated enough.
+func forgedVerdict(forgedBlocks newVector, isForged bool, concentration float64, cfg Config) bool {
+	return isForged && len(forgedBlocks) >= cfg.minForgedBlocks() && concentration >= cfg.MinShiftConcentration
+}
+
+// offsetThreshold returns the offset threshold for the number of the analyzed blocks: the fraction of the blocks
+// when the relative threshold is provided, the absolute threshold otherwise.
+func (c Config) offsetThreshold(blocks int) int {
+	if c.OffsetFraction > 0 {
+		return int(c.OffsetFraction * float64(blocks))
+	}
+	return c.OffsetThreshold
+}
+
+// minShift returns the minimum displacement between two matched blocks.
+// By default the overlapping blocks are not matched.
+func (c Config) minShift() float64 {
+	if c.MinShift == 0 {
+		return float64(c.BlockSize)
+	}
+	return c.MinShift
+}
+
+// blockStep returns the distance between the neighboring blocks, which is at least one pixel.
+func (c Config) blockStep() int {
+	if c.Step < 1 {
+		return 1
+	}
+	return c.Step
+}
+
+// highlightColor returns the color of the annotated source regions, which is red by default.
+func (c Config) highlightColor() color.RGBA {
+	if c.HighlightColor == nil {
+		return color.RGBA{255, 0, 0, 255}
+	}
+	return color.RGBAModel.Convert(c.HighlightColor).(color.RGBA)
+}
+
+// workers returns the number of goroutines extracting and comparing the block features, which is at least one.
+func (c Config) workers() int {
+	if c.Workers < 1 {
+		return 1
+	}
+	return c.Workers
+}
+
+// countBlocks returns the number of blocks extracted with the provided step which fits inside the rectangle.
+func countBlocks(r image.Rectangle, blockSize, step int) int {
+	if r.Dx() < blockSize || r.Dy() < blockSize {
+		return 0
+	}
+	bdx, bdy := (r.Dx()-blockSize)/step+1, (r.Dy()-blockSize)/step+1
+	return bdx * bdy
+}
+
+// maxBlocks returns the maximum number of the blocks extracted from a tile, which produce at most MaxFeatures
+// features, or 0 when unlimited.
+func (c Config) maxBlocks() int {
+	if c.MaxFeatures <= 0 {
+		return 0
+	}
+	return c.MaxFeatures / featuresPerBlock(c)
+}
+
+// sampleBlocks returns n of the blocks in their order, sampled randomly with the seed,
+// or evenly spaced when the seed is zero.
+func sampleBlocks(blocks []imageBlock, n int, seed int64) []imageBlock {
+	sampled := make([]imageBlock, 0, n)
+	if seed == 0 {
+		for i := 0; i < n; i++ {
+			sampled = append(sampled, blocks[i*len(blocks)/n])
+		}
+		return sampled
+	}
+	indices := rand.New(rand.NewSource(seed)).Perm(len(blocks))[:n]
+	sort.Ints(indices)
+	for _, i := range indices {
+		sampled = append(sampled, blocks[i])
+	}
+	return sampled
+}
+
+// tileBlocks returns the number of blocks extracted from the tile, once sampled to the maximum number of features.
+func (c Config) tileBlocks(tile image.Rectangle) int {
+	n := countBlocks(tile, c.BlockSize, c.blockStep())
+	if limit := c.maxBlocks(); limit > 0 && n > limit {
+		return limit
+	}
+	return n
+}
+
+// blockSources contains the images the block features are extracted from.
+type blockSources struct {
+	// yuv is the YUV image, with the luminance stored in the red component.
+	yuv *image.RGBA
+	// yuv16 is the 16-bit YUV image the DCT features are extracted from instead, when provided.
+	yuv16 *image.RGBA64
+	// grad is the gradient of the YUV image, computed only for the Sobel features.
+	grad *gradient
+	// alpha is the image providing the opacity of the blocks.
+	alpha *image.NRGBA
+	// excluded marks the pixels excluded from the detection, when provided.
+	excluded *exclusionMap
+}
+
+// extractFeatures divides the tile of the YUV image into overlapping blocks and appends the features of each block to feats.
+// The features contain the blocks top-left position in the image space. It also returns the number of processed blocks.
+// When the context is done the features of the already processed blocks are returned with the context error.
+func extractFeatures(ctx context.Context, feats []feature, src blockSources, tile image.Rectangle, cfg Config, bar progressBar, stats *pipelineStats) ([]feature, int, error) {
+	img, blockSize, step := src.yuv, cfg.BlockSize, cfg.blockStep()
+
+	start := time.Now()
+	var blocks []imageBlock
+	for i := tile.Min.X; i <= tile.Max.X-blockSize; i += step {
+		for j := tile.Min.Y; j <= tile.Max.Y-blockSize; j += step {
+			r := image.Rect(i, j, i+blockSize, j+blockSize)
+			block := img.SubImage(r).(*image.RGBA)
+			blocks = append(blocks, imageBlock{x: i, y: j, img: block})
+		}
+	}
+	if limit := cfg.maxBlocks(); limit > 0 && len(blocks) > limit {
+		debugLog.Printf("Sampling %d of the %d blocks of the tile %v", limit, len(blocks), tile)
+		blocks = sampleBlocks(blocks, limit, cfg.SampleSeed)
+	}
+
+	stats.add(statsBlocks, start, len(blocks))
+
+	start, first := time.Now(), len(feats)
+	workers := cfg.workers()
+	if workers == 1 || len(blocks) < workers {
+		feats, n, err := extractBlocks(ctx, feats, blocks, src, cfg, bar)
+		stats.add(statsFeatures, start, len(feats)-first)
+		return feats, n, err
+	}
+
+	// Each worker extracts the features of a contiguous range of blocks, which are concatenated in order.
+	parts := make([][]feature, workers)
+	processed := make([]int, workers)
+	errs := make([]error, workers)
+	chunk := (len(blocks) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > len(blocks) {
+			hi = len(blocks)
+		}
+		wg.Add(1)
+		go func(w int, blocks []imageBlock) {
+			defer wg.Done()
+			parts[w], processed[w], errs[w] = extractBlocks(ctx, nil, blocks, src, cfg, bar)
+		}(w, blocks[lo:hi])
+	}
+	wg.Wait()
+
+	var n int
+	var err error
+	for w := range parts {
+		feats = append(feats, parts[w]...)
+		n += processed[w]
+		if errs[w] != nil {
+			err = errs[w]
+		}
+	}
+	stats.add(statsFeatures, start, len(feats)-first)
+	return feats, n, err
+}
+
+// extractBlocks appends the features of the blocks to feats and returns them with the number of processed blocks.
+func extractBlocks(ctx context.Context, feats []feature, blocks []imageBlock, src blockSources, cfg Config, bar progressBar) ([]feature, int, error) {
+	blockSize := cfg.BlockSize
+	for n, block := range blocks {
+		if err := ctx.Err(); err != nil {
+			return feats, n, err
+		}
+		bar.Increment()
+		// The mostly transparent blocks have no visible content to be matched.
+		if cfg.MinAlpha > 0 && meanAlpha(src.alpha, block.x, block.y, blockSize) < cfg.MinAlpha {
+			continue
+		}
+		if src.excluded != nil && src.excluded.overlaps(block.x, block.y, blockSize) {
+			continue
+		}
+		b := block.img.(*image.RGBA)
+		first := len(feats)
+		feats = append(feats, blockFeatures(src, b, block.x, block.y, cfg)...)
+		// The blocks gated out by their texture energy have no features, and neither have their mirrored copies.
+		if len(feats) == first {
+			continue
+		}
+		if cfg.DetectFlips {
+			for _, flip := range []Flip{FlipHorizontal, FlipVertical} {
+				flipped := blockFeatures(blockSources{}, flipBlock(b, blockSize, flip), block.x, block.y, cfg)
+				for i := range flipped {
+					flipped[i].flip = flip
+				}
+				feats = append(feats, flipped...)
+			}
+		}
+		if cfg.AdaptiveThreshold {
+			_, variance := lumaStats(b, blockSize)
+			for i := first; i < len(feats); i++ {
+				feats[i].variance = variance
+			}
+		}
+	}
+	return feats, len(blocks), nil
+}
+
+// blockFeatures extracts the selected features of the YUV block having its top left corner at bx, by.
+// The DCT features are extracted from the 16-bit YUV image instead, when it is provided.
+// It returns no features when the texture energy of the block is below the minimum.
+func blockFeatures(src blockSources, b *image.RGBA, bx, by int, cfg Config) []feature {
+	var feats []feature
+	blockSize, features := cfg.BlockSize, cfg.featureSet()
+	if features.has(FeatureDCT) {
+		var energy float64
+		if src.yuv16 != nil {
+			feats, energy = dctFeatures16(src.yuv16, bx, by, blockSize, cfg.lowFreqCount())
+		} else {
+			feats, energy = dctFeatures(b, bx, by, blockSize, cfg.lowFreqCount())
+		}
+		if energy < cfg.MinTextureEnergy {
+			return nil
+		}
+		feats = weightFeatures(feats, cfg.dctWeights())
+	}
+	if features.has(FeatureSobel) {
+		feats = append(feats, sobelFeatures(src.grad, bx, by, blockSize)...)
+	}
+	if features.has(FeatureMeanVar) {
+		feats = append(feats, meanVarFeatures(b, bx, by, blockSize)...)
+	}
+	if features.has(FeatureEntropy) {
+		feats = append(feats, entropyFeatures(b, bx, by, blockSize)...)
+	}
+	if features.has(FeatureZernike) {
+		feats = append(feats, zernikeFeatures(b, bx, by, blockSize, cfg.zernikeOrder())...)
+	}
+	if features.has(FeatureFourierMellin) {
+		feats = append(feats, fourierMellinFeatures(b, bx, by, blockSize)...)
+	}
+	if features.has(FeatureChannels) {
+		feats = append(feats, channelFeatures(b, bx, by, blockSize)...)
+	}
+	return feats
+}
+
+// matchFeatures sorts the features and returns the shift vectors between the neighboring similar blocks.
+func matchFeatures(features []feature, cfg Config, stats *pipelineStats) []vector {
+	if !cfg.DetectFlips {
+		return matchSorted(features, cfg, false, stats)
+	}
+
+	// The mirrored features would break the adjacency of the similar unmirrored features in the sorted order,
+	// so the unmirrored blocks are matched separately, and then only the mirrored matches are kept.
+	var plain []feature
+	for _, f := range features {
+		if f.flip == NoFlip {
+			plain = append(plain, f)
+		}
+	}
+	return append(matchSorted(plain, cfg, false, stats), matchSorted(features, cfg, true, stats)...)
+}
+
+// matchSorted sorts the features and returns the shift vectors between the neighboring similar blocks,
+// or only between the neighboring mirrored and unmirrored blocks.
+func matchSorted(features []feature, cfg Config, mirroredOnly bool, stats *pipelineStats) []vector {
+	var vectors []vector
+
+	// Lexicographically sort the feature vectors
+	start := time.Now()
+	sort.Sort(featVec(features))
+	stats.add(statsSort, start, len(features))
+
+	start = time.Now()
+	pairs := len(features) - 1
+	workers := cfg.workers()
+	if workers == 1 || pairs < workers {
+		vectors = compareNeighbors(vectors, features, 0, pairs, cfg, mirroredOnly)
+		stats.add(statsMatch, start, len(vectors))
+		return vectors
+	}
+
+	// Each worker compares a contiguous range of neighboring pairs, and the vectors are concatenated in order,
+	// so they are identical to the vectors of the sequential comparison.
+	parts := make([][]vector, workers)
+	chunk := (pairs + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > pairs {
+			hi = pairs
+		}
+		if lo >= hi {
+			break
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			parts[w] = compareNeighbors(nil, features, lo, hi, cfg, mirroredOnly)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+
+	for _, part := range parts {
+		vectors = append(vectors, part...)
+	}
+	stats.add(statsMatch, start, len(vectors))
+	return vectors
+}
+
+// compareNeighbors compares the sorted features of the indices between lo and hi with the features following them
+// within the match window, and appends the shift vectors of the similar blocks to vectors.
+func compareNeighbors(vectors []vector, features []feature, lo, hi int, cfg Config, mirroredOnly bool) []vector {
+	window := cfg.matchWindow()
+	for i := lo; i < hi; i++ {
+		for j := i + 1; j <= i+window && j < len(features); j++ {
+			blockA, blockB := features[i], features[j]
+			if mirroredOnly && blockA.flip == NoFlip && blockB.flip == NoFlip {
+				continue
+			}
+			result := analyzeBlocks(blockA, blockB, cfg)
+
+			if result != nil {
+				vectors = append(vectors, *result)
+			}
+		}
+	}
+	return vectors
+}
+
+// matchWindow returns the number of the following features each sorted feature is compared with.
+func (c Config) matchWindow() int {
+	if c.MatchWindow < 1 {
+		return 1
+	}
+	return c.MatchWindow
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterOutNeighbors(t *testing.T) {
+	// The blocks of a compact copy are next to each other, but each pair is displaced by the copy shift.
+	var vect []vector
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			vect = append(vect, vector{xa: 10 + x, ya: 10 + y, xb: 138 + x, yb: 138 + y})
+		}
+	}
+	// The similar neighboring blocks of a smooth area.
+	vect = append(vect, vector{xa: 200, ya: 20, xb: 204, yb: 17})
+
+	forged, isForged := filterOutNeighbors(vect, DefaultConfig)
+	if !isForged {
+		t.Fatal("the copy should be reported as forged")
+	}
+	if len(forged) != 9 {
+		t.Fatalf("expected the 9 pairs of the copy, got %d", len(forged))
+	}
+	for _, v := range forged {
+		if v.xb-v.xa != 128 || v.yb-v.ya != 128 {
+			t.Errorf("the neighboring pair %+v should be filtered out", v)
+		}
+	}
+}
+
+func TestFilterOutNeighborsShortInput(t *testing.T) {
+	distant := vector{xa: 10, ya: 10, xb: 138, yb: 138}
+	near := vector{xa: 200, ya: 20, xb: 204, yb: 17}
+	for _, tc := range []struct {
+		name   string
+		vect   []vector
+		forged int
+	}{
+		{"empty", nil, 0},
+		// A lone distant pair is a legitimate copy of a single block, so it is kept.
+		{"single distant", []vector{distant}, 1},
+		{"single near", []vector{near}, 0},
+		{"two distant", []vector{distant, {xa: 11, ya: 10, xb: 139, yb: 138}}, 2},
+		{"distant and near", []vector{near, distant}, 1},
+	} {
+		forged, isForged := filterOutNeighbors(tc.vect, DefaultConfig)
+		if len(forged) != tc.forged || isForged != (tc.forged > 0) {
+			t.Errorf("%s: kept %d pairs, forged %v, expected %d pairs", tc.name, len(forged), isForged, tc.forged)
+		}
+	}
+}
+
+func TestDetectSyntheticImage(t *testing.T) {
+	for _, size := range []int{256, 512} {
+		img, src, dst, err := SyntheticImage(size, size, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Detect(img, DefaultConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Forged {
+			t.Fatalf("%dx%d: the synthetic copy is not detected", size, size)
+		}
+		shift := dst.Min.Sub(src.Min)
+		if len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+			t.Errorf("%dx%d: expected the dominant shift %v, got %+v", size, size, shift, res.Shifts)
+		}
+		var found bool
+		for _, r := range res.Regions {
+			if r.Overlaps(src) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%dx%d: no region of the %v copied region has been detected", size, size, src)
+		}
+	}
+}
+
+func TestMinForgedBlocksVerdict(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A real region holds many forged blocks.
+	n := len(res.Regions)
+	if !res.Forged || n < DefaultConfig.MinForgedBlocks {
+		t.Fatalf("the copied region is not reported forged: %d regions", n)
+	}
+
+	// The same matches are reported clean when they are one block short of the minimum.
+	cfg := DefaultConfig
+	cfg.MinForgedBlocks = n + 1
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged || len(res.Regions) != n {
+		t.Errorf("%d forged blocks are reported forged with a minimum of %d", len(res.Regions), cfg.MinForgedBlocks)
+	}
+	cfg.MinForgedBlocks = n
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged {
+		t.Errorf("%d forged blocks are not reported forged with a minimum of %d", n, cfg.MinForgedBlocks)
+	}
+}
+
+func TestMinForgedBlocksStrayMatch(t *testing.T) {
+	// A single coincidental match of two distant blocks is kept by the neighbor filter, as its own dominant shift.
+	stray := []vector{{xa: 10, ya: 10, xb: 138, yb: 138}}
+	forged, isForged := filterOutNeighbors(stray, DefaultConfig)
+	if len(forged) != 1 || !isForged {
+		t.Fatalf("expected the stray match to pass the neighbor filter, kept %d pairs", len(forged))
+	}
+	if forgedVerdict(forged, isForged, 1, DefaultConfig) {
+		t.Errorf("a single stray match is reported forged with the default minimum of %d forged blocks", DefaultConfig.MinForgedBlocks)
+	}
+	cfg := DefaultConfig
+	cfg.MinForgedBlocks = 1
+	if !forgedVerdict(forged, isForged, 1, cfg) {
+		t.Error("a single stray match is not reported forged with a minimum of 1 forged block")
+	}
+
+	// A second pair of the same copy reaches the default minimum.
+	forged, isForged = filterOutNeighbors(append(stray, vector{xa: 11, ya: 10, xb: 139, yb: 138}), DefaultConfig)
+	if !forgedVerdict(forged, isForged, 1, DefaultConfig) {
+		t.Errorf("%d forged blocks are not reported forged with the default minimum", len(forged))
+	}
+}
+
+func TestDetectionPrecision(t *testing.T) {
+	for _, tc := range []struct {
+		forged, suspicious int
+		want               float64
+	}{
+		{0, 0, 0},
+		{0, 10, 0},
+		// A single forged block among many suspicious ones is a low precision, not over 50%.
+		{1, 10, 10},
+		{3, 4, 75},
+		{4, 4, 100},
+	} {
+		if got := detectionPrecision(tc.forged, tc.suspicious); got != tc.want {
+			t.Errorf("%d of %d suspicious blocks forged: precision %v, expected %v", tc.forged, tc.suspicious, got, tc.want)
+		}
+	}
+}
+
+func TestMinForgedBlocksDefault(t *testing.T) {
+	if got := (Config{}).minForgedBlocks(); got != DefaultConfig.MinForgedBlocks || got != 2 {
+		t.Errorf("the unset minimum forged blocks is %d, expected the default 2", got)
+	}
+	if got := (Config{MinForgedBlocks: 1}).minForgedBlocks(); got != 1 {
+		t.Errorf("the minimum forged blocks is %d, expected 1", got)
+	}
+}
+
+func TestDetectTimeout(t *testing.T) {
+	img, _, _, err := SyntheticImage(1024, 1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := DetectContext(ctx, img, DefaultConfig)
+	if !errors.Is(err, context.DeadlineExceeded) || res != nil {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	// The error reports the partial progress.
+	if !strings.Contains(err.Error(), "detection aborted after processing") || !strings.Contains(err.Error(), " blocks") {
+		t.Errorf("the timeout error doesn't report the processed blocks: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the detection was aborted after %v", elapsed)
+	}
+}
+
+func TestSortRegions(t *testing.T) {
+	regions := []image.Rectangle{
+		image.Rect(30, 10, 34, 14), image.Rect(5, 20, 9, 24), image.Rect(5, 10, 9, 16), image.Rect(5, 10, 9, 14), image.Rect(0, 10, 4, 14),
+	}
+	sortRegions(regions)
+	want := []image.Rectangle{
+		image.Rect(0, 10, 4, 14), image.Rect(5, 10, 9, 14), image.Rect(5, 10, 9, 16), image.Rect(30, 10, 34, 14), image.Rect(5, 20, 9, 24),
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("the regions are not sorted by their top left corner:\n got %v\nwant %v", regions, want)
+	}
+}
+
+func TestSortRegionPairs(t *testing.T) {
+	regions := []image.Rectangle{image.Rect(30, 10, 34, 14), image.Rect(5, 20, 9, 24), image.Rect(0, 10, 4, 14)}
+	destinations := []image.Rectangle{image.Rect(0, 0, 4, 4), image.Rect(50, 50, 54, 54), image.Rect(20, 40, 24, 44)}
+	sortRegionPairs(regions, destinations)
+	wantRegions := []image.Rectangle{image.Rect(0, 10, 4, 14), image.Rect(30, 10, 34, 14), image.Rect(5, 20, 9, 24)}
+	wantDestinations := []image.Rectangle{image.Rect(20, 40, 24, 44), image.Rect(0, 0, 4, 4), image.Rect(50, 50, 54, 54)}
+	if !reflect.DeepEqual(regions, wantRegions) || !reflect.DeepEqual(destinations, wantDestinations) {
+		t.Errorf("the destinations didn't follow their regions:\n got %v -> %v\nwant %v -> %v", regions, destinations, wantRegions, wantDestinations)
+	}
+}
+
+func TestDetectDeterministicJSON(t *testing.T) {
+	img, _, _, err := SyntheticImage(256, 256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The parallel extraction finishes the blocks in a different order on each run.
+	cfg := DefaultConfig
+	cfg.Workers = 4
+	var runs [2][]byte
+	for i := range runs {
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runs[i], err = json.Marshal(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(runs[0], runs[1]) {
+		t.Error("two runs of the detection produced different JSON results")
+	}
+}
+
+func TestDetectRegionOfInterest(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.ROI = image.Rect(16, 16, 240, 240)
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged {
+		t.Fatalf("the copy inside the region of interest is not detected")
+	}
+	// The results are reported in the full image space.
+	shift := dst.Min.Sub(src.Min)
+	if len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("expected the dominant shift %v, got %+v", shift, res.Shifts)
+	}
+	inside := func(rects []image.Rectangle, want image.Rectangle) bool {
+		var found bool
+		for _, r := range rects {
+			// The regions span two blocks, so only the matched blocks lie within the region of interest.
+			if !r.Min.In(cfg.ROI) {
+				t.Errorf("the region %v starts outside of the region of interest %v", r, cfg.ROI)
+			}
+			found = found || r.Overlaps(want)
+		}
+		return found
+	}
+	if !inside(res.Regions, src) {
+		t.Errorf("no region of the %v copied region has been detected", src)
+	}
+	if !inside(res.Destinations, dst) {
+		t.Errorf("no destination of the %v copy has been detected", dst)
+	}
+
+	// The copy outside the region of interest is not analyzed.
+	cfg.ROI = image.Rect(0, 0, 128, 128)
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged {
+		t.Errorf("the copy outside the region of interest is detected: %+v", res.Regions)
+	}
+
+	cfg.ROI = image.Rect(200, 200, 300, 300)
+	if _, err := Detect(img, cfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("got the error %v for a region of interest outside of the image, expected %v", err, ErrInvalidConfig)
+	}
+	cfg.ROI = image.Rect(0, 0, 3, 3)
+	if _, err := Detect(img, cfg); !errors.Is(err, ErrImageTooSmall) {
+		t.Errorf("got the error %v for a region of interest smaller than a block, expected %v", err, ErrImageTooSmall)
+	}
+}
+
+func TestShiftConcentrationVerdict(t *testing.T) {
+	forged, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noise := randomNRGBA(128, 128, 1)
+	// The low offset threshold lets the coincidental matches of the noise report it as forged.
+	cfg := DefaultConfig
+	cfg.OffsetThreshold = 5
+	detect := func(img image.Image) *Result {
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	resForged, resNoise := detect(forged), detect(noise)
+	if !resForged.Forged || !resNoise.Forged {
+		t.Fatalf("both images are expected to be forged without the concentration check: %v %v", resForged.Forged, resNoise.Forged)
+	}
+	// The copy concentrates the matches on its shift much more than the noise does.
+	if resForged.ShiftConcentration < 10*resNoise.ShiftConcentration {
+		t.Fatalf("the shift concentration of the copy is %v, and %v for the noise", resForged.ShiftConcentration, resNoise.ShiftConcentration)
+	}
+
+	cfg.MinShiftConcentration = 0.002
+	if res := detect(forged); !res.Forged {
+		t.Errorf("the concentrated copy is not detected with the minimum concentration %v: %v", cfg.MinShiftConcentration, res.ShiftConcentration)
+	}
+	if res := detect(noise); res.Forged {
+		t.Errorf("the diffuse noise is reported as forged with the minimum concentration %v: %v", cfg.MinShiftConcentration, res.ShiftConcentration)
+	}
+}
+
+// authenticImage returns the synthetic image with unrelated content in place of its copy,
+// and the region the copy has been replaced in.
+func authenticImage(t *testing.T, w, h int) (*image.NRGBA, image.Rectangle) {
+	forged, _, dst, err := SyntheticImage(w, h, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _, err := SyntheticImage(w, h, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(forged.Bounds())
+	draw.Draw(img, img.Bounds(), forged, image.Point{}, draw.Src)
+	draw.Draw(img, dst, other, dst.Min, draw.Src)
+	return img, dst
+}
+
+// randomFeatures returns n features of random positions with a few distinct coefficients,
+// so the sorted order holds many runs of similar neighbors.
+func randomFeatures(n int, seed int64) []feature {
+	r := rand.New(rand.NewSource(seed))
+	feats := make([]feature, n)
+	for i := range feats {
+		feats[i] = feature{x: r.Intn(2000), y: r.Intn(2000), coef: float64(r.Intn(50))}
+	}
+	return feats
+}
+
+func TestMatchFeaturesParallel(t *testing.T) {
+	feats := randomFeatures(50000, 1)
+
+	for _, window := range []int{1, 3} {
+		cfg := DefaultConfig
+		cfg.Workers, cfg.MatchWindow = 1, window
+		want := matchFeatures(append([]feature(nil), feats...), cfg, nil)
+		if len(want) == 0 {
+			t.Fatal("expected shift vectors from the sequential matching")
+		}
+
+		for _, workers := range []int{2, 7, 16} {
+			cfg.Workers = workers
+			got := matchFeatures(append([]feature(nil), feats...), cfg, nil)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("window %d, %d workers: got %d vectors, different from the %d sequential vectors", window, workers, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestMatchWindow(t *testing.T) {
+	// The true match of the first block is its second neighbor in the sorted order: the block between them
+	// is similar to both, but farther from them than the maximum shift.
+	feats := []feature{
+		{x: 0, y: 0, coef: 10},
+		{x: 200, y: 0, coef: 10.1},
+		{x: 50, y: 0, coef: 10.2},
+	}
+	cfg := DefaultConfig
+	cfg.MaxShift = 100
+	for _, tt := range []struct {
+		window int
+		want   []vector
+	}{
+		{1, nil},
+		{2, []vector{{xa: 0, ya: 0, xb: 50, yb: 0, offsetX: 50}}},
+	} {
+		cfg.MatchWindow = tt.window
+		if got := matchFeatures(append([]feature(nil), feats...), cfg, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("window %d: got the vectors %+v, expected %+v", tt.window, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkMatchFeatures compares the sequential and the concurrent matching of a large feature set.
+func BenchmarkMatchFeatures(b *testing.B) {
+	feats := randomFeatures(200000, 1)
+	for _, workers := range []int{1, 2, 4, 8} {
+		cfg := DefaultConfig
+		cfg.Workers = workers
+		b.Run(fmt.Sprintf("workers/%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matchFeatures(append([]feature(nil), feats...), cfg, nil)
+			}
+		})
+	}
+}
+
+// BenchmarkDetect measures the whole detection of the synthetic images, which are identical on every machine.
+func BenchmarkDetect(b *testing.B) {
+	for _, size := range []int{128, 256, 512} {
+		img, _, _, err := SyntheticImage(size, size, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Detect(img, DefaultConfig); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleBlockImage(t *testing.T) {
+	cfg := DefaultConfig
+	img := randomNRGBA(cfg.BlockSize, cfg.BlockSize, 1)
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.InsufficientBlocks || res.Forged || len(res.Regions) != 0 || res.Mask == nil || res.Mask.Bounds() != img.Bounds() {
+		t.Errorf("expected a not forged result with insufficient blocks and an empty mask, got %+v", res)
+	}
+
+	// The region of interest of the block size holds a single block too.
+	cfg.ROI = image.Rect(8, 8, 8+cfg.BlockSize, 8+cfg.BlockSize)
+	if res, err = Detect(randomNRGBA(32, 32, 1), cfg); err != nil || !res.InsufficientBlocks || res.Mask.Bounds() != image.Rect(0, 0, 32, 32) {
+		t.Errorf("expected insufficient blocks in the region of interest, got %+v, %v", res, err)
+	}
+
+	// Two blocks can be matched.
+	cfg.ROI = image.Rectangle{}
+	if res, err = Detect(randomNRGBA(cfg.BlockSize+1, cfg.BlockSize, 1), cfg); err != nil || res.InsufficientBlocks || !res.Analyzed {
+		t.Errorf("expected the two blocks to be analyzed, got %+v, %v", res, err)
+	}
+}
+
+func TestDegenerateImageNotAnalyzed(t *testing.T) {
+	// The blocks of a flat image have no texture energy, so they are all gated out.
+	flat := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{color.NRGBA{90, 120, 150, 255}}, image.Point{}, draw.Src)
+	cfg := DefaultConfig
+	cfg.MinTextureEnergy = 1
+	res, err := Detect(flat, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Analyzed || res.Forged {
+		t.Errorf("expected the flat image not to be analyzed, got %+v", res)
+	}
+
+	// The fully transparent blocks are skipped as well.
+	cfg = DefaultConfig
+	cfg.MinAlpha = 0.5
+	if res, err = Detect(image.NewNRGBA(image.Rect(0, 0, 64, 64)), cfg); err != nil || res.Analyzed {
+		t.Errorf("expected the transparent image not to be analyzed, got %+v, %v", res, err)
+	}
+	cfg.MinAlpha = 0
+	if res, err = Detect(randomNRGBA(64, 64, 1), cfg); err != nil || !res.Analyzed {
+		t.Errorf("expected the textured image to be analyzed, got %+v, %v", res, err)
+	}
+}
+
+func TestOffsetFraction(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the large image takes a few seconds")
+	}
+	cfg := DefaultConfig
+	cfg.MaxImageSize = 0
+	cfg.Step = 2
+	cfg.Workers = 4
+	cfg.OffsetFraction = 0.0035
+	// The copies cover the same fraction of both images. The relative threshold grows with the blocks of the image,
+	// so it is not exceeded by the many coincidental matches of the large image, nor too high for the small copy.
+	thresholds := make(map[int]int)
+	for _, size := range []int{256, 1024} {
+		img, src, dst, err := SyntheticImage(size, size, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shift := dst.Min.Sub(src.Min)
+		if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+			t.Errorf("%dx%d: expected the copy shifted by %v to be detected, got %+v", size, size, shift, res.Shifts)
+		}
+		thresholds[size] = cfg.offsetThreshold(countBlocks(img.Bounds(), cfg.BlockSize, cfg.Step))
+	}
+
+	// The absolute threshold of the large image misses the copy of the small image.
+	img, _, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.OffsetFraction = 0
+	cfg.OffsetThreshold = thresholds[1024]
+	if res, err := Detect(img, cfg); err != nil || res.Forged {
+		t.Errorf("expected the offset threshold %d to miss the small copy (%v)", cfg.OffsetThreshold, err)
+	}
+	if thresholds[256] >= thresholds[1024]/10 {
+		t.Errorf("the relative offset thresholds %v are not proportional to the blocks", thresholds)
+	}
+}
+
+func TestPreprocess(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := cloneNRGBA(img)
+	cfg := DefaultConfig
+	want := preprocess(img, cfg)
+	if !reflect.DeepEqual(img.Pix, orig.Pix) {
+		t.Fatal("the preprocessing changes the source image")
+	}
+	if want.Bounds() != img.Bounds() {
+		t.Errorf("the preprocessed image has the bounds %v, expected %v", want.Bounds(), img.Bounds())
+	}
+	cfg.Gamma = 1
+	if got := preprocess(img, cfg); !reflect.DeepEqual(got.Pix, want.Pix) {
+		t.Error("the gamma of 1 changes the preprocessed image")
+	}
+	cfg.Gamma = 2.2
+	if got := preprocess(img, cfg); reflect.DeepEqual(got.Pix, want.Pix) {
+		t.Error("the gamma of 2.2 doesn't change the preprocessed image")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"image"
+	"time"
+)
+
+// Detector runs the forgery detection with the same settings on many images. It retains the feature
+// and the shift vector buffers between the runs, so a long-lived detector processes the images without
+// reallocating the large slices. A Detector is not safe for concurrent use.
+type Detector struct {
+	// Config contains the detection settings.
+	Config Config
+
+	features []feature
+	vectors  []vector
+
+	// events receives the progress events when the detection has been started by DetectProgress.
+	events  chan ProgressEvent
+	started time.Time
+}
+
+// Detect analyzes the image with the detector settings and detects the copy-move forgeries.
+// The Reset method must be called between the runs, otherwise use a fresh detector.
+func (d *Detector) Detect(src image.Image) (*Result, error) {
+	return d.DetectContext(context.Background(), src)
+}
+
+// Reset clears the features and the shift vectors of the previous run, retaining the allocated capacity.
+// The shift vectors are accumulated over the runs until the detector is reset.
+func (d *Detector) Reset() {
+	d.features = d.features[:0]
+	d.vectors = d.vectors[:0]
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDetectorReset(t *testing.T) {
+	first, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, _, err := SyntheticImage(128, 96, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Detector{Config: DefaultConfig}
+	if _, err := d.Detect(first); err != nil {
+		t.Fatal(err)
+	}
+	d.Reset()
+	if len(d.features) != 0 || len(d.vectors) != 0 {
+		t.Fatalf("the reset detector retains %d features and %d vectors", len(d.features), len(d.vectors))
+	}
+	features, vectors := cap(d.features), cap(d.vectors)
+	if features == 0 || vectors == 0 {
+		t.Fatalf("the reset detector doesn't retain the capacity of its buffers: %d features, %d vectors", features, vectors)
+	}
+
+	res, err := d.Detect(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second image is smaller, so its buffers fit in the retained ones.
+	if cap(d.features) != features {
+		t.Errorf("the feature buffer has been reallocated from %d to %d", features, cap(d.features))
+	}
+
+	// The result of the second run doesn't depend on the first one.
+	fresh, err := Detect(second, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the result of the reset detector differs from the result of a fresh detection:\n%s\n%s", got, want)
+	}
+}
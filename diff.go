@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// diffGain is the amplification factor of the per-pixel differences,
+// which makes the subtle retouches visible in the difference image.
+const diffGain = 10
+
+// difference returns the amplified absolute per-pixel difference between two images of the same size.
+// The images are aligned on their top left corner. The identical pixels are black in the result.
+func difference(a, b image.Image) (*image.NRGBA, error) {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return nil, fmt.Errorf("%w: %v and %v", ErrSizeMismatch, a.Bounds().Size(), b.Bounds().Size())
+	}
+	imgA, imgB := imgToNRGBA(a), imgToNRGBA(b)
+	dst := image.NewNRGBA(imgA.Bounds())
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			d := int(imgA.Pix[i+c]) - int(imgB.Pix[i+c])
+			if d < 0 {
+				d = -d
+			}
+			dst.Pix[i+c] = uint8(clampInt(d*diffGain, 0, 255))
+		}
+		dst.Pix[i+3] = 255
+	}
+	return dst, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDifferenceIdentical(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 48, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := difference(img, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Bounds().Size() != img.Bounds().Size() {
+		t.Fatalf("the difference is %v, expected the size of the images %v", diff.Bounds().Size(), img.Bounds().Size())
+	}
+	for i := 0; i < len(diff.Pix); i += 4 {
+		if diff.Pix[i] != 0 || diff.Pix[i+1] != 0 || diff.Pix[i+2] != 0 || diff.Pix[i+3] != 255 {
+			t.Fatalf("the difference of identical images has the pixel %v, expected opaque black", diff.Pix[i:i+4])
+		}
+	}
+}
+
+func TestDifferenceAmplified(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	// The second image has another origin, the images are aligned on their top left corner.
+	b := image.NewNRGBA(image.Rect(10, 10, 14, 14))
+	a.SetNRGBA(1, 2, color.NRGBA{100, 50, 0, 255})
+	b.SetNRGBA(11, 12, color.NRGBA{103, 10, 0, 255})
+	diff, err := difference(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := diff.NRGBAAt(1, 2), (color.NRGBA{3 * diffGain, 255, 0, 255}); got != want {
+		t.Errorf("the difference is %v, expected %v", got, want)
+	}
+	if got := diff.NRGBAAt(2, 1); got != (color.NRGBA{0, 0, 0, 255}) {
+		t.Errorf("the difference of the identical pixels is %v", got)
+	}
+}
+
+func TestDifferenceSizeMismatch(t *testing.T) {
+	a, b := image.NewRGBA(image.Rect(0, 0, 8, 8)), image.NewRGBA(image.Rect(0, 0, 8, 9))
+	if _, err := difference(a, b); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}
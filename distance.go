@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// Metric is the distance metric used to compare the block positions.
+type Metric int
+
+const (
+	// Euclidean is the straight line (L2) distance.
+	Euclidean Metric = iota
+	// Manhattan is the sum of the absolute differences (L1) distance.
+	Manhattan
+	// Chebyshev is the greatest of the absolute differences (L∞) distance.
+	Chebyshev
+)
+
+// metricNames maps the metric names accepted on the command line to their values.
+var metricNames = map[string]Metric{
+	"euclidean": Euclidean,
+	"manhattan": Manhattan,
+	"chebyshev": Chebyshev,
+}
+
+// parseMetric returns the metric corresponding to its name.
+func parseMetric(name string) (Metric, error) {
+	metric, ok := metricNames[strings.ToLower(name)]
+	if !ok {
+		return 0, invalidConfig("unknown distance metric %q", name)
+	}
+	return metric, nil
+}
+
+// distance computes the distance between two points separated by dx and dy using the provided metric.
+func distance(metric Metric, dx, dy float64) float64 {
+	switch metric {
+	case Manhattan:
+		return math.Abs(dx) + math.Abs(dy)
+	case Chebyshev:
+		return math.Max(math.Abs(dx), math.Abs(dy))
+	default:
+		return math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		metric Metric
+		dx, dy float64
+		want   float64
+	}{
+		{Euclidean, 3, -4, 5},
+		{Manhattan, 3, -4, 7},
+		{Chebyshev, 3, -4, 4},
+		{Euclidean, 0, 0, 0},
+		{Manhattan, -2, 0, 2},
+		{Chebyshev, -6, 5, 6},
+	} {
+		if got := distance(tc.metric, tc.dx, tc.dy); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("distance(%v, %v, %v) = %v, expected %v", tc.metric, tc.dx, tc.dy, got, tc.want)
+		}
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for name, want := range map[string]Metric{"euclidean": Euclidean, "Manhattan": Manhattan, "CHEBYSHEV": Chebyshev} {
+		if got, err := parseMetric(name); err != nil || got != want {
+			t.Errorf("parseMetric(%q) = %v, %v, expected %v", name, got, err, want)
+		}
+	}
+	if _, err := parseMetric("cosine"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown metric, got %v", err)
+	}
+}
+
+func TestMetricFilters(t *testing.T) {
+	// The displacements of the pairs are (20, 20), (30, 0) and (15, 15), at the Euclidean distances
+	// 28.3, 30 and 21.2, the Manhattan distances 40, 30 and 30, and the Chebyshev distances 20, 30 and 15.
+	vect := []vector{
+		{xa: 0, ya: 0, xb: 20, yb: 20},
+		{xa: 0, ya: 0, xb: 30, yb: 0},
+		{xa: 0, ya: 0, xb: 15, yb: 15},
+	}
+	for _, tc := range []struct {
+		metric  Metric
+		forged  int
+		shifted bool
+	}{
+		{Euclidean, 2, false},
+		{Manhattan, 3, false},
+		{Chebyshev, 1, true},
+	} {
+		cfg := DefaultConfig
+		cfg.Metric, cfg.ForgeryThreshold, cfg.MaxShift = tc.metric, 25, 25
+
+		if forged, _ := filterOutNeighbors(vect, cfg); len(forged) != tc.forged {
+			t.Errorf("%v: %d pairs are distant enough, expected %d", tc.metric, len(forged), tc.forged)
+		}
+		// The blocks displaced by (20, 20) are within the maximum shift for the Chebyshev metric only.
+		v := analyzeBlocks(feature{x: 0, y: 0}, feature{x: 20, y: 20}, cfg)
+		if (v != nil) != tc.shifted {
+			t.Errorf("%v: the shift vector within the maximum shift is %v, expected %v", tc.metric, v != nil, tc.shifted)
+		}
+	}
+}
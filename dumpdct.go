@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+)
+
+// dumpDCT writes the DCT coefficient matrices of the Y,R,G,B planes of the blocks of the preprocessed
+// YUV image in a tabular text format. Each matrix is preceded by a header line with the block position
+// and the plane, followed by a row per vertical frequency with the tab separated coefficients of
+// the horizontal frequencies. When a block position is provided, only that block is written.
+func dumpDCT(w io.Writer, img *image.RGBA, blockSize, step int, block *image.Point) error {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	dump := func(x, y int) {
+		sub := img.SubImage(image.Rect(x, y, x+blockSize, y+blockSize)).(*image.RGBA)
+		yPlane, rPlane, gPlane, bPlane, _, _, _ := blockPlanes(sub, blockSize)
+		coefs := blockPlaneCoefs(blockSize, yPlane, rPlane, gPlane, bPlane)
+
+		size := blockSize * blockSize
+		for i, name := range []string{"Y", "R", "G", "B"} {
+			fmt.Fprintf(bw, "block %d,%d %s\n", x, y, name)
+			plane := coefs[i*size : (i+1)*size]
+			for v := 0; v < blockSize; v++ {
+				for u := 0; u < blockSize; u++ {
+					if u > 0 {
+						bw.WriteByte('\t')
+					}
+					fmt.Fprintf(bw, "%.4f", plane[v*blockSize+u])
+				}
+				bw.WriteByte('\n')
+			}
+		}
+	}
+
+	if block != nil {
+		if !image.Rect(block.X, block.Y, block.X+blockSize, block.Y+blockSize).In(b) {
+			return fmt.Errorf("the block %d,%d lies outside of the analyzed image bounds %v", block.X, block.Y, b)
+		}
+		dump(block.X, block.Y)
+		return bw.Flush()
+	}
+	for y := b.Min.Y; y <= b.Max.Y-blockSize; y += step {
+		for x := b.Min.X; x <= b.Max.X-blockSize; x += step {
+			dump(x, y)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDumpDCTConstantBlock(t *testing.T) {
+	const blockSize = 4
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{100, 128, 128, 255})
+	}
+	var buf bytes.Buffer
+	block := image.Pt(2, 2)
+	if err := dumpDCT(&buf, img, blockSize, 1, &block); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4*(blockSize+1) {
+		t.Fatalf("got %d lines, expected a header and %d rows for each of the 4 planes:\n%s", len(lines), blockSize, buf.String())
+	}
+	for p, plane := range []string{"Y", "R", "G", "B"} {
+		header := lines[p*(blockSize+1)]
+		if want := fmt.Sprintf("block 2,2 %s", plane); header != want {
+			t.Errorf("got the header %q, expected %q", header, want)
+		}
+		// Only the DC coefficient of a constant block is nonzero.
+		for v, row := range lines[p*(blockSize+1)+1 : (p+1)*(blockSize+1)] {
+			fields := strings.Split(row, "\t")
+			if len(fields) != blockSize {
+				t.Fatalf("the %s row %d has %d coefficients, expected %d", plane, v, len(fields), blockSize)
+			}
+			for u, f := range fields {
+				c, err := strconv.ParseFloat(f, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if dc := u == 0 && v == 0; dc != (c != 0) {
+					t.Errorf("the %s coefficient %d,%d is %v", plane, u, v, c)
+				}
+			}
+		}
+	}
+}
+
+func TestDumpDCTAllBlocks(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := dumpDCT(&buf, img, 4, 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The 8x8 image holds 3x3 blocks of 4 pixels spaced by 2 pixels.
+	if got := strings.Count(buf.String(), "block "); got != 9*4 {
+		t.Errorf("got %d matrices, expected %d", got, 9*4)
+	}
+	if !strings.HasPrefix(buf.String(), "block 0,0 Y\n") || !strings.Contains(buf.String(), "block 4,4 B\n") {
+		t.Errorf("the first or the last block is missing:\n%s", buf.String())
+	}
+
+	block := image.Pt(6, 0)
+	if err := dumpDCT(&buf, img, 4, 2, &block); err == nil {
+		t.Error("no error for a block outside of the image")
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"image"
+)
+
+// EmbeddedResult is the detection result with the analyzed image, and optionally the annotated image, embedded
+// as PNG data URLs. A single JSON record then carries everything needed to reproduce the visualization.
+type EmbeddedResult struct {
+	*Result
+	// Image is the analyzed image, before the preprocessing and the downscaling.
+	Image string `json:"image,omitempty"`
+	// Annotated is the image annotated with the detected forgeries, when embedded.
+	Annotated string `json:"annotated,omitempty"`
+}
+
+// newEmbeddedResult embeds the images into the result. The nil images are not embedded.
+func newEmbeddedResult(res *Result, src, annotated image.Image) (*EmbeddedResult, error) {
+	embedded := &EmbeddedResult{Result: res}
+	for _, e := range []struct {
+		img image.Image
+		url *string
+	}{{src, &embedded.Image}, {annotated, &embedded.Annotated}} {
+		if e.img == nil {
+			continue
+		}
+		url, err := dataURL(e.img)
+		if err != nil {
+			return nil, err
+		}
+		*e.url = string(url)
+	}
+	return embedded, nil
+}
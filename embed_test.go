@@ -0,0 +1,86 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// decodeDataURL decodes the image of the PNG data URL.
+func decodeDataURL(t *testing.T, url string) image.Image {
+	t.Helper()
+	const prefix = "data:image/png;base64,"
+	if !strings.HasPrefix(url, prefix) {
+		t.Fatalf("unexpected data URL %.40q", url)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(url, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestEmbeddedResult(t *testing.T) {
+	img, _, _, err := SyntheticImage(160, 96, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded, err := newEmbeddedResult(res, img, annotate(img, res, DefaultConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(embedded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The embedded images are added to the fields of the native result.
+	var decoded struct {
+		Forged    bool   `json:"forged"`
+		Image     string `json:"image"`
+		Annotated string `json:"annotated"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Forged != res.Forged {
+		t.Errorf("the embedded result is forged: %v, expected %v", decoded.Forged, res.Forged)
+	}
+	for name, url := range map[string]string{"image": decoded.Image, "annotated": decoded.Annotated} {
+		if size := decodeDataURL(t, url).Bounds().Size(); size != img.Bounds().Size() {
+			t.Errorf("the embedded %s is %v, expected %v", name, size, img.Bounds().Size())
+		}
+	}
+	// The embedded image is lossless.
+	back := imgToNRGBA(decodeDataURL(t, decoded.Image))
+	if !bytes.Equal(back.Pix, img.Pix) {
+		t.Error("the embedded image differs from the input")
+	}
+
+	// The images which are not requested are not embedded.
+	embedded, err = newEmbeddedResult(res, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(embedded); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(`"annotated"`)) || !bytes.Contains(data, []byte(`"image"`)) {
+		t.Errorf("unexpected fields of the result embedding the image only: %.200s", data)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "image"
+
+// equalizeLuminance spreads the luminance of a YUV image over the full tonal range by histogram equalization.
+// The luminance is stored in the red component, and the chroma is left unchanged to avoid the color shifts.
+func equalizeLuminance(img *image.RGBA) {
+	b := img.Bounds()
+	var hist [256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			hist[img.Pix[i]]++
+			i += 4
+		}
+	}
+
+	// Map each luminance value through the cumulative histogram, with the darkest value mapped to 0.
+	var cdf, cdfMin int
+	var lut [256]uint8
+	total := b.Dx() * b.Dy()
+	for v, n := range hist {
+		cdf += n
+		if cdfMin == 0 {
+			cdfMin = cdf
+		}
+		if total > cdfMin {
+			lut[v] = uint8(round(float64(cdf-cdfMin) * 255 / float64(total-cdfMin)))
+		} else {
+			lut[v] = uint8(v)
+		}
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Pix[i] = lut[img.Pix[i]]
+			i += 4
+		}
+	}
+}
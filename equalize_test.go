@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestEqualizeLuminance(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	for x := 0; x < 4; x++ {
+		copy(img.Pix[4*x:], []uint8{uint8(100 + x), 50, 200, 255})
+	}
+	equalizeLuminance(img)
+	for x, want := range []uint8{0, 85, 170, 255} {
+		if y := img.Pix[4*x]; y != want {
+			t.Errorf("the luminance %d is equalized to %d, expected %d", 100+x, y, want)
+		}
+		if u, v := img.Pix[4*x+1], img.Pix[4*x+2]; u != 50 || v != 200 {
+			t.Errorf("the chroma of the pixel %d changed to %d, %d", x, u, v)
+		}
+	}
+
+	// A flat image has no tonal range to spread.
+	flat := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range flat.Pix {
+		flat.Pix[i] = 77
+	}
+	equalizeLuminance(flat)
+	for i, v := range flat.Pix {
+		if v != 77 {
+			t.Fatalf("the flat image value %d changed to %d", i, v)
+		}
+	}
+}
+
+func TestEqualizeLowContrastMatches(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tonal range is compressed to 16 levels.
+	for i := range img.Pix {
+		if i%4 != 3 {
+			img.Pix[i] = 120 + img.Pix[i]/16
+		}
+	}
+	shift := dst.Min.Sub(src.Min)
+	count := func(equalize bool) int {
+		cfg := DefaultConfig
+		cfg.Equalize = equalize
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range res.Shifts {
+			if s.X == float64(shift.X) && s.Y == float64(shift.Y) {
+				return s.Count
+			}
+		}
+		return 0
+	}
+	if plain, equalized := count(false), count(true); equalized <= plain {
+		t.Errorf("the copy is matched by %d pairs after the equalization, not more than %d without it", equalized, plain)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when the input image format cannot be decoded.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrImageTooSmall is returned when the image cannot hold a single block.
+	ErrImageTooSmall = errors.New("image too small")
+	// ErrInvalidConfig is returned when the detection settings are not valid.
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// ErrSizeMismatch is returned when the compared images don't have the same size.
+	ErrSizeMismatch = errors.New("image size mismatch")
+	// ErrCorruptImage is returned when the image decoder breaks down on the malformed image data.
+	ErrCorruptImage = errors.New("corrupt image")
+)
+
+// invalidConfig returns an ErrInvalidConfig error detailing the invalid setting.
+func invalidConfig(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+}
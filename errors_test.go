@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectErrors(t *testing.T) {
+	valid := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	invalid := DefaultConfig
+	invalid.BlockSize = 1
+
+	for _, tc := range []struct {
+		name string
+		img  image.Image
+		cfg  Config
+		err  error
+	}{
+		{"too small", image.NewRGBA(image.Rect(0, 0, 2, 2)), DefaultConfig, ErrImageTooSmall},
+		{"empty", image.NewRGBA(image.Rectangle{}), DefaultConfig, ErrImageTooSmall},
+		{"invalid config", valid, invalid, ErrInvalidConfig},
+	} {
+		if _, err := Detect(tc.img, tc.cfg); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestLoadImageErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(path, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadImage(path); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+	if _, err := loadImage(filepath.Join(dir, "missing.png")); err == nil || errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected a file error, got %v", err)
+	}
+}
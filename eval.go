@@ -0,0 +1,59 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// Evaluation compares the forgery mask of a detection with the ground truth mask of the forged regions.
+type Evaluation struct {
+	// Forged is the verdict of the detection.
+	Forged bool `json:"forged"`
+	// TruePositives is the number of the forged pixels marked in the mask.
+	TruePositives int `json:"true_positives"`
+	// FalsePositives is the number of the authentic pixels marked in the mask.
+	FalsePositives int `json:"false_positives"`
+	// FalseNegatives is the number of the forged pixels missed by the mask.
+	FalseNegatives int `json:"false_negatives"`
+	// Precision is the fraction of the marked pixels which are forged (0 when no pixel is marked).
+	Precision float64 `json:"precision"`
+	// Recall is the fraction of the forged pixels which are marked (0 when no pixel is forged).
+	Recall float64 `json:"recall"`
+	// F1 is the harmonic mean of the precision and the recall.
+	F1 float64 `json:"f1"`
+}
+
+// evaluateMask compares the forgery mask with the ground truth mask, whose white pixels mark the forged regions.
+// Both masks are aligned with their origin and must have the same size.
+func evaluateMask(mask *image.Gray, truth image.Image) (Evaluation, error) {
+	var e Evaluation
+	mb, tb := mask.Bounds(), truth.Bounds()
+	if mb.Size() != tb.Size() {
+		return e, ErrSizeMismatch
+	}
+	for y := 0; y < mb.Dy(); y++ {
+		for x := 0; x < mb.Dx(); x++ {
+			marked := mask.GrayAt(mb.Min.X+x, mb.Min.Y+y).Y >= 128
+			forged := color.GrayModel.Convert(truth.At(tb.Min.X+x, tb.Min.Y+y)).(color.Gray).Y >= 128
+			switch {
+			case marked && forged:
+				e.TruePositives++
+			case marked:
+				e.FalsePositives++
+			case forged:
+				e.FalseNegatives++
+			}
+		}
+	}
+
+	if marked := e.TruePositives + e.FalsePositives; marked > 0 {
+		e.Precision = float64(e.TruePositives) / float64(marked)
+	}
+	if forged := e.TruePositives + e.FalseNegatives; forged > 0 {
+		e.Recall = float64(e.TruePositives) / float64(forged)
+	}
+	if e.Precision+e.Recall > 0 {
+		e.F1 = 2 * e.Precision * e.Recall / (e.Precision + e.Recall)
+	}
+	return e, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestEvaluateMask(t *testing.T) {
+	white := &image.Uniform{color.Gray{255}}
+	// The detection marks 20x10 pixels, 10x10 of which overlap the 10x20 forged pixels.
+	mask := image.NewGray(image.Rect(0, 0, 40, 40))
+	draw.Draw(mask, image.Rect(0, 0, 20, 10), white, image.Point{}, draw.Src)
+	truth := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(truth, truth.Bounds(), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	draw.Draw(truth, image.Rect(10, 0, 20, 20), white, image.Point{}, draw.Src)
+
+	e, err := evaluateMask(mask, truth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.TruePositives != 100 || e.FalsePositives != 100 || e.FalseNegatives != 100 {
+		t.Errorf("expected 100 true positive, false positive and false negative pixels, got %+v", e)
+	}
+	if math.Abs(e.Precision-0.5) > 1e-9 || math.Abs(e.Recall-0.5) > 1e-9 || math.Abs(e.F1-0.5) > 1e-9 {
+		t.Errorf("expected the precision, the recall and the F1 score of 0.5, got %+v", e)
+	}
+
+	// Nothing is marked nor forged.
+	if e, err := evaluateMask(image.NewGray(mask.Bounds()), image.NewGray(mask.Bounds())); err != nil || e != (Evaluation{}) {
+		t.Errorf("expected an empty evaluation of the empty masks, got %+v, %v", e, err)
+	}
+	if _, err := evaluateMask(mask, image.NewGray(image.Rect(0, 0, 40, 41))); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}
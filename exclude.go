@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// exclusionMap marks the pixels of the analyzed image which are excluded from the detection. It stores
+// the summed-area table of the excluded pixels, so the blocks overlapping them are found in constant time.
+type exclusionMap struct {
+	width int
+	sum   []int
+}
+
+// newExclusionMap maps the exclusion mask of the image onto the analyzed image of the provided size.
+// The mask is aligned with the image origin and the white pixels mark the excluded regions.
+// Only the mask pixels inside the region of interest are mapped, and the analyzed image pixels
+// are scaled down by the provided factor. An analyzed pixel covering any excluded pixel is excluded.
+func newExclusionMap(mask image.Image, roi image.Rectangle, size image.Point, scale float64) *exclusionMap {
+	w, h := size.X, size.Y
+	excluded := make([]bool, w*h)
+	mb := mask.Bounds()
+	for y := roi.Min.Y; y < roi.Max.Y; y++ {
+		for x := roi.Min.X; x < roi.Max.X; x++ {
+			if color.GrayModel.Convert(mask.At(mb.Min.X+x, mb.Min.Y+y)).(color.Gray).Y < 128 {
+				continue
+			}
+			ax := clampInt(int(float64(x-roi.Min.X)/scale), 0, w-1)
+			ay := clampInt(int(float64(y-roi.Min.Y)/scale), 0, h-1)
+			excluded[ay*w+ax] = true
+		}
+	}
+
+	// The summed-area table has an additional leading row and column of zeros.
+	e := &exclusionMap{width: w + 1, sum: make([]int, (w+1)*(h+1))}
+	for y := 0; y < h; y++ {
+		var row int
+		for x := 0; x < w; x++ {
+			if excluded[y*w+x] {
+				row++
+			}
+			e.sum[(y+1)*e.width+x+1] = e.sum[y*e.width+x+1] + row
+		}
+	}
+	return e
+}
+
+// overlaps reports whether the block having its top left corner at x, y contains any excluded pixel.
+func (e *exclusionMap) overlaps(x, y, blockSize int) bool {
+	x1, y1 := x+blockSize, y+blockSize
+	return e.sum[y1*e.width+x1]-e.sum[y*e.width+x1]-e.sum[y1*e.width+x]+e.sum[y*e.width+x] > 0
+}
+
+// checkExcludeMask verifies that the exclusion mask has the same size as the image.
+func checkExcludeMask(mask, img image.Image) error {
+	if mask.Bounds().Size() != img.Bounds().Size() {
+		return fmt.Errorf("%w: the exclusion mask is %v, the image is %v", ErrSizeMismatch, mask.Bounds().Size(), img.Bounds().Size())
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestDetectExcludedWatermark(t *testing.T) {
+	img, _ := authenticImage(t, 256, 256)
+
+	cfg := DefaultConfig
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged {
+		t.Fatalf("the authentic image is reported as forged: %+v", res.Shifts)
+	}
+
+	// The same watermark is stamped along the bottom of the image.
+	const size = 24
+	watermark := randomNRGBA(size, size, 7)
+	mask := image.NewGray(img.Bounds())
+	for _, p := range []image.Point{{8, 224}, {104, 224}, {200, 224}} {
+		r := image.Rectangle{p, p.Add(image.Pt(size, size))}
+		draw.Draw(img, r, watermark, image.Point{}, draw.Src)
+		draw.Draw(mask, r, image.White, image.Point{}, draw.Src)
+	}
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != 96 || res.Shifts[0].Y != 0 {
+		t.Fatalf("the repeated watermark is not matched: %+v", res.Shifts)
+	}
+
+	cfg.Exclude = mask
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged {
+		t.Errorf("the excluded watermark is reported as forged: %+v", res.Shifts)
+	}
+	for _, r := range res.Regions {
+		if mask.GrayAt(r.Min.X, r.Min.Y).Y != 0 {
+			t.Errorf("the region %v starts in the excluded watermark", r)
+		}
+	}
+
+	cfg.Exclude = image.NewGray(image.Rect(0, 0, 128, 128))
+	if _, err := Detect(img, cfg); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got the error %v for a smaller exclusion mask, expected %v", err, ErrSizeMismatch)
+	}
+}
+
+func TestExclusionMapOverlaps(t *testing.T) {
+	mask := image.NewGray(image.Rect(0, 0, 16, 16))
+	mask.Pix[mask.PixOffset(5, 6)] = 255
+	e := newExclusionMap(mask, mask.Bounds(), image.Pt(16, 16), 1)
+	tests := []struct {
+		x, y int
+		want bool
+	}{
+		{0, 0, false},
+		{2, 3, true},
+		{5, 6, true},
+		{6, 6, false},
+		{5, 7, false},
+	}
+	for _, tt := range tests {
+		if got := e.overlaps(tt.x, tt.y, 4); got != tt.want {
+			t.Errorf("the block at %d,%d overlaps the excluded pixel: %v, expected %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	// The analyzed image is downscaled by half.
+	e = newExclusionMap(mask, mask.Bounds(), image.Pt(8, 8), 2)
+	if !e.overlaps(0, 0, 4) || e.overlaps(4, 4, 4) {
+		t.Error("the excluded pixel is not mapped onto the downscaled image")
+	}
+}
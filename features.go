@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FeatureSet selects the block descriptors used for matching. The sets can be combined.
+type FeatureSet int
+
+const (
+	// FeatureDCT are the quantized low frequency DCT coefficients and the average R,G,B values of the block.
+	FeatureDCT FeatureSet = 1 << iota
+	// FeatureSobel are the Sobel gradient orientation histograms of the block, weighted by the gradient magnitude.
+	FeatureSobel
+	// FeatureMeanVar are the luminance mean and variance of the block.
+	FeatureMeanVar
+	// FeatureEntropy is the Shannon entropy of the block luminance histogram.
+	FeatureEntropy
+	// FeatureZernike are the rotation invariant Zernike moment magnitudes of the block luminance.
+	FeatureZernike
+	// FeatureFourierMellin is the scale and rotation invariant Fourier-Mellin descriptor of the block luminance.
+	FeatureFourierMellin
+	// FeatureChannels are the correlations of the R,G and the G,B planes of the block, which are unchanged
+	// by the per-channel gain and offset of a color adjustment.
+	FeatureChannels
+)
+
+// featureNames maps the feature set names accepted on the command line to their values.
+var featureNames = map[string]FeatureSet{
+	"dct":      FeatureDCT,
+	"sobel":    FeatureSobel,
+	"meanvar":  FeatureMeanVar,
+	"entropy":  FeatureEntropy,
+	"zernike":  FeatureZernike,
+	"fm":       FeatureFourierMellin,
+	"channels": FeatureChannels,
+}
+
+// ChannelWeights are the weights of the Y, R, G and B channels in the DCT features: the luminance coefficients
+// are scaled by the Y weight, the DC coefficient and the average of each color channel by its weight.
+type ChannelWeights struct {
+	Y, R, G, B float64
+}
+
+// parseChannelWeights parses the channel weights in the y,r,g,b notation.
+func parseChannelWeights(s string) (*ChannelWeights, error) {
+	var w ChannelWeights
+	if _, err := fmt.Sscanf(s, "%g,%g,%g,%g", &w.Y, &w.R, &w.G, &w.B); err != nil {
+		return nil, invalidConfig("malformed channel weights %q, expected the y,r,g,b format", s)
+	}
+	return &w, nil
+}
+
+// parseFeatureSet parses a comma separated list of feature set names.
+func parseFeatureSet(names string) (FeatureSet, error) {
+	var set FeatureSet
+	for _, name := range strings.Split(names, ",") {
+		f, ok := featureNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, invalidConfig("unknown feature set %q", name)
+		}
+		set |= f
+	}
+	return set, nil
+}
+
+// String returns the comma separated names of the feature sets, in the format of parseFeatureSet.
+func (s FeatureSet) String() string {
+	var names []string
+	for name, f := range featureNames {
+		if s.has(f) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// has reports whether the feature set contains the provided features.
+func (s FeatureSet) has(f FeatureSet) bool {
+	return s&f != 0
+}
+
+// featureDims returns the number of features extracted for each block.
+func (c Config) featureDims() int {
+	var dims int
+	s := c.featureSet()
+	if s.has(FeatureDCT) {
+		dims += c.dctDims()
+	}
+	if s.has(FeatureSobel) {
+		dims += sobelBins
+	}
+	if s.has(FeatureMeanVar) {
+		dims += 2
+	}
+	if s.has(FeatureEntropy) {
+		dims++
+	}
+	if s.has(FeatureZernike) {
+		dims += len(zernikeMoments(c.zernikeOrder()))
+	}
+	if s.has(FeatureFourierMellin) {
+		dims += fmFeatures
+	}
+	if s.has(FeatureChannels) {
+		dims += 2
+	}
+	return dims
+}
+
+// dctWeights returns the weight of each DCT feature of a block, in their order, or nil when the channels are not weighted.
+func (c Config) dctWeights() []float64 {
+	w := c.DCTWeights
+	if w == nil {
+		return nil
+	}
+	weights := make([]float64, 0, c.lowFreqCount()+6)
+	for i := 0; i < c.lowFreqCount(); i++ {
+		weights = append(weights, w.Y)
+	}
+	// The DC coefficients of the R,G,B planes are followed by the averages of the R,B,G planes.
+	return append(weights, w.R, w.G, w.B, w.R, w.B, w.G)
+}
+
+// dctDims returns the number of the DCT features of a block, without the features of the channels weighted by zero.
+func (c Config) dctDims() int {
+	weights := c.dctWeights()
+	if weights == nil {
+		return c.lowFreqCount() + 6
+	}
+	var n int
+	for _, w := range weights {
+		if w != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// weightFeatures scales the features of a block by their weights, and drops the features weighted by zero,
+// which have no influence on the matching. The features are left unchanged when the weights are nil.
+func weightFeatures(feats []feature, weights []float64) []feature {
+	if weights == nil {
+		return feats
+	}
+	kept := feats[:0]
+	for i, f := range feats {
+		if weights[i] != 0 {
+			f.coef *= weights[i]
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// lowFreqCount returns the number of the low frequency luminance DCT coefficients of the DCT features.
+func (c Config) lowFreqCount() int {
+	if c.LowFreqCount == 0 {
+		return defaultLowFreqCount
+	}
+	return c.LowFreqCount
+}
+
+// zernikeOrder returns the maximum order of the Zernike moments.
+func (c Config) zernikeOrder() int {
+	if c.ZernikeOrder == 0 {
+		return defaultZernikeOrder
+	}
+	return c.ZernikeOrder
+}
+
+// featureSet returns the configured feature set, which defaults to the DCT features.
+func (c Config) featureSet() FeatureSet {
+	if c.Features == 0 {
+		return FeatureDCT
+	}
+	return c.Features
+}
+
+// dctFeatures computes the DCT coefficients of the YUV block having its top left corner at bx, by,
+// and returns the low frequency coefficients together with the average R,G,B values as features,
+// and the texture energy of the block.
+func dctFeatures(b *image.RGBA, bx, by int, blockSize, lowFreq int) ([]feature, float64) {
+	yPlane, rPlane, gPlane, bPlane, avr, avg, avb := blockPlanes(b, blockSize)
+	return planeDCTFeatures(bx, by, blockSize, lowFreq, yPlane, rPlane, gPlane, bPlane, avr, avg, avb)
+}
+
+// blockPlanes returns the Y,R,G,B planes of the YUV block in row major order, with the average R,G,B values.
+// The planes are stored contiguously in a single buffer.
+func blockPlanes(b *image.RGBA, blockSize int) (yPlane, rPlane, gPlane, bPlane []float64, avr, avg, avb float64) {
+	size := blockSize * blockSize
+
+	// Obtain the Y,R,G,B planes of the block.
+	planes := make([]float64, 4*size)
+	yPlane, rPlane, gPlane, bPlane = planes[:size:size], planes[size:2*size:2*size], planes[2*size:3*size:3*size], planes[3*size:]
+
+	min := b.Bounds().Min
+	for y := 0; y < blockSize; y++ {
+		i := b.PixOffset(min.X, min.Y+y)
+		for x := 0; x < blockSize; x++ {
+			// Obtain the pixels converted to YUV color space
+			yc, uc, vc := b.Pix[i+0], b.Pix[i+1], b.Pix[i+2]
+			// Convert YUV to RGB and obtain the R,G,B value
+			r, g, bl := color.YCbCrToRGB(yc, uc, vc)
+
+			j := y*blockSize + x
+			yPlane[j], rPlane[j], gPlane[j], bPlane[j] = float64(yc), float64(r), float64(g), float64(bl)
+			avr += float64(r)
+			avg += float64(g)
+			avb += float64(bl)
+			i += 4
+		}
+	}
+	avr /= float64(size)
+	avg /= float64(size)
+	avb /= float64(size)
+	return
+}
+
+// planeDCTFeatures computes the DCT coefficients of the Y,R,G,B planes of a block having its top left corner at bx, by,
+// and returns the lowFreq lowest frequency luminance coefficients in zigzag order and the DC coefficients of the R,G,B
+// planes, together with the provided average R,G,B values as features. It also returns the texture energy of the block.
+func planeDCTFeatures(bx, by, blockSize, lowFreq int, yPlane, rPlane, gPlane, bPlane []float64, avr, avg, avb float64) ([]feature, float64) {
+	features := make([]feature, 0, lowFreq+6)
+	coefs := blockPlaneCoefs(blockSize, yPlane, rPlane, gPlane, bPlane)
+	size := blockSize * blockSize
+
+	for _, f := range zigzag(blockSize, lowFreq) {
+		features = append(features, feature{x: bx, y: by, coef: coefs[f.Y*blockSize+f.X]})
+	}
+	features = append(features, feature{x: bx, y: by, coef: coefs[size]})
+	features = append(features, feature{x: bx, y: by, coef: coefs[2*size]})
+	features = append(features, feature{x: bx, y: by, coef: coefs[3*size]})
+
+	// Append average R,G,B values to the features vector(slice).
+	features = append(features, feature{x: bx, y: by, coef: avr})
+	features = append(features, feature{x: bx, y: by, coef: avb})
+	features = append(features, feature{x: bx, y: by, coef: avg})
+
+	return features, textureEnergy(coefs[:size], blockSize)
+}
+
+// textureEnergy returns the texture energy of a block: the sum of the magnitudes of its high frequency luminance
+// DCT coefficients, the ones whose horizontal and vertical frequencies sum to at least half the block size.
+// The flat blocks have almost no energy, while the noise and the details spread it over the high frequencies.
+// The coefficients of the blocks up to 4x4 are quantized, and the larger blocks sum more coefficients.
+func textureEnergy(yCoefs []float64, blockSize int) float64 {
+	var energy float64
+	for u := 0; u < blockSize; u++ {
+		for v := 0; v < blockSize; v++ {
+			if u+v > 0 && 2*(u+v) >= blockSize {
+				energy += math.Abs(yCoefs[v*blockSize+u])
+			}
+		}
+	}
+	return energy
+}
+
+// blockPlaneCoefs computes the DCT coefficients of the Y,R,G,B planes of a block. The coefficients of the planes
+// are stored one plane after the other in a single flat slice, each plane laid out like the coefficients of
+// blockDCT. The coefficients of the blocks up to 4x4 are quantized.
+func blockPlaneCoefs(blockSize int, yPlane, rPlane, gPlane, bPlane []float64) []float64 {
+	size := blockSize * blockSize
+	// The coefficients and the intermediate products of the transform share a single buffer.
+	buf := make([]float64, 5*size)
+	coefs, tmp := buf[:4*size], buf[4*size:]
+	for i, plane := range [4][]float64{yPlane, rPlane, gPlane, bPlane} {
+		separableDCT(plane, coefs[i*size:(i+1)*size], tmp, blockSize)
+	}
+
+	// Obtain the quantized DCT coefficients.
+	if blockSize <= 4 {
+		for i := range coefs {
+			j := i % size
+			coefs[i] /= q4x4[j%blockSize][j/blockSize]
+		}
+	}
+	return coefs
+}
+
+// meanVarFeatures returns the luminance mean and variance of the YUV block having its top left corner at bx, by.
+func meanVarFeatures(b *image.RGBA, bx, by int, blockSize int) []feature {
+	mean, variance := lumaStats(b, blockSize)
+	return []feature{
+		{x: bx, y: by, coef: mean},
+		{x: bx, y: by, coef: variance},
+	}
+}
+
+// lumaStats computes the luminance mean and variance of a YUV block, where the luminance is stored in the red component.
+func lumaStats(b *image.RGBA, blockSize int) (float64, float64) {
+	var sum, sqSum float64
+	min := b.Bounds().Min
+	for y := 0; y < blockSize; y++ {
+		i := b.PixOffset(min.X, min.Y+y)
+		for x := 0; x < blockSize; x++ {
+			l := float64(b.Pix[i])
+			sum += l
+			sqSum += l * l
+			i += 4
+		}
+	}
+	n := float64(blockSize * blockSize)
+	mean := sum / n
+	return mean, sqSum/n - mean*mean
+}
+
+// channelFeatures returns the correlations of the R,G and the G,B planes of the YUV block having its top left
+// corner at bx, by. A copy whose colors have been shifted or rescaled per channel keeps the correlations,
+// while its DCT and average color features change.
+func channelFeatures(b *image.RGBA, bx, by int, blockSize int) []feature {
+	_, rPlane, gPlane, bPlane, avr, avg, avb := blockPlanes(b, blockSize)
+	return []feature{
+		{x: bx, y: by, coef: planeCorrelation(rPlane, gPlane, avr, avg)},
+		{x: bx, y: by, coef: planeCorrelation(gPlane, bPlane, avg, avb)},
+	}
+}
+
+// planeCorrelation returns the Pearson correlation coefficient of two planes with the provided averages.
+// The correlation with a flat plane is undefined and reported as zero.
+func planeCorrelation(a, b []float64, avgA, avgB float64) float64 {
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-avgA, b[i]-avgB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA < 1e-9 || varB < 1e-9 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// entropyFeatures returns the luminance entropy of the YUV block having its top left corner at bx, by.
+func entropyFeatures(b *image.RGBA, bx, by int, blockSize int) []feature {
+	return []feature{{x: bx, y: by, coef: blockEntropy(b, blockSize)}}
+}
+
+// blockEntropy computes the Shannon entropy in bits of the YUV block luminance histogram.
+// The flat blocks have an entropy close to zero, while the textured blocks have a high entropy.
+func blockEntropy(b *image.RGBA, blockSize int) float64 {
+	var hist [256]int
+	min := b.Bounds().Min
+	for y := 0; y < blockSize; y++ {
+		i := b.PixOffset(min.X, min.Y+y)
+		for x := 0; x < blockSize; x++ {
+			hist[b.Pix[i]]++
+			i += 4
+		}
+	}
+
+	var entropy float64
+	n := float64(blockSize * blockSize)
+	for _, count := range hist {
+		if count > 0 {
+			p := float64(count) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// normalizeFeatures standardizes each feature dimension to zero mean and unit variance across all blocks,
+// so that the large magnitude dimensions (like the DC coefficients) no longer dominate the small ones.
+// The features of each block are expected to be stored contiguously, dims values per block.
+func normalizeFeatures(features []feature, dims int) {
+	blocks := len(features) / dims
+	if blocks == 0 {
+		return
+	}
+
+	for j := 0; j < dims; j++ {
+		var sum, sqSum float64
+		for i := 0; i < blocks; i++ {
+			c := features[i*dims+j].coef
+			sum += c
+			sqSum += c * c
+		}
+		mean := sum / float64(blocks)
+		std := math.Sqrt(math.Max(sqSum/float64(blocks)-mean*mean, 0))
+
+		for i := 0; i < blocks; i++ {
+			f := &features[i*dims+j]
+			f.coef -= mean
+			// The constant dimensions are only centered.
+			if std > 0 {
+				f.coef /= std
+			}
+		}
+	}
+}
+
+// blockFeature is the whole feature vector of a block, keeping together the features extracted from it.
+// The embedded feature holds the position, variance and mirroring of the block, its coefficient is unused.
+// The vectors serve the correlation matching only: the default matching sorts the flat features of all the
+// blocks, each coefficient on its own.
+type blockFeature struct {
+	feature
+	values []float64
+}
+
+// groupFeatures groups the flat features back into the feature vectors of their blocks.
+// The features of each block are expected to be stored contiguously, dims values per block.
+func groupFeatures(features []feature, dims int) []blockFeature {
+	if dims <= 0 {
+		return nil
+	}
+	blocks := make([]blockFeature, len(features)/dims)
+	for i := range blocks {
+		row := features[i*dims : (i+1)*dims]
+		values := make([]float64, dims)
+		for j, f := range row {
+			values[j] = f.coef
+		}
+		blocks[i] = blockFeature{feature: row[0], values: values}
+		blocks[i].coef = 0
+	}
+	return blocks
+}
+
+// blockVec sorts the feature vectors of the blocks lexicographically, so the similar blocks become neighbors.
+// The equal vectors are ordered by the block position and mirroring, like the features.
+type blockVec []blockFeature
+
+func (a blockVec) Len() int      { return len(a) }
+func (a blockVec) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a blockVec) Less(i, j int) bool {
+	vi, vj := a[i].values, a[j].values
+	for k := range vi {
+		if k >= len(vj) {
+			return false
+		}
+		if vi[k] != vj[k] {
+			return vi[k] < vj[k]
+		}
+	}
+	if len(vi) != len(vj) {
+		return len(vi) < len(vj)
+	}
+	return lessPosition(a[i].feature, a[j].feature)
+}
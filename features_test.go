@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+func TestMeanVarFeatures(t *testing.T) {
+	const size = 4
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 100
+	}
+	block := img.SubImage(image.Rect(2, 2, 2+size, 2+size)).(*image.RGBA)
+	feats := meanVarFeatures(block, 2, 2, size)
+	if len(feats) != 2 || feats[0].coef != 100 || feats[1].coef != 0 {
+		t.Errorf("the solid block has the mean and variance %+v, expected 100 and 0", feats)
+	}
+
+	// A textured block alternates two luminance values, 100 and 200.
+	for y := 2; y < 2+size; y++ {
+		for x := 2; x < 2+size; x++ {
+			if (x+y)%2 == 0 {
+				img.Pix[img.PixOffset(x, y)] = 200
+			}
+		}
+	}
+	feats = meanVarFeatures(block, 2, 2, size)
+	if math.Abs(feats[0].coef-150) > 1e-9 || math.Abs(feats[1].coef-2500) > 1e-9 {
+		t.Errorf("the textured block has the mean and variance %v and %v, expected 150 and 2500", feats[0].coef, feats[1].coef)
+	}
+}
+
+func TestMeanVarFeatureDims(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Features = FeatureDCT
+	dct := extractedFeatures(t, cfg)
+	cfg.Features = FeatureDCT | FeatureMeanVar
+	feats := extractedFeatures(t, cfg)
+	if n := countBlocks(image.Rect(0, 0, 96, 64), cfg.BlockSize, 1); len(feats) != len(dct)+2*n {
+		t.Errorf("extracted %d features of %d blocks, expected the %d DCT features and 2 more per block", len(feats), n, len(dct))
+	}
+}
+
+func TestBlockEntropy(t *testing.T) {
+	const size = 16
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 90
+	}
+	if e := blockEntropy(img, size); e > 1e-9 {
+		t.Errorf("the uniform block has the entropy %v, expected 0", e)
+	}
+
+	// The luminance of the random block is drawn uniformly, whose entropy is close to the 8 bits of the values,
+	// although the 256 pixels of a block can't hold all the values.
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	if e := blockEntropy(img, size); e < 6.5 || e > 8 {
+		t.Errorf("the random block has the entropy %v, expected a high entropy", e)
+	}
+	feats := entropyFeatures(img, 3, 5, size)
+	if len(feats) != 1 || feats[0].x != 3 || feats[0].y != 5 || feats[0].coef != blockEntropy(img, size) {
+		t.Errorf("unexpected entropy features %+v", feats)
+	}
+}
+
+func TestNormalizeFeatures(t *testing.T) {
+	const dims, blocks = 4, 300
+	rnd := rand.New(rand.NewSource(1))
+	// The dimensions have very different scales and offsets, like the DC coefficients and the small AC
+	// coefficients, and the last one is constant.
+	scales := [dims]float64{1000, 3, 0.01, 0}
+	offsets := [dims]float64{500, -20, 0.5, 7}
+	feats := make([]feature, 0, dims*blocks)
+	for i := 0; i < blocks; i++ {
+		for j := 0; j < dims; j++ {
+			feats = append(feats, feature{x: i, coef: offsets[j] + scales[j]*rnd.NormFloat64()})
+		}
+	}
+	normalizeFeatures(feats, dims)
+
+	for j := 0; j < dims; j++ {
+		var sum, sqSum float64
+		for i := 0; i < blocks; i++ {
+			c := feats[i*dims+j].coef
+			sum += c
+			sqSum += c * c
+		}
+		mean, variance := sum/blocks, sqSum/blocks
+		wantVariance := 1.0
+		if scales[j] == 0 {
+			wantVariance = 0
+		}
+		if math.Abs(mean) > 1e-9 || math.Abs(variance-wantVariance) > 1e-9 {
+			t.Errorf("the dimension %d has the mean %v and the variance %v, expected 0 and %v", j, mean, variance, wantVariance)
+		}
+	}
+}
+
+func TestLowFreqCountFeatureLength(t *testing.T) {
+	want := []image.Point{{0, 0}, {0, 1}, {1, 0}, {2, 0}, {1, 1}, {0, 2}}
+	if got := zigzag(4, 6); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got the zigzag frequencies %v, expected %v", got, want)
+	}
+	if got := zigzag(4, 16); len(got) != 16 || got[15] != image.Pt(3, 3) {
+		t.Errorf("got the zigzag frequencies %v, expected all of them ending with 3,3", got)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	for _, n := range []int{1, 3, 10, 16} {
+		cfg := DefaultConfig
+		cfg.LowFreqCount = n
+		// The luminance coefficients followed by the 6 color features.
+		if dims := cfg.featureDims(); dims != n+6 {
+			t.Errorf("got %d DCT features with %d low frequencies", dims, n)
+		}
+		if feats, _ := dctFeatures(img, 0, 0, 8, n); len(feats) != n+6 {
+			t.Errorf("got %d extracted features with %d low frequencies", len(feats), n)
+		}
+	}
+}
+
+func TestLowFreqCountRecompression(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 50}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift := dst.Min.Sub(src.Min)
+	count := func(img image.Image, lowFreq int) int {
+		cfg := DefaultConfig
+		cfg.LowFreqCount = lowFreq
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range res.Shifts {
+			if s.X == float64(shift.X) && s.Y == float64(shift.Y) {
+				return s.Count
+			}
+		}
+		return 0
+	}
+	// The fraction of the matches of the copy surviving the recompression.
+	retained := func(lowFreq int) float64 {
+		return float64(count(recompressed, lowFreq)) / float64(count(img, lowFreq))
+	}
+	if low, all := retained(3), retained(16); low <= all {
+		t.Errorf("%.2f of the matches are retained with 3 low frequencies, not more than %.2f with all of them", low, all)
+	}
+}
+
+func TestGroupFeatures(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Step = 8
+	cfg.Features = FeatureDCT | FeatureMeanVar
+	feats := extractedFeatures(t, cfg)
+	dims := cfg.featureDims()
+
+	blocks := groupFeatures(feats, dims)
+	if len(blocks)*dims != len(feats) {
+		t.Fatalf("expected %d blocks of %d features, got %d", len(feats)/dims, dims, len(blocks))
+	}
+	vectors := make(map[image.Point][]float64)
+	for i, b := range blocks {
+		if len(b.values) != dims {
+			t.Fatalf("the block %d,%d has %d features, expected %d", b.x, b.y, len(b.values), dims)
+		}
+		for j, v := range b.values {
+			if f := feats[i*dims+j]; f.x != b.x || f.y != b.y || f.coef != v {
+				t.Fatalf("the feature %d of the block %d,%d is %+v, expected %v", j, b.x, b.y, f, v)
+			}
+		}
+		vectors[image.Pt(b.x, b.y)] = b.values
+	}
+
+	// The feature vectors are sorted as a whole, so each block keeps its own features.
+	sort.Sort(blockVec(blocks))
+	for i, b := range blocks {
+		if !reflect.DeepEqual(vectors[image.Pt(b.x, b.y)], b.values) {
+			t.Fatalf("the sorted block %d,%d has the features %v, expected %v", b.x, b.y, b.values, vectors[image.Pt(b.x, b.y)])
+		}
+		if i > 0 && blockVec(blocks).Less(i, i-1) {
+			t.Fatalf("the blocks %d and %d are not sorted", i-1, i)
+		}
+	}
+}
+
+func TestTextureEnergyGate(t *testing.T) {
+	// The left half is flat, the right half is noise, and nothing is copied, so every match is spurious.
+	const size = 96
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	rnd := rand.New(rand.NewSource(1))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Pix[y*img.Stride+x] = 128
+			if x >= size/2 {
+				img.Pix[y*img.Stride+x] = uint8(rnd.Intn(256))
+			}
+		}
+	}
+
+	detect := func(energy float64) (*Detector, []feature) {
+		cfg := DefaultConfig
+		cfg.MinTextureEnergy = energy
+		d := &Detector{Config: cfg}
+		if _, err := d.Detect(img); err != nil {
+			t.Fatal(err)
+		}
+		yuv := preprocess(imgToNRGBA(img), cfg)
+		feats, _, err := extractFeatures(context.Background(), nil, blockSources{yuv: yuv}, yuv.Bounds(), cfg, newProgressBar(0, ""), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d, feats
+	}
+	all, allFeats := detect(0)
+	gated, gatedFeats := detect(1)
+
+	// Only the textured blocks away from the flat half, which is blurred into the noise at its edge, are kept.
+	for _, f := range gatedFeats {
+		if f.x < size/2-DefaultConfig.BlockSize-DefaultConfig.BlurRadius {
+			t.Fatalf("the flat block at %d,%d has not been gated out", f.x, f.y)
+		}
+	}
+	countTextured := func(feats []feature) int {
+		var n int
+		for _, f := range feats {
+			if f.x >= size/2+DefaultConfig.BlurRadius {
+				n++
+			}
+		}
+		return n / DefaultConfig.featureDims()
+	}
+	if textured, kept := countTextured(allFeats), countTextured(gatedFeats); kept != textured {
+		t.Errorf("expected the %d textured blocks to be retained, got %d", textured, kept)
+	}
+	flat := func(vectors []vector) int {
+		var n int
+		for _, v := range vectors {
+			if v.xa < size/2-DefaultConfig.BlockSize && v.xb < size/2-DefaultConfig.BlockSize {
+				n++
+			}
+		}
+		return n
+	}
+	if n := flat(gated.vectors); n > 0 || flat(all.vectors) == 0 || len(gated.vectors) >= len(all.vectors) {
+		t.Errorf("expected the gate to remove the spurious matches of the flat blocks, got %d matches (%d flat) instead of %d (%d flat)",
+			len(gated.vectors), n, len(all.vectors), flat(all.vectors))
+	}
+}
+
+func TestChannelFeatures(t *testing.T) {
+	// The copy is color adjusted by a gain and an offset per channel, which keep the channel correlations.
+	adjust := func(r, g, b uint8) (uint8, uint8, uint8) { return r*3/4 + 40, g, b/2 + 20 }
+	// The palette colors, and their adjusted colors, are unchanged by the conversion to YUV and back,
+	// so the correlations of the copied blocks are not perturbed by the rounding of the conversion.
+	exact := func(r, g, b uint8) bool {
+		y, u, v := color.RGBToYCbCr(r, g, b)
+		r2, g2, b2 := color.YCbCrToRGB(y, u, v)
+		return r2 == r && g2 == g && b2 == b
+	}
+	rnd := rand.New(rand.NewSource(1))
+	var palette []color.NRGBA
+	for len(palette) < 64 {
+		r, g, b := uint8(rnd.Intn(64)*4), uint8(rnd.Intn(256)), uint8(rnd.Intn(128)*2)
+		if exact(r, g, b) && exact(adjust(r, g, b)) {
+			palette = append(palette, color.NRGBA{r, g, b, 255})
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+	for i := 0; i < len(img.Pix); i += 4 {
+		c := palette[rnd.Intn(len(palette))]
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, 255
+	}
+	src, shift := image.Rect(16, 16, 48, 48), image.Pt(64, 64)
+	for y := src.Min.Y; y < src.Max.Y; y++ {
+		for x := src.Min.X; x < src.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			r, g, b := adjust(c.R, c.G, c.B)
+			img.SetNRGBA(x+shift.X, y+shift.Y, color.NRGBA{r, g, b, 255})
+		}
+	}
+
+	copyMatches := func(features FeatureSet) int {
+		cfg := DefaultConfig
+		cfg.BlurRadius = 0
+		cfg.Features = features
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range res.Shifts {
+			if s.X == float64(shift.X) && s.Y == float64(shift.Y) {
+				return s.Count
+			}
+		}
+		return 0
+	}
+	// Each block of the copy has exactly the correlations of its source block, while only some of its DCT
+	// features match, since the average colors and the luminance have changed.
+	dct, channels := copyMatches(FeatureDCT), copyMatches(FeatureChannels)
+	if blocks := countBlocks(src, DefaultConfig.BlockSize, 1); channels < blocks {
+		t.Errorf("the channel correlations matched %d blocks of the adjusted copy, expected its %d blocks", channels, blocks)
+	}
+	if channels <= 2*dct {
+		t.Errorf("the channel correlations matched %d blocks of the adjusted copy, not many more than the %d of the DCT features", channels, dct)
+	}
+}
+
+func TestDCTChannelWeights(t *testing.T) {
+	img, _, _, err := SyntheticImage(96, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yuv := image.NewRGBA(img.Bounds())
+	draw.Draw(yuv, yuv.Bounds(), convertRGBImageToYUV(img), image.Point{}, draw.Src)
+	// The recolored image has the same luminance, but random chroma, so only its R,G,B planes differ.
+	recolored := image.NewRGBA(yuv.Bounds())
+	copy(recolored.Pix, yuv.Pix)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < len(recolored.Pix); i += 4 {
+		recolored.Pix[i+1], recolored.Pix[i+2] = uint8(rnd.Intn(256)), uint8(rnd.Intn(256))
+	}
+	vectors := func(yuv *image.RGBA, cfg Config) ([]feature, []vector) {
+		feats, _, err := extractFeatures(context.Background(), nil, blockSources{yuv: yuv}, yuv.Bounds(), cfg, pb.New(0), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := countBlocks(yuv.Bounds(), cfg.BlockSize, 1); len(feats) != n*cfg.featureDims() {
+			t.Fatalf("extracted %d features of %d blocks, expected %d per block", len(feats), n, cfg.featureDims())
+		}
+		return feats, matchFeatures(append([]feature(nil), feats...), cfg, nil)
+	}
+
+	cfg := DefaultConfig
+	_, a := vectors(yuv, cfg)
+	if _, b := vectors(recolored, cfg); reflect.DeepEqual(a, b) {
+		t.Fatal("expected the chroma to change the matches of the equally weighted channels")
+	}
+	// The color channels weighted by zero have no influence on the matches.
+	cfg.DCTWeights = &ChannelWeights{Y: 1}
+	luma, a := vectors(yuv, cfg)
+	if _, b := vectors(recolored, cfg); !reflect.DeepEqual(a, b) {
+		t.Errorf("the zero weighted color channels changed the matches: %d vectors instead of %d", len(b), len(a))
+	}
+	// The weights scale the features of their channels.
+	cfg.DCTWeights = &ChannelWeights{Y: 2}
+	if doubled, _ := vectors(yuv, cfg); doubled[0].coef != 2*luma[0].coef {
+		t.Errorf("the doubled luminance feature is %v, expected %v", doubled[0].coef, 2*luma[0].coef)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// fetchTimeout is the maximum duration of downloading an image from an URL.
+	fetchTimeout = 30 * time.Second
+	// maxFetchSize is the maximum size in bytes of an image downloaded from an URL.
+	maxFetchSize = 64 << 20
+)
+
+// isURL reports whether the image path is an HTTP or HTTPS URL.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetchImage downloads and decodes the image found at the provided URL. The responses which are
+// not images, according to their content type, or which are larger than maxFetchSize are rejected.
+func fetchImage(url string) (image.Image, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return nil, fmt.Errorf("%w: %s has the content type %q", ErrUnsupportedFormat, url, ct)
+	}
+
+	// Read one byte more than the limit to tell whether the image exceeds it.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchSize {
+		return nil, fmt.Errorf("the image at %s is larger than %d bytes", url, maxFetchSize)
+	}
+
+	return decodeImage(bytes.NewReader(data))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadImageFromURL(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forged.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(data.Bytes())
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if !isURL(srv.URL) || isURL("forged.png") {
+		t.Fatal("the URLs are not told apart from the file paths")
+	}
+	fetched, err := loadImage(srv.URL + "/forged.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePixels(fetched, img) {
+		t.Fatal("the fetched image differs from the served image")
+	}
+	res, err := Detect(fetched, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged {
+		t.Errorf("the copy of the fetched image is not detected")
+	}
+
+	if _, err := loadImage(srv.URL + "/page"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got the error %v for a page which is not an image, expected %v", err, ErrUnsupportedFormat)
+	}
+	if _, err := loadImage(srv.URL + "/missing.png"); err == nil {
+		t.Error("no error for a missing image")
+	}
+}
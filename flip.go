@@ -0,0 +1,63 @@
+package main
+
+import "image"
+
+// Flip is the mirroring of a copied region relative to its source.
+type Flip int
+
+// The supported mirroring types.
+const (
+	NoFlip Flip = iota
+	FlipHorizontal
+	FlipVertical
+)
+
+// String returns the name of the mirroring type.
+func (f Flip) String() string {
+	switch f {
+	case FlipHorizontal:
+		return "horizontal"
+	case FlipVertical:
+		return "vertical"
+	}
+	return ""
+}
+
+// flipBlock returns the horizontally or vertically mirrored copy of the square block.
+func flipBlock(b *image.RGBA, blockSize int, flip Flip) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, blockSize, blockSize))
+	min := b.Bounds().Min
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			sx, sy := x, y
+			if flip == FlipHorizontal {
+				sx = blockSize - 1 - x
+			} else {
+				sy = blockSize - 1 - y
+			}
+			i, j := b.PixOffset(min.X+sx, min.Y+sy), dst.PixOffset(x, y)
+			copy(dst.Pix[j:j+4], b.Pix[i:i+4])
+		}
+	}
+	return dst
+}
+
+// flipValues mirrors the per-pixel values of a square patch stored in row order, having the provided number of values per pixel.
+func flipValues(values []float64, size, channels int, flip Flip) []float64 {
+	if flip == NoFlip {
+		return values
+	}
+	flipped := make([]float64, len(values))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			sx, sy := x, y
+			if flip == FlipHorizontal {
+				sx = size - 1 - x
+			} else {
+				sy = size - 1 - y
+			}
+			copy(flipped[(y*size+x)*channels:(y*size+x+1)*channels], values[(sy*size+sx)*channels:])
+		}
+	}
+	return flipped
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"reflect"
+	"testing"
+)
+
+func TestFlipBlock(t *testing.T) {
+	b := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := range b.Pix {
+		b.Pix[i] = uint8(i)
+	}
+	h := flipBlock(b, 3, FlipHorizontal)
+	v := flipBlock(b, 3, FlipVertical)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			if got, want := h.RGBAAt(x, y), b.RGBAAt(2-x, y); got != want {
+				t.Errorf("the horizontally flipped pixel %d,%d is %v, expected %v", x, y, got, want)
+			}
+			if got, want := v.RGBAAt(x, y), b.RGBAAt(x, 2-y); got != want {
+				t.Errorf("the vertically flipped pixel %d,%d is %v, expected %v", x, y, got, want)
+			}
+		}
+	}
+
+	values := []float64{1, 2, 3, 4}
+	if got, want := flipValues(values, 2, 1, FlipHorizontal), []float64{2, 1, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the horizontally flipped values are %v, expected %v", got, want)
+	}
+	if got, want := flipValues(values, 2, 1, FlipVertical), []float64{3, 4, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the vertically flipped values are %v, expected %v", got, want)
+	}
+}
+
+func TestDetectMirroredCopy(t *testing.T) {
+	forged, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := src.Dx()
+	for _, flip := range []Flip{FlipHorizontal, FlipVertical} {
+		// The copied region is mirrored before being pasted.
+		img := image.NewNRGBA(forged.Bounds())
+		draw.Draw(img, img.Bounds(), forged, image.Point{}, draw.Src)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				dx, dy := size-1-x, y
+				if flip == FlipVertical {
+					dx, dy = x, size-1-y
+				}
+				img.Set(dst.Min.X+dx, dst.Min.Y+dy, forged.At(src.Min.X+x, src.Min.Y+y))
+			}
+		}
+
+		cfg := DefaultConfig
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Forged {
+			t.Errorf("%v: the mirrored copy is detected without the flip detection: %+v", flip, res.Shifts)
+		}
+
+		cfg.DetectFlips = true
+		if res, err = Detect(img, cfg); err != nil {
+			t.Fatal(err)
+		}
+		// Along the mirroring direction the offset is the sum of the block coordinates.
+		want := Shift{X: float64(dst.Min.X - src.Min.X), Y: float64(src.Min.Y + dst.Min.Y + size - cfg.BlockSize), Flip: flip.String()}
+		if flip == FlipHorizontal {
+			want.X, want.Y = float64(src.Min.X+dst.Min.X+size-cfg.BlockSize), float64(dst.Min.Y-src.Min.Y)
+		}
+		if !res.Forged || len(res.Shifts) == 0 {
+			t.Fatalf("%v: the mirrored copy is not detected", flip)
+		}
+		if got := res.Shifts[0]; got.X != want.X || got.Y != want.Y || got.Flip != want.Flip {
+			t.Errorf("%v: got the dominant shift %+v, expected %+v", flip, got, want)
+		}
+	}
+}
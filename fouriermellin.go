@@ -0,0 +1,224 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// fmRadii is the number of the logarithmic radius bins of the log-polar map.
+	fmRadii = 8
+	// fmAngles is the number of the angle bins of the log-polar map, covering half a turn,
+	// since the magnitude spectrum is symmetric.
+	fmAngles = 16
+	// fmFeatures is the number of the Fourier-Mellin features per block.
+	fmFeatures = 8
+	// fmScale scales the features of the normalized log-polar map, which lie below 0.1,
+	// so the distance threshold tells apart the blocks of a different spectrum.
+	fmScale = 1000
+)
+
+// magnitudeSpectrum computes the DFT magnitude of a square grid, shifting the DC component to the center.
+func magnitudeSpectrum(grid []float64, n int) []float64 {
+	re, im := dft2(grid, make([]float64, len(grid)), n, n)
+	mag := make([]float64, n*n)
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			su, sv := (u+n/2)%n, (v+n/2)%n
+			mag[sv*n+su] = math.Hypot(re[v*n+u], im[v*n+u])
+		}
+	}
+	return mag
+}
+
+// dft2 computes the separable two dimensional discrete Fourier transform of a w×h grid.
+func dft2(re, im []float64, w, h int) ([]float64, []float64) {
+	rowRe, rowIm := make([]float64, w*h), make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for u := 0; u < w; u++ {
+			var sr, si float64
+			for x := 0; x < w; x++ {
+				a := -2 * math.Pi * float64(u*x) / float64(w)
+				c, s := math.Cos(a), math.Sin(a)
+				sr += re[y*w+x]*c - im[y*w+x]*s
+				si += re[y*w+x]*s + im[y*w+x]*c
+			}
+			rowRe[y*w+u], rowIm[y*w+u] = sr, si
+		}
+	}
+	outRe, outIm := make([]float64, w*h), make([]float64, w*h)
+	for u := 0; u < w; u++ {
+		for v := 0; v < h; v++ {
+			var sr, si float64
+			for y := 0; y < h; y++ {
+				a := -2 * math.Pi * float64(v*y) / float64(h)
+				c, s := math.Cos(a), math.Sin(a)
+				sr += rowRe[y*w+u]*c - rowIm[y*w+u]*s
+				si += rowRe[y*w+u]*s + rowIm[y*w+u]*c
+			}
+			outRe[v*w+u], outIm[v*w+u] = sr, si
+		}
+	}
+	return outRe, outIm
+}
+
+// logPolarGrid resamples a square grid around its center into the log-polar space, using bilinear interpolation.
+// The rows of the result are the logarithmic radii and the columns the angles, so a rotation of the
+// grid content becomes a circular shift of the columns and a scaling becomes a shift of the rows.
+func logPolarGrid(grid []float64, n, radii, angles int) []float64 {
+	c := float64(n) / 2
+	at := func(x, y int) float64 {
+		return grid[clampInt(y, 0, n-1)*n+clampInt(x, 0, n-1)]
+	}
+	return logPolarSample(at, c, c, c-0.5, radii, angles, math.Pi)
+}
+
+// logPolarSample resamples the values returned by at around the cx, cy center into the log-polar space,
+// up to the maxR radius and over the provided angle span, using bilinear interpolation.
+// The rows of the result are the logarithmic radii and the columns the angles.
+func logPolarSample(at func(x, y int) float64, cx, cy, maxR float64, radii, angles int, span float64) []float64 {
+	logStep := math.Log(maxR) / float64(radii-1)
+
+	lp := make([]float64, radii*angles)
+	for ri := 0; ri < radii; ri++ {
+		r := math.Exp(float64(ri) * logStep)
+		for ai := 0; ai < angles; ai++ {
+			theta := span * float64(ai) / float64(angles)
+			x, y := cx+r*math.Cos(theta), cy+r*math.Sin(theta)
+			x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+			fx, fy := x-float64(x0), y-float64(y0)
+			lp[ri*angles+ai] = at(x0, y0)*(1-fx)*(1-fy) + at(x0+1, y0)*fx*(1-fy) +
+				at(x0, y0+1)*(1-fx)*fy + at(x0+1, y0+1)*fx*fy
+		}
+	}
+	return lp
+}
+
+// LogPolar remaps the image luminance around the center into the log-polar space.
+// The center is in the image coordinates and the radius extends up to the nearest image edge.
+// The columns of the result are the logarithmic radii and the rows the angles covering a full turn,
+// so a rotation around the center becomes a circular vertical shift and a scaling a horizontal shift.
+// The result has the same size as the image.
+func LogPolar(img image.Image, center image.Point) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+
+	maxR := math.Min(
+		math.Min(float64(center.X-bounds.Min.X), float64(bounds.Max.X-1-center.X)),
+		math.Min(float64(center.Y-bounds.Min.Y), float64(bounds.Max.Y-1-center.Y)),
+	)
+	if w < 2 || h < 1 || maxR <= 1 {
+		return dst
+	}
+
+	at := func(x, y int) float64 {
+		x = clampInt(x, bounds.Min.X, bounds.Max.X-1)
+		y = clampInt(y, bounds.Min.Y, bounds.Max.Y-1)
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+	lp := logPolarSample(at, float64(center.X), float64(center.Y), maxR, w, h, 2*math.Pi)
+	for ri := 0; ri < w; ri++ {
+		for ai := 0; ai < h; ai++ {
+			dst.Pix[dst.PixOffset(ri, ai)] = uint8(math.Round(lp[ri*h+ai]))
+		}
+	}
+	return dst
+}
+
+// fourierMellin returns the normalized log-polar map of the magnitude spectrum of the YUV block luminance.
+// The magnitude spectrum is translation invariant, while in the log-polar space the rotation and
+// scaling of the block become shifts.
+func fourierMellin(b *image.RGBA, blockSize int) []float64 {
+	min := b.Bounds().Min
+	grid := make([]float64, blockSize*blockSize)
+	var mean float64
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			v := float64(b.Pix[b.PixOffset(min.X+x, min.Y+y)])
+			grid[y*blockSize+x] = v
+			mean += v
+		}
+	}
+	mean /= float64(len(grid))
+	// The block is centered and tapered by a circular Hann window, otherwise the DC component and the edges
+	// of the block, which don't rotate with its content, dominate the spectrum.
+	c := float64(blockSize-1) / 2
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			r := math.Hypot(float64(x)-c, float64(y)-c) / (c + 1)
+			var w float64
+			if r < 1 {
+				w = 0.5 + 0.5*math.Cos(math.Pi*r)
+			}
+			grid[y*blockSize+x] = (grid[y*blockSize+x] - mean) * w
+		}
+	}
+	lp := logPolarGrid(magnitudeSpectrum(grid, blockSize), blockSize, fmRadii, fmAngles)
+	// The map is normalized, so the descriptor doesn't depend on the contrast of the block.
+	var norm float64
+	for _, v := range lp {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range lp {
+			lp[i] /= norm
+		}
+	}
+	return lp
+}
+
+// fourierMellinFeatures returns the Fourier-Mellin descriptor of the YUV block having its top left corner at bx, by.
+// The descriptor is the low frequency DFT magnitude of the log-polar map, which is invariant to the shifts
+// of the map, hence to the rotation and scaling of the block.
+func fourierMellinFeatures(b *image.RGBA, bx, by int, blockSize int) []feature {
+	re, im := dft2(fourierMellin(b, blockSize), make([]float64, fmRadii*fmAngles), fmAngles, fmRadii)
+
+	features := make([]feature, 0, fmFeatures)
+	for kr := 0; kr < 2; kr++ {
+		for ka := 0; ka < fmFeatures/2; ka++ {
+			i := kr*fmAngles + ka
+			features = append(features, feature{x: bx, y: by, coef: fmScale * math.Hypot(re[i], im[i]) / float64(fmRadii*fmAngles)})
+		}
+	}
+	return features
+}
+
+// estimateScaleRotation estimates the scale and the rotation (in radians, modulo π) which maps the block
+// having its top left corner at a onto the block at b, by finding the shift maximizing the correlation of their log-polar maps.
+func estimateScaleRotation(img *image.RGBA, a, b image.Point, blockSize int) (float64, float64) {
+	rect := func(p image.Point) *image.RGBA {
+		return img.SubImage(image.Rect(p.X, p.Y, p.X+blockSize, p.Y+blockSize)).(*image.RGBA)
+	}
+	lpA, lpB := fourierMellin(rect(a), blockSize), fourierMellin(rect(b), blockSize)
+
+	best, bestDr, bestDa := math.Inf(-1), 0, 0
+	for dr := -(fmRadii / 2); dr <= fmRadii/2; dr++ {
+		for da := 0; da < fmAngles; da++ {
+			var sum float64
+			var count int
+			for ri := 0; ri < fmRadii; ri++ {
+				rj := ri + dr
+				if rj < 0 || rj >= fmRadii {
+					continue
+				}
+				for ai := 0; ai < fmAngles; ai++ {
+					sum += lpA[ri*fmAngles+ai] * lpB[rj*fmAngles+(ai+da)%fmAngles]
+					count++
+				}
+			}
+			if corr := sum / float64(count); corr > best {
+				best, bestDr, bestDa = corr, dr, da
+			}
+		}
+	}
+
+	// A scaled up content has a shrunk spectrum, which shifts the log-polar map towards the smaller radii.
+	maxR := float64(blockSize)/2 - 0.5
+	logStep := math.Log(maxR) / float64(fmRadii-1)
+	scale := math.Exp(-float64(bestDr) * logStep)
+	rotation := math.Pi * float64(bestDa) / float64(fmAngles)
+	return scale, rotation
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// featureDistance returns the Euclidean distance between two feature vectors.
+func featureDistance(a, b []feature) float64 {
+	var sum float64
+	for i := range a {
+		sum += (a[i].coef - b[i].coef) * (a[i].coef - b[i].coef)
+	}
+	return math.Sqrt(sum)
+}
+
+func TestFourierMellinScaledRotatedCopy(t *testing.T) {
+	const size = 16
+	pattern := func(x, y float64) float64 {
+		return 128 + 40*math.Cos(0.9*x+0.3*y) + 40*math.Cos(0.2*x-0.7*y)
+	}
+	// The copy is scaled 1.25 times and rotated by 30°, and the other block has a different content.
+	copied := renderBlock(size, math.Pi/6, func(x, y float64) float64 { return pattern(x/1.25, y/1.25) })
+	other := renderBlock(size, 0, func(x, y float64) float64 {
+		return 128 + 40*math.Cos(0.5*x-0.5*y) + 40*math.Cos(1.1*y)
+	})
+	ref := renderBlock(size, 0, pattern)
+
+	fm := func(b *image.RGBA) []feature { return fourierMellinFeatures(b, 0, 0, size) }
+	// The Fourier-Mellin descriptor of the transformed copy is much closer to the original than the descriptor
+	// of a different block.
+	if dc, do := featureDistance(fm(ref), fm(copied)), featureDistance(fm(ref), fm(other)); dc > do/2 {
+		t.Errorf("the Fourier-Mellin distance of the copy is %.4f, and of the other block %.4f", dc, do)
+	}
+
+	// The scale and the rotation of the copy are recovered within a bin of the log-polar map.
+	img := image.NewRGBA(image.Rect(0, 0, 2*size, size))
+	draw.Draw(img, ref.Bounds(), ref, image.Point{}, draw.Src)
+	draw.Draw(img, ref.Bounds().Add(image.Pt(size, 0)), copied, image.Point{}, draw.Src)
+	scale, rotation := estimateScaleRotation(img, image.Pt(0, 0), image.Pt(size, 0), size)
+	logStep := math.Log(float64(size)/2-0.5) / (fmRadii - 1)
+	if math.Abs(math.Log(scale/1.25)) > logStep {
+		t.Errorf("the estimated scale is %.2f, expected 1.25", scale)
+	}
+	if math.Abs(rotation-math.Pi/6) > math.Pi/fmAngles {
+		t.Errorf("the estimated rotation is %.1f°, expected 30°", rotation*180/math.Pi)
+	}
+}
+
+func TestLogPolarRotationIsCyclicShift(t *testing.T) {
+	const n = 64
+	// The pattern varies with both the angle and the radius around the center.
+	pattern := func(x, y float64) uint8 {
+		r, th := math.Hypot(x, y), math.Atan2(y, x)
+		return uint8(127 + 100*math.Sin(3*th)*math.Exp(-r/20))
+	}
+	a := image.NewGray(image.Rect(0, 0, n, n))
+	b := image.NewGray(image.Rect(0, 0, n, n))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			dx, dy := float64(x-n/2), float64(y-n/2)
+			a.SetGray(x, y, color.Gray{pattern(dx, dy)})
+			// b is rotated by a quarter turn.
+			b.SetGray(x, y, color.Gray{pattern(dy, -dx)})
+		}
+	}
+	center := image.Pt(n/2, n/2)
+	la := LogPolar(a, center).(*image.Gray)
+	lb := LogPolar(b, center).(*image.Gray)
+	if la.Bounds() != a.Bounds() {
+		t.Fatalf("the log-polar image bounds are %v, expected %v", la.Bounds(), a.Bounds())
+	}
+
+	// The rows are the angles of a full turn, so the quarter turn is a cyclic shift of a quarter of the rows.
+	shiftErr := func(s int) float64 {
+		var sum float64
+		for ai := 0; ai < n; ai++ {
+			for ri := 0; ri < n; ri++ {
+				d := float64(la.GrayAt(ri, ai).Y) - float64(lb.GrayAt(ri, (ai+s)%n).Y)
+				sum += d * d
+			}
+		}
+		return sum
+	}
+	best, bestErr := 0, math.MaxFloat64
+	for s := 0; s < n; s++ {
+		if e := shiftErr(s); e < bestErr {
+			best, bestErr = s, e
+		}
+	}
+	if best != n/4 && best != 3*n/4 {
+		t.Errorf("the rotated pattern is shifted by %d rows in the log-polar space, expected a quarter of %d rows", best, n)
+	}
+	if unshifted := shiftErr(0); bestErr > unshifted/10 {
+		t.Errorf("the shifted log-polar images differ by %v, compared to %v without the shift", bestErr, unshifted)
+	}
+}
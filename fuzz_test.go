@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// maxFuzzPixels bounds the size of the decoded fuzz images, so the declared huge dimensions
+// of the malformed headers don't exhaust the memory before the decoder notices the missing data.
+const maxFuzzPixels = 1 << 20
+
+func FuzzDetect(f *testing.F) {
+	img, _, _, err := SyntheticImage(48, 40, 1)
+	if err != nil {
+		f.Fatal(err)
+	}
+	var pngData, jpegData, gifData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		f.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, img, &jpeg.Options{Quality: 75}); err != nil {
+		f.Fatal(err)
+	}
+	if err := gif.Encode(&gifData, img, nil); err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range [][]byte{pngData.Bytes(), jpegData.Bytes(), gifData.Bytes(), jpegData.Bytes()[:jpegData.Len()/2], []byte("not an image")} {
+		f.Add(seed)
+	}
+
+	cfg := DefaultConfig
+	cfg.MaxImageSize = 64
+	cfg.Workers = 1
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if c, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && c.Width*c.Height > maxFuzzPixels {
+			t.Skip("the image is too large for fuzzing")
+		}
+		img, err := decodeImage(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		res, err := Detect(img, cfg)
+		if err != nil {
+			return
+		}
+		if res == nil || res.Mask == nil {
+			t.Fatal("the detection returned neither an error nor a result")
+		}
+		b := img.Bounds()
+		if res.Mask.Bounds() != image.Rect(0, 0, b.Dx(), b.Dy()) {
+			t.Errorf("the mask %v doesn't cover the image %v", res.Mask.Bounds(), b)
+		}
+		for _, r := range res.Regions {
+			if r.Empty() {
+				t.Errorf("the detected region %v is empty", r)
+			}
+		}
+	})
+}
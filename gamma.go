@@ -0,0 +1,31 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// gammaTable returns the lookup table of the gamma correction curve, mapping each 8-bit
+// channel value v to 255·(v/255)^(1/gamma). Gamma values above 1 brighten the midtones.
+func gammaTable(gamma float64) [256]uint8 {
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = uint8(math.Round(255 * math.Pow(float64(v)/255, 1/gamma)))
+	}
+	return lut
+}
+
+// adjustGamma applies the gamma correction to the color channels of the image in place, leaving the alpha unchanged.
+func adjustGamma(img *image.NRGBA, gamma float64) {
+	lut := gammaTable(gamma)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Pix[i+0] = lut[img.Pix[i+0]]
+			img.Pix[i+1] = lut[img.Pix[i+1]]
+			img.Pix[i+2] = lut[img.Pix[i+2]]
+			i += 4
+		}
+	}
+}
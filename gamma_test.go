@@ -0,0 +1,70 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestGammaTable(t *testing.T) {
+	lut := gammaTable(1)
+	for v := range lut {
+		if int(lut[v]) != v {
+			t.Fatalf("the gamma of 1 maps %d to %d", v, lut[v])
+		}
+	}
+	tests := []struct {
+		gamma   float64
+		in, out uint8
+	}{
+		{2, 0, 0},
+		{2, 64, 128},
+		{2, 128, 181},
+		{2, 255, 255},
+		{0.5, 64, 16},
+		{0.5, 128, 64},
+	}
+	for _, tt := range tests {
+		if got := gammaTable(tt.gamma)[tt.in]; got != tt.out {
+			t.Errorf("the gamma of %v maps %d to %d, expected %d", tt.gamma, tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestAdjustGamma(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	copy(img.Pix, []uint8{64, 128, 255, 100, 0, 10, 20, 255})
+	orig := cloneNRGBA(img)
+	adjustGamma(img, 1)
+	if !reflect.DeepEqual(img.Pix, orig.Pix) {
+		t.Errorf("the gamma of 1 changes the pixels from %v to %v", orig.Pix, img.Pix)
+	}
+
+	adjustGamma(img, 2)
+	lut := gammaTable(2)
+	// The alpha is left unchanged.
+	want := []uint8{128, 181, 255, 100, 0, lut[10], lut[20], 255}
+	if !reflect.DeepEqual(img.Pix, want) {
+		t.Errorf("the gamma of 2 changes the pixels to %v, expected %v", img.Pix, want)
+	}
+}
+
+func TestDetectGammaNoOp(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	want, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gamma = 1
+	got, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the gamma of 1 changes the result from %+v to %+v", want, got)
+	}
+}
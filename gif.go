@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"math"
+	"os"
+)
+
+const (
+	// frameChangeFactor is the factor by which the change of a frame relative to both of its neighbors
+	// must exceed the difference between the two neighbors for the frame to be reported as suspicious.
+	frameChangeFactor = 3
+	// minFrameChange is the minimum change of a suspicious frame, which ignores the dithering noise.
+	minFrameChange = 1
+)
+
+// FrameResult contains the outcome of the forgery detection on a single frame of an animated GIF.
+type FrameResult struct {
+	// Frame is the index of the frame.
+	Frame int `json:"frame"`
+	*Result
+	// Change is the mean absolute per-pixel difference from the previous frame, between 0 and 255.
+	Change float64 `json:"change"`
+	// Suspicious reports whether the frame differs much more from both of its neighbors than
+	// the consecutive frames usually do, like a single edited frame of an animation.
+	Suspicious bool `json:"suspicious"`
+}
+
+// loadGIF decodes all the frames of an animated GIF file.
+func loadGIF(path string) (*gif.GIF, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	return g, nil
+}
+
+// gifFrames composites the frames of an animated GIF, honoring their disposal methods,
+// so that each returned frame is the full image displayed at that point of the animation.
+func gifFrames(g *gif.GIF) []*image.NRGBA {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	frames := make([]*image.NRGBA, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneNRGBA(canvas))
+
+		// Prepare the canvas for the next frame.
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.ZP, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+// meanDifference returns the mean absolute per-pixel difference of the color channels of two images of the same size.
+func meanDifference(a, b *image.NRGBA) float64 {
+	var sum int
+	for i := 0; i < len(a.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			d := int(a.Pix[i+c]) - int(b.Pix[i+c])
+			if d < 0 {
+				d = -d
+			}
+			sum += d
+		}
+	}
+	return float64(sum) / float64(len(a.Pix)/4*3)
+}
+
+// DetectGIF runs the forgery detection on each frame of an animated GIF. Besides the per-frame results
+// it flags the frames which differ suspiciously from both of their neighbors.
+func DetectGIF(ctx context.Context, g *gif.GIF, cfg Config) ([]FrameResult, error) {
+	frames := gifFrames(g)
+	results := make([]FrameResult, len(frames))
+	for i, frame := range frames {
+		res, err := DetectContext(ctx, frame, cfg)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = FrameResult{Frame: i, Result: res}
+		if i > 0 {
+			results[i].Change = meanDifference(frames[i-1], frame)
+		}
+	}
+
+	// An edited frame differs from both of its neighbors, while the neighbors are similar to each other.
+	for i := 1; i < len(frames)-1; i++ {
+		// The change to the next frame is stored with the next frame.
+		change := math.Min(results[i].Change, results[i+1].Change)
+		skip := meanDifference(frames[i-1], frames[i+1])
+		results[i].Suspicious = change > minFrameChange && change > frameChangeFactor*skip
+	}
+	return results, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+// paletted converts the image to a frame of an animated GIF.
+func paletted(img image.Image) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.Draw(p, p.Bounds(), img, img.Bounds().Min, draw.Src)
+	return p
+}
+
+func TestDetectGIFForgedFrame(t *testing.T) {
+	forged, src, dst, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The authentic frames have unrelated content in place of the copy.
+	authentic, _ := authenticImage(t, 128, 128)
+
+	g := &gif.GIF{Config: image.Config{Width: 128, Height: 128}}
+	for i := 0; i < 5; i++ {
+		frame := image.Image(authentic)
+		if i == 2 {
+			frame = forged
+		}
+		g.Image = append(g.Image, paletted(frame))
+		g.Delay = append(g.Delay, 10)
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+
+	res, err := DetectGIF(context.Background(), g, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != len(g.Image) {
+		t.Fatalf("got %d frame results, expected %d", len(res), len(g.Image))
+	}
+	shift := dst.Min.Sub(src.Min)
+	for i, r := range res {
+		if r.Frame != i {
+			t.Errorf("the result %d is for the frame %d", i, r.Frame)
+		}
+		if want := i == 2; r.Suspicious != want {
+			t.Errorf("the frame %d is suspicious: %v, expected %v (change %v)", i, r.Suspicious, want, r.Change)
+		}
+	}
+	if r := res[2]; !r.Forged || len(r.Shifts) == 0 || r.Shifts[0].X != float64(shift.X) || r.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("the copy in the forged frame is not detected at the shift %v: %+v", shift, r.Shifts)
+	}
+	for _, i := range []int{0, 4} {
+		if res[i].Forged {
+			t.Errorf("the authentic frame %d is reported as forged: %+v", i, res[i].Shifts)
+		}
+	}
+}
+
+func TestGIFFramesDisposal(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	pal := color.Palette{color.Transparent, red, blue}
+	full := image.NewPaletted(image.Rect(0, 0, 4, 4), pal)
+	draw.Draw(full, full.Bounds(), &image.Uniform{red}, image.Point{}, draw.Src)
+	patch := image.NewPaletted(image.Rect(2, 2, 4, 4), pal)
+	draw.Draw(patch, patch.Bounds(), &image.Uniform{blue}, image.Point{}, draw.Src)
+	empty := image.NewPaletted(image.Rect(0, 0, 1, 1), pal)
+
+	tests := []struct {
+		disposal byte
+		want     color.NRGBA
+	}{
+		// The patch stays on the canvas.
+		{gif.DisposalNone, color.NRGBA{0, 0, 255, 255}},
+		// The patch area is cleared to transparent.
+		{gif.DisposalBackground, color.NRGBA{}},
+		// The canvas is restored to the full red frame.
+		{gif.DisposalPrevious, color.NRGBA{255, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		g := &gif.GIF{
+			Image:    []*image.Paletted{full, patch, empty},
+			Disposal: []byte{gif.DisposalNone, tt.disposal, gif.DisposalNone},
+			Config:   image.Config{Width: 4, Height: 4},
+		}
+		frames := gifFrames(g)
+		if len(frames) != 3 {
+			t.Fatalf("got %d frames, expected 3", len(frames))
+		}
+		if c := frames[1].NRGBAAt(3, 3); c != (color.NRGBA{0, 0, 255, 255}) {
+			t.Errorf("disposal %d: the patch pixel of the patched frame is %v, expected blue", tt.disposal, c)
+		}
+		if c := frames[1].NRGBAAt(0, 0); c != (color.NRGBA{255, 0, 0, 255}) {
+			t.Errorf("disposal %d: the pixel outside the patch is %v, expected red", tt.disposal, c)
+		}
+		if c := frames[2].NRGBAAt(3, 3); c != tt.want {
+			t.Errorf("disposal %d: the patch pixel of the next frame is %v, expected %v", tt.disposal, c, tt.want)
+		}
+	}
+}
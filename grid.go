@@ -0,0 +1,134 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// jpegBlock is the size of the JPEG compression blocks.
+	jpegBlock = 8
+	// gridCellSize is the size in pixels of the cells the blocking-artifact grid is measured in.
+	gridCellSize = 32
+	// gridMinStrength is the minimum ratio between the boundary discontinuity at the grid phase
+	// and the mean discontinuity at the other phases for a grid to be considered present.
+	gridMinStrength = 1.25
+	// gridCellStrength is the minimum strength of a misaligned cell grid. The grids of the small cells
+	// are noisier than the grid of the whole image, so a stronger grid is required to flag a cell.
+	gridCellStrength = 2
+)
+
+// gridPhase measures the JPEG blocking-artifact grid of the luminance discontinuities accumulated by their
+// phase: the discontinuities across the block boundaries are stronger than inside the blocks.
+// It returns the phase of the block boundaries, between 0 and 7, and the strength of the grid.
+func gridPhase(bins [jpegBlock]float64) (int, float64) {
+	var phase int
+	for p, v := range bins {
+		if v > bins[phase] {
+			phase = p
+		}
+	}
+	return phase, gridStrength(bins, phase)
+}
+
+// gridStrength returns the ratio between the discontinuity at the phase and the mean discontinuity
+// at the other phases. The strength is zero for the flat areas.
+func gridStrength(bins [jpegBlock]float64, phase int) float64 {
+	var rest float64
+	for p, v := range bins {
+		if p != phase {
+			rest += v
+		}
+	}
+	rest /= jpegBlock - 1
+	if rest < 1e-9 {
+		return 0
+	}
+	return bins[phase] / rest
+}
+
+// misalignedGrid finds the regions of the image whose JPEG blocking-artifact grid is not aligned with the grid
+// of the whole image. A region pasted from another JPEG image, or from a shifted position, usually keeps its
+// own grid, which breaks the 8x8 grid alignment. The image is analyzed in cells, and the cells having a clear
+// grid at a different phase than the whole image are merged into regions. No region is returned when the
+// image itself has no clear grid, like the images which have never been JPEG compressed.
+// The regions are relative to the image origin.
+func misalignedGrid(img image.Image) []image.Rectangle {
+	src := imgToNRGBA(img)
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 2*gridCellSize || h < 2*gridCellSize {
+		return nil
+	}
+
+	luma := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		i := src.PixOffset(b.Min.X, b.Min.Y+y)
+		for x := 0; x < w; x++ {
+			luma[y*w+x] = 0.299*float64(src.Pix[i]) + 0.587*float64(src.Pix[i+1]) + 0.114*float64(src.Pix[i+2])
+			i += 4
+		}
+	}
+
+	// The discontinuity across the boundary between the pixels x-1 and x (or y-1 and y) is the step between
+	// them in excess of the steps next to them, so the texture contributes less than the isolated block edges.
+	// The discontinuities are accumulated by their horizontal and vertical phase, for each cell and for the whole image.
+	step := func(a, b, c, d float64) float64 {
+		return math.Max(0, 2*math.Abs(c-b)-math.Abs(b-a)-math.Abs(d-c))
+	}
+	cols, rows := w/gridCellSize, h/gridCellSize
+	cellX := make([][jpegBlock]float64, cols*rows)
+	cellY := make([][jpegBlock]float64, cols*rows)
+	var imageX, imageY [jpegBlock]float64
+	for y := 2; y < rows*gridCellSize-1; y++ {
+		for x := 2; x < cols*gridCellSize-1; x++ {
+			c := (y/gridCellSize)*cols + x/gridCellSize
+			i := y*w + x
+			dx := step(luma[i-2], luma[i-1], luma[i], luma[i+1])
+			dy := step(luma[i-2*w], luma[i-w], luma[i], luma[i+w])
+			cellX[c][x%jpegBlock] += dx
+			cellY[c][y%jpegBlock] += dy
+			imageX[x%jpegBlock] += dx
+			imageY[y%jpegBlock] += dy
+		}
+	}
+
+	phaseX, strengthX := gridPhase(imageX)
+	phaseY, strengthY := gridPhase(imageY)
+	if strengthX < gridMinStrength || strengthY < gridMinStrength {
+		return nil
+	}
+
+	// A cell is misaligned when it has a strong grid at another phase, and no grid at the image phase.
+	misaligned := func(bins [jpegBlock]float64, imagePhase int) bool {
+		p, s := gridPhase(bins)
+		return p != imagePhase && s >= gridCellStrength && gridStrength(bins, imagePhase) < gridMinStrength
+	}
+	var cells []image.Rectangle
+	for c := range cellX {
+		if misaligned(cellX[c], phaseX) || misaligned(cellY[c], phaseY) {
+			x, y := (c%cols)*gridCellSize, (c/cols)*gridCellSize
+			cells = append(cells, image.Rect(x, y, x+gridCellSize, y+gridCellSize))
+		}
+	}
+	return mergeRegions(image.Rect(0, 0, w, h), cells)
+}
+
+// mergeRegions merges the touching rectangles into the bounding rectangles of their connected regions,
+// and returns them ordered by their position.
+func mergeRegions(bounds image.Rectangle, rects []image.Rectangle) []image.Rectangle {
+	if len(rects) == 0 {
+		return nil
+	}
+	labels, areas := connectedComponents(regionMask(bounds, rects))
+	merged := make([]image.Rectangle, len(areas))
+	for i, label := range labels {
+		if label < 0 {
+			continue
+		}
+		x, y := bounds.Min.X+i%bounds.Dx(), bounds.Min.Y+i/bounds.Dx()
+		merged[label] = merged[label].Union(image.Rect(x, y, x+1, y+1))
+	}
+	sortRegions(merged)
+	return merged
+}
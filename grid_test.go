@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"testing"
+)
+
+// smoothJPEG returns a smooth image saved as a JPEG image, whose blocking artifacts dominate its edges.
+func smoothJPEG(t *testing.T) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 320, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 320; x++ {
+			v := 128 + 60*math.Sin(float64(x)/23) + 50*math.Cos(float64(y)/17+float64(x)/41)
+			img.Set(x, y, color.NRGBA{uint8(v), uint8(255 - v), uint8(v / 2), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dec
+}
+
+func TestMisalignedGrid(t *testing.T) {
+	dec := smoothJPEG(t)
+	if r := misalignedGrid(dec); len(r) != 0 {
+		t.Fatalf("the regions %v of the unmodified JPEG image are reported as misaligned", r)
+	}
+	// A region pasted off the 8x8 grid.
+	img := cloneNRGBA(imgToNRGBA(dec))
+	dst := image.Rect(163, 99, 163+96, 99+96)
+	draw.Draw(img, dst, dec, image.Pt(32, 32), draw.Src)
+	r := misalignedGrid(img)
+	if len(r) != 1 || !r[0].Overlaps(dst) {
+		t.Errorf("got the misaligned regions %v, expected a single region over the pasted region %v", r, dst)
+	}
+
+	cfg := DefaultConfig
+	cfg.DetectGrid = true
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.GridMisaligned) != 1 || !res.GridMisaligned[0].Overlaps(dst) {
+		t.Errorf("got the misaligned regions %v in the result, expected a single region over %v", res.GridMisaligned, dst)
+	}
+	cfg.DetectGrid = false
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.GridMisaligned) != 0 {
+		t.Errorf("the misaligned regions %v are reported without the grid detection", res.GridMisaligned)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "math"
+
+// shiftBin returns the accumulator bin of the shift vector. The shift vectors are accumulated in a two
+// dimensional Hough space over the horizontal and vertical offsets, whose bins are ShiftBinSize pixels wide,
+// so the slightly jittered shifts of the same copy vote for the same peak. The bin is identified by
+// its center, and the mirrored copies are accumulated separately for each mirroring direction.
+func (c Config) shiftBin(v vector) offset {
+	size := c.ShiftBinSize
+	if size <= 0 {
+		size = 1
+	}
+	return offset{
+		x:    math.Round(v.offsetX/size) * size,
+		y:    math.Round(v.offsetY/size) * size,
+		flip: v.flip,
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestShiftBin(t *testing.T) {
+	cfg := DefaultConfig
+	v := vector{offsetX: 51, offsetY: -30, flip: FlipHorizontal}
+	if got, want := cfg.shiftBin(v), (offset{51, -30, FlipHorizontal}); got != want {
+		t.Errorf("got the bin %+v without the bin size, expected %+v", got, want)
+	}
+	cfg.ShiftBinSize = 4
+	if got, want := cfg.shiftBin(v), (offset{52, -32, FlipHorizontal}); got != want {
+		t.Errorf("got the bin %+v with the bin size %v, expected %+v", got, cfg.ShiftBinSize, want)
+	}
+}
+
+func TestHoughGroupsJitteredShifts(t *testing.T) {
+	// The shifts of the copy are jittered by up to 2 pixels horizontally.
+	var vect []vector
+	for i := 0; i < 30; i++ {
+		dx := 51 + i%3
+		vect = append(vect, vector{xa: i, ya: 0, xb: i + dx, yb: 30, offsetX: float64(dx), offsetY: 30})
+	}
+	cfg := DefaultConfig
+	cfg.OffsetThreshold = 20
+	// Each exact shift is voted by 10 pairs only, below the threshold.
+	if suspicious, _ := getSuspiciousBlocks(vect, cfg); len(suspicious) != 0 {
+		t.Errorf("got %d suspicious pairs with the exact shift bins, expected none", len(suspicious))
+	}
+
+	cfg.ShiftBinSize = 4
+	suspicious, hist := getSuspiciousBlocks(vect, cfg)
+	if len(suspicious) != len(vect) {
+		t.Errorf("got %d suspicious pairs with the wider shift bins, expected %d", len(suspicious), len(vect))
+	}
+	if len(hist) != 1 || hist[offset{52, 32, NoFlip}] != len(vect) {
+		t.Errorf("the jittered shifts are not grouped into a single peak: %v", hist)
+	}
+}
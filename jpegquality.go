@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// stdQuant are the standard IJG luminance and chrominance quantization tables of the quality 50, in zigzag order.
+var stdQuant = [2][64]int{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14, 13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37, 29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68, 87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113, 121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26, 26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// errNoQuantTables is returned when the JPEG stream has no quantization table.
+var errNoQuantTables = errors.New("no JPEG quantization tables found")
+
+// EstimateJPEGQuality estimates the quality factor, between 1 and 100, a JPEG image has been saved at.
+// The luminance and chrominance quantization tables of the image are compared with the standard IJG
+// tables scaled for each quality, and the closest quality is returned. The images saved with
+// non-standard tables get the quality of the closest standard tables. It returns ErrUnsupportedFormat
+// when the stream is not a JPEG image.
+func EstimateJPEGQuality(r io.Reader) (int, error) {
+	tables, err := jpegQuantTables(r)
+	if err != nil {
+		return 0, err
+	}
+
+	best, bestDiff := 0, math.Inf(1)
+	for q := 1; q <= 100; q++ {
+		var diff float64
+		for id, table := range tables {
+			std := stdQuant[0]
+			if id > 0 {
+				std = stdQuant[1]
+			}
+			scaled := scaleQuant(std, q)
+			for i, v := range table {
+				diff += math.Abs(float64(v - scaled[i]))
+			}
+		}
+		// The ties are resolved for the higher quality, since the clamped tables of the highest qualities are identical.
+		if diff <= bestDiff {
+			best, bestDiff = q, diff
+		}
+	}
+	return best, nil
+}
+
+// scaleQuant scales the standard quantization table for the quality, the same way as the IJG encoder.
+func scaleQuant(std [64]int, quality int) [64]int {
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var table [64]int
+	for i, v := range std {
+		table[i] = clampInt((v*scale+50)/100, 1, 255)
+	}
+	return table
+}
+
+// jpegQuantTables reads the quantization tables of a JPEG stream, indexed by their destination identifiers.
+// The markers are parsed up to the start of the scan, which is preceded by the tables.
+func jpegQuantTables(r io.Reader) (map[int][64]int, error) {
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil, ErrUnsupportedFormat
+	}
+
+	tables := make(map[int][64]int)
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(br, marker[:]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xff {
+			return nil, ErrUnsupportedFormat
+		}
+		// The fill bytes and the markers without payload are skipped.
+		if marker[1] == 0xff {
+			br.UnreadByte()
+			continue
+		}
+		if marker[1] == 0x01 || (marker[1] >= 0xd0 && marker[1] <= 0xd7) {
+			continue
+		}
+		// Start of scan or end of image.
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			break
+		}
+
+		var length uint16
+		if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length < 2 {
+			return nil, ErrUnsupportedFormat
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(br, segment); err != nil {
+			return nil, err
+		}
+		if marker[1] != 0xdb {
+			continue
+		}
+
+		// A DQT segment may hold several tables, each of them with 8-bit or 16-bit precision values.
+		for len(segment) > 0 {
+			precision, id := segment[0]>>4, int(segment[0]&0x0f)
+			segment = segment[1:]
+			size := 64
+			if precision > 0 {
+				size = 128
+			}
+			if len(segment) < size {
+				return nil, ErrUnsupportedFormat
+			}
+			var table [64]int
+			for i := range table {
+				if precision > 0 {
+					table[i] = int(binary.BigEndian.Uint16(segment[2*i:]))
+				} else {
+					table[i] = int(segment[i])
+				}
+			}
+			tables[id] = table
+			segment = segment[size:]
+		}
+	}
+	if len(tables) == 0 {
+		return nil, errNoQuantTables
+	}
+	return tables, nil
+}
+
+// fileJPEGQuality returns the estimated quality of the JPEG image file,
+// or zero when the file is not a JPEG image or it is not a local file.
+func fileJPEGQuality(path string) int {
+	if isURL(path) {
+		return 0
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	q, err := EstimateJPEGQuality(f)
+	if err != nil {
+		return 0
+	}
+	return q
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateJPEGQuality(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []int{10, 35, 50, 75, 90, 95, 100} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+			t.Fatal(err)
+		}
+		est, err := EstimateJPEGQuality(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if est < q-2 || est > q+2 {
+			t.Errorf("the image saved at the quality %d is estimated at %d", q, est)
+		}
+	}
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EstimateJPEGQuality(&pngData); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got the error %v for a PNG image, expected %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestEstimateJPEGQualityNonStandardTables(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the first quantization table with a flat table.
+	data := buf.Bytes()
+	i := bytes.Index(data, []byte{0xff, 0xdb})
+	if i < 0 {
+		t.Fatal("the encoded image has no quantization table")
+	}
+	for j := i + 5; j < i+5+64; j++ {
+		data[j] = 7
+	}
+	q, err := EstimateJPEGQuality(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q < 1 || q > 100 {
+		t.Errorf("the image with a non-standard table is estimated at %d", q)
+	}
+	// The image is still decodable.
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileJPEGQuality(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "image.jpg")
+	if err := saveImage(path, img, "", 80); err != nil {
+		t.Fatal(err)
+	}
+	if q := fileJPEGQuality(path); q < 78 || q > 82 {
+		t.Errorf("the image file saved at the quality 80 is estimated at %d", q)
+	}
+	pngPath := filepath.Join(dir, "image.png")
+	if err := saveImage(pngPath, img, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if q := fileJPEGQuality(pngPath); q != 0 {
+		t.Errorf("the PNG file has the quality %d", q)
+	}
+	if q := fileJPEGQuality(filepath.Join(dir, "missing.jpg")); q != 0 {
+		t.Errorf("the missing file has the quality %d", q)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// maxInstances is the maximum number of copy-move instances selected by the elbow heuristic.
+	maxInstances = 8
+	// elbowGain is the minimum fraction of the single cluster error an additional cluster must remove
+	// to be selected by the elbow heuristic.
+	elbowGain = 0.1
+	// maxKMeansIterations bounds the number of the k-means refinement iterations.
+	maxKMeansIterations = 100
+)
+
+// Instance is an independent copy-move forgery, separated from the others by its shift vector.
+type Instance struct {
+	// X and Y are the dominant shift vector of the instance in the original image space.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// Flip is the mirroring of the copy, horizontal or vertical, if any.
+	Flip string `json:"flip,omitempty"`
+	// Blocks are the forged source blocks supporting the instance, in the original image space.
+	Blocks []image.Rectangle `json:"blocks"`
+}
+
+// copyInstances clusters the forged block pairs by their shift vectors into independent copy-move instances
+// with the k-means algorithm. The mirrored copies are not displaced by a translation, so the pairs of each
+// mirroring direction are clustered separately. The number of clusters is k, or it is selected by
+// the elbow heuristic when k is zero. The instances supported by fewer than minBlocks pairs are dropped,
+// and the rest are ordered by their decreasing support.
+func copyInstances(pairs []regionPair, scale float64, k, minBlocks int) []Instance {
+	groups := make(map[Flip][]regionPair)
+	for _, p := range pairs {
+		groups[p.shift.flip] = append(groups[p.shift.flip], p)
+	}
+
+	var instances []Instance
+	for flip, group := range groups {
+		points := make([][2]float64, len(group))
+		for i, p := range group {
+			points[i] = [2]float64{p.shift.x * scale, p.shift.y * scale}
+		}
+		n := k
+		if n == 0 {
+			n = elbowClusters(points)
+		}
+		labels, _ := kmeans(points, n)
+
+		clusters := make(map[int][]int)
+		for i, l := range labels {
+			clusters[l] = append(clusters[l], i)
+		}
+		for _, members := range clusters {
+			if len(members) < minBlocks {
+				continue
+			}
+			// The dominant shift is the most frequent shift of the cluster, which is not skewed by the outliers like the mean.
+			counts := make(map[[2]float64]int)
+			inst := Instance{Flip: flip.String()}
+			best := -1
+			for _, i := range members {
+				pt := points[i]
+				counts[pt]++
+				if c := counts[pt]; c > best || (c == best && (pt[0] < inst.X || (pt[0] == inst.X && pt[1] < inst.Y))) {
+					best, inst.X, inst.Y = c, pt[0], pt[1]
+				}
+				inst.Blocks = append(inst.Blocks, group[i].src)
+			}
+			sortRegions(inst.Blocks)
+			instances = append(instances, inst)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		a, b := instances[i], instances[j]
+		switch {
+		case len(a.Blocks) != len(b.Blocks):
+			return len(a.Blocks) > len(b.Blocks)
+		case a.X != b.X:
+			return a.X < b.X
+		case a.Y != b.Y:
+			return a.Y < b.Y
+		}
+		return a.Flip < b.Flip
+	})
+	return instances
+}
+
+// elbowClusters selects the number of clusters with the elbow heuristic: clusters are added
+// while each new cluster reduces the squared error by at least elbowGain of the single cluster error.
+func elbowClusters(points [][2]float64) int {
+	distinct := make(map[[2]float64]bool)
+	for _, p := range points {
+		distinct[p] = true
+	}
+	limit := maxInstances
+	if len(distinct) < limit {
+		limit = len(distinct)
+	}
+
+	_, base := kmeans(points, 1)
+	k, prev := 1, base
+	for k < limit {
+		_, sse := kmeans(points, k+1)
+		if prev-sse < elbowGain*base {
+			break
+		}
+		k, prev = k+1, sse
+	}
+	return k
+}
+
+// kmeans partitions the points into k clusters, and returns the cluster label of each point and the sum
+// of the squared distances of the points to their cluster centers. The centers are initialized
+// deterministically by the farthest point traversal, starting from the first point.
+func kmeans(points [][2]float64, k int) ([]int, float64) {
+	labels := make([]int, len(points))
+	if len(points) == 0 {
+		return labels, 0
+	}
+	if k > len(points) {
+		k = len(points)
+	}
+	if k < 1 {
+		k = 1
+	}
+
+	dist := func(a, b [2]float64) float64 {
+		dx, dy := a[0]-b[0], a[1]-b[1]
+		return dx*dx + dy*dy
+	}
+	centers := [][2]float64{points[0]}
+	for len(centers) < k {
+		far, farDist := 0, -1.0
+		for i, p := range points {
+			d := math.Inf(1)
+			for _, c := range centers {
+				d = math.Min(d, dist(p, c))
+			}
+			if d > farDist {
+				far, farDist = i, d
+			}
+		}
+		centers = append(centers, points[far])
+	}
+
+	var sse float64
+	for iter := 0; iter < maxKMeansIterations; iter++ {
+		changed := iter == 0
+		sse = 0
+		for i, p := range points {
+			best, bestDist := 0, math.Inf(1)
+			for c, center := range centers {
+				if d := dist(p, center); d < bestDist {
+					best, bestDist = c, d
+				}
+			}
+			if labels[i] != best {
+				labels[i], changed = best, true
+			}
+			sse += bestDist
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, k)
+		for i, p := range points {
+			s := &sums[labels[i]]
+			s[0], s[1], s[2] = s[0]+p[0], s[1]+p[1], s[2]+1
+		}
+		for c, s := range sums {
+			// The empty clusters keep their previous center.
+			if s[2] > 0 {
+				centers[c] = [2]float64{s[0] / s[2], s[1] / s[2]}
+			}
+		}
+	}
+	return labels, sse
+}
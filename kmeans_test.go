@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestKMeans(t *testing.T) {
+	points := [][2]float64{{0, 0}, {1, 0}, {0, 1}, {50, 50}, {51, 50}, {50, 51}}
+	labels, sse := kmeans(points, 2)
+	for i := 1; i < 3; i++ {
+		if labels[i] != labels[0] || labels[i+3] != labels[3] {
+			t.Fatalf("expected the points to be clustered by their proximity, got the labels %v", labels)
+		}
+	}
+	if labels[0] == labels[3] {
+		t.Fatalf("expected two clusters, got the labels %v", labels)
+	}
+	// Each cluster of three points has its center at a third of the unit offsets.
+	if want := 2 * 4.0 / 3; sse < want-1e-9 || sse > want+1e-9 {
+		t.Errorf("expected the squared error %v, got %v", want, sse)
+	}
+
+	if got := elbowClusters(points); got != 2 {
+		t.Errorf("expected the elbow heuristic to select 2 clusters, got %d", got)
+	}
+	if got := elbowClusters([][2]float64{{7, 7}, {7, 7}, {7, 7}}); got != 1 {
+		t.Errorf("expected the elbow heuristic to select 1 cluster of the identical points, got %d", got)
+	}
+}
+
+func TestCopyInstances(t *testing.T) {
+	var pairs []regionPair
+	for i := 0; i < 30; i++ {
+		pairs = append(pairs,
+			regionPair{src: image.Rect(i, 0, i+8, 8), shift: offset{x: 90, y: 60}},
+			regionPair{src: image.Rect(i, 50, i+8, 58), shift: offset{x: -40, y: 70}},
+		)
+	}
+	// An outlier of the first instance does not move its dominant shift.
+	pairs = append(pairs, regionPair{src: image.Rect(3, 3, 11, 11), shift: offset{x: 91, y: 60}})
+
+	instances := copyInstances(pairs, 1, 0, 2)
+	if len(instances) != 2 {
+		t.Fatalf("expected 2 instances, got %d", len(instances))
+	}
+	if got := instances[0]; got.X != 90 || got.Y != 60 || len(got.Blocks) != 31 {
+		t.Errorf("expected the instance of 31 blocks shifted by 90,60 first, got %v,%v of %d blocks", got.X, got.Y, len(got.Blocks))
+	}
+	if got := instances[1]; got.X != -40 || got.Y != 70 || len(got.Blocks) != 30 {
+		t.Errorf("expected the instance of 30 blocks shifted by -40,70, got %v,%v of %d blocks", got.X, got.Y, len(got.Blocks))
+	}
+
+	if got := copyInstances(pairs, 1, 1, 1); len(got) != 1 {
+		t.Errorf("expected a single instance with k=1, got %d", len(got))
+	}
+	if got := copyInstances(pairs, 1, 0, 40); len(got) != 0 {
+		t.Errorf("expected the instances of too few blocks to be dropped, got %d", len(got))
+	}
+	if got := copyInstances(pairs, 2, 0, 2); got[0].X != 180 || got[0].Y != 120 {
+		t.Errorf("expected the shift scaled to the original image space, got %v,%v", got[0].X, got[0].Y)
+	}
+}
+
+func TestDetectTwoCopies(t *testing.T) {
+	img, _ := authenticImage(t, 256, 256)
+	draw.Draw(img, image.Rect(160, 40, 200, 80), img, image.Pt(20, 150), draw.Src)
+	draw.Draw(img, image.Rect(30, 190, 70, 230), img, image.Pt(150, 150), draw.Src)
+
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Instances) != 2 {
+		t.Fatalf("expected 2 copy-move instances, got %d", len(res.Instances))
+	}
+	// The shift vectors are oriented from left to right, so the second copy is shifted back to its source.
+	for i, want := range [][2]float64{{140, -110}, {120, -40}} {
+		found := false
+		for _, in := range res.Instances {
+			if in.X == want[0] && in.Y == want[1] {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("copy %d: expected an instance shifted by %v,%v", i, want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// debugLog prints the diagnostic messages, like the block and feature counts or the timings.
+// It is silent unless the verbose mode is enabled.
+var debugLog = log.New(ioutil.Discard, "[debug] ", log.Ltime)
+
+// verbose indicates whether the diagnostic messages and the progress bars are printed.
+var verbose bool
+
+// setVerbose enables or disables the diagnostic output. The diagnostics are always
+// printed on the standard error, keeping the standard output clean for the results.
+func setVerbose(enabled bool) {
+	verbose = enabled
+	if enabled {
+		debugLog.SetOutput(os.Stderr)
+	} else {
+		debugLog.SetOutput(ioutil.Discard)
+	}
+}
+
+// progressBar reports the progress of a processing stage.
+type progressBar interface {
+	Increment() int
+	Finish()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureOutput returns what the function writes on the standard output and the standard error.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(f *os.File, dst *string, done chan<- struct{}) {
+		b, _ := ioutil.ReadAll(f)
+		*dst = string(b)
+		close(done)
+	}
+	outDone, errDone := make(chan struct{}), make(chan struct{})
+	go read(outR, &stdout, outDone)
+	go read(errR, &stderr, errDone)
+
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	// The logger keeps the writer it has been given, so it is set up again on the redirected standard error.
+	setVerbose(verbose)
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+		setVerbose(verbose)
+	}()
+	fn()
+
+	outW.Close()
+	errW.Close()
+	<-outDone
+	<-errDone
+	return stdout, stderr
+}
+
+func TestQuietModeOutput(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detect := func() {
+		if _, err := Detect(img, DefaultConfig); err != nil {
+			t.Error(err)
+		}
+	}
+
+	setVerbose(false)
+	stdout, stderr := captureOutput(t, detect)
+	if stdout != "" || stderr != "" {
+		t.Errorf("the quiet detection printed %q on the standard output and %q on the standard error", stdout, stderr)
+	}
+
+	setVerbose(true)
+	defer setVerbose(false)
+	stdout, stderr = captureOutput(t, detect)
+	if stdout != "" {
+		t.Errorf("the verbose detection printed %q on the standard output", stdout)
+	}
+	if !strings.Contains(stderr, "[debug] ") || !strings.Contains(stderr, "Features: ") {
+		t.Errorf("the verbose detection didn't print the diagnostics on the standard error: %q", stderr)
+	}
+}
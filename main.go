@@ -1,61 +1,26 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	_ "image/jpeg"
+	"image/jpeg"
 	"image/png"
 	_ "image/png"
-	"log"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
-	"time"
-
-	"github.com/nfnt/resize"
-	"gopkg.in/cheggaaa/pb.v1"
+	"strings"
 )
 
-// MaxImageSize is the resized image maximum width or height depending on the image ratio.
+// MaxImageSize is the default resized image maximum width or height depending on the image ratio.
 const MaxImageSize = 320
 
-const Banner = `
-  __                          _
- / _| ___  _ __ ___ _ __  ___(_) ___
-| |_ / _ \| '__/ _ \ '_ \/ __| |/ __|
-|  _| (_) | | |  __/ | | \__ \ | (__
-|_|  \___/|_|  \___|_| |_|___/_|\___|
-
-Image forgery detection library.
-    Version: %s
-
-`
-
 // Version indicates the current build version.
 var Version string
 
-var (
-	// Flags
-	source            = flag.String("in", "", "Input image")
-	destination       = flag.String("out", "", "Output image")
-	blurRadius        = flag.Int("blur", 1, "Blur radius")
-	blockSize         = flag.Int("bs", 4, "Block size")
-	offsetThreshold   = flag.Int("ot", 72, "Offset threshold")
-	distanceThreshold = flag.Float64("dt", 0.4, "Distance threshold")
-	forgeryThreshold  = flag.Float64("ft", 210, "Forgery threshold")
-)
-
-// pixel struct contains the discrete cosine transformation R,G,B,Y values.
-type pixel struct {
-	r, g, b, y float64
-}
-
-// dctPx stores the DCT pixel values.
-type dctPx [][]pixel
-
 // imageBlock contains the generated block upper left position and the stored image.
 type imageBlock struct {
 	x   int
@@ -63,11 +28,19 @@ type imageBlock struct {
 	img image.Image
 }
 
-// vector struct contains the neighboring blocks top left position and the shift vectors between them.
+// vector struct contains the matched blocks top left position and the shift vector between them.
+// The shift vector is the spatial displacement from the source (a) to the destination (b) block,
+// oriented so that a copy-move produces the same shift for every block pair it contains.
 type vector struct {
 	xa, ya           int
 	xb, yb           int
 	offsetX, offsetY float64
+	// scale and rotation are the estimated transformation of the destination block, when known.
+	scale, rotation float64
+	// flip is the mirroring of the destination block. The shift of the mirrored blocks is measured
+	// from the mirrored position: the horizontal (or vertical) offset is the sum of the two block
+	// coordinates, which is the same for every block pair of a mirrored copy.
+	flip Flip
 }
 
 // feature struct contains the feature blocks x, y position and their respective values.
@@ -75,6 +48,10 @@ type feature struct {
 	x    int
 	y    int
 	coef float64
+	// variance is the luminance variance of the block, computed only for the adaptive threshold.
+	variance float64
+	// flip is the mirroring of the block the feature has been extracted from.
+	flip Flip
 }
 
 // q4x4 is the quantization matrix table.
@@ -85,228 +62,83 @@ var q4x4 = [][]float64{
 	{49.0, 78.0, 103.0, 120.0},
 }
 
-var (
-	resizedImg     image.Image
-	features       []feature
-	vectors        []vector
-	cr, cg, cb, cy float64
-)
-
-func main() {
-	done := make(chan struct{})
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, fmt.Sprintf(Banner, Version))
-		flag.PrintDefaults()
-	}
-	flag.Parse()
-
-	if len(*source) == 0 || len(*destination) == 0 {
-		log.Fatal("Usage: forensic -in input.jpg -out out.jpg")
-	}
-
-	if *blockSize <= 1 {
-		log.Fatal("ERROR: the block size must be greater then 1.")
-	}
-
-	start := time.Now()
-
-	input, err := os.Open(*source)
-	defer input.Close()
-
-	if err != nil {
-		log.Fatalf("Error reading the image file: %v", err)
-	}
-	src, _, err := image.Decode(input)
-	if err != nil {
-		log.Fatalf("Error decoding the image: %v", err)
-	}
-
-	if src.Bounds().Dx() > MaxImageSize {
-		resizedImg = resize.Resize(MaxImageSize, 0, src, resize.Lanczos3)
-	} else if src.Bounds().Dy() > MaxImageSize {
-		resizedImg = resize.Resize(0, MaxImageSize, src, resize.Lanczos3)
-	} else {
-		resizedImg = src
-	}
-
-	go func() {
-		var output string
-		precision := float64(process(resizedImg, done))
-		if precision > 50.0 {
-			output = fmt.Sprintf("%.0f%% the image is forged!", precision)
-		} else {
-			precision = 100 - precision
-			output = fmt.Sprintf("%.0f%% the image is NOT forged!", precision)
-		}
-		fmt.Println(output)
-	}()
-	<-done
-
-	fmt.Printf("\nDone in: %.2fs\n", time.Since(start).Seconds())
-}
-
-// process analyze the input image and detect forgeries.
-// It returns the precision score and a boolean value indication
-func process(input image.Image, done chan struct{}) float64 {
-	img := imgToNRGBA(input)
+// annotate draws the detected forged regions over the original image.
+// The source regions are drawn in the highlight color and the destination regions in its complementary color.
+// With a line thickness the region outlines are drawn, otherwise the regions are filled,
+// using the forgery mask for the source regions when available.
+func annotate(src image.Image, res *Result, cfg Config) *image.RGBA {
+	img := imgToNRGBA(src)
 	output := image.NewRGBA(img.Bounds())
 	draw.Draw(output, image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()), img, image.ZP, draw.Src)
 
-	// Blur the image to eliminate the details.
-	if *blurRadius > 0 {
-		img = StackBlur(img, uint32(*blurRadius))
-	}
+	overlay := cfg.highlightColor()
+	complement := color.RGBA{overlay.A - overlay.R, overlay.A - overlay.G, overlay.A - overlay.B, overlay.A}
 
-	// Convert image to YUV color space
-	yuv := convertRGBImageToYUV(img)
-	newImg := image.NewRGBA(yuv.Bounds())
-	draw.Draw(newImg, image.Rect(0, 0, yuv.Bounds().Dx(), yuv.Bounds().Dy()), yuv, image.ZP, draw.Src)
-
-	dx, dy := yuv.Bounds().Max.X, yuv.Bounds().Max.Y
-	bdx, bdy := (dx - *blockSize + 1), (dy - *blockSize + 1)
-	n := math.Max(float64(dx), float64(dy))
-
-	var blocks []imageBlock
-	for i := 0; i < bdx; i++ {
-		for j := 0; j < bdy; j++ {
-			r := image.Rect(i, j, i+*blockSize, j+*blockSize)
-			block := newImg.SubImage(r).(*image.RGBA)
-			blocks = append(blocks, imageBlock{x: i, y: j, img: block})
+	if cfg.LineThickness > 0 {
+		for _, rect := range res.Destinations {
+			drawOutline(output, rect, complement, cfg.LineThickness)
 		}
-	}
-
-	bar := pb.StartNew(len(blocks))
-	bar.Prefix("Generate: ")
-
-	for _, block := range blocks {
-		// Average RGB value.
-		var avr, avg, avb float64
-
-		b := block.img.(*image.RGBA)
-		i0 := b.PixOffset(b.Bounds().Min.X, b.Bounds().Min.Y)
-		i1 := i0 + b.Bounds().Dx()*4
-
-		dctPixels := make(dctPx, *blockSize**blockSize)
-		for u := 0; u < *blockSize; u++ {
-			dctPixels[u] = make([]pixel, *blockSize)
-			for v := 0; v < *blockSize; v++ {
-				for i := i0; i < i1; i += 4 {
-					// Obtain the pixels converted to YUV color space
-					yc, uc, vc, _ := b.Pix[i+0], b.Pix[i+2], b.Pix[i+2], b.Pix[i+3]
-					// Convert YUV to RGB and obtain the R,G,B value
-					r, g, b := color.YCbCrToRGB(yc, uc, vc)
-
-					for x := 0; x < *blockSize; x++ {
-						for y := 0; y < *blockSize; y++ {
-							// Compute Discrete Cosine coefficients
-							cr += dct(float64(x), float64(y), float64(u), float64(v), float64(*blockSize)) * float64(r)
-							cg += dct(float64(x), float64(y), float64(u), float64(v), float64(*blockSize)) * float64(g)
-							cb += dct(float64(x), float64(y), float64(u), float64(v), float64(*blockSize)) * float64(b)
-							cy += dct(float64(x), float64(y), float64(u), float64(v), float64(*blockSize)) * float64(yc)
-
-							avr += float64(r)
-							avg += float64(g)
-							avb += float64(b)
-						}
-					}
-				}
-
-				// Normalize alpha channel.
-				alpha := func(a float64) float64 {
-					if a == 0 {
-						return math.Sqrt(1.0 / float64(n))
-					} else {
-						return math.Sqrt(2.0 / float64(n))
-					}
-				}
-
-				cu, cv := float64(u), float64(v)
-				cr *= alpha(cu) * alpha(cv)
-				cg *= alpha(cu) * alpha(cv)
-				cb *= alpha(cu) * alpha(cv)
-				cy *= alpha(cu) * alpha(cv)
-
-				dctPixels[u][v] = pixel{cr, cg, cb, cy}
-
-				// Obtain the quantized DCT coefficients.
-				if *blockSize <= 4 {
-					dctPixels[u][v].r = dctPixels[u][v].r / q4x4[u][v]
-					dctPixels[u][v].g = dctPixels[u][v].g / q4x4[u][v]
-					dctPixels[u][v].b = dctPixels[u][v].b / q4x4[u][v]
-					dctPixels[u][v].y = dctPixels[u][v].y / q4x4[u][v]
-				}
-			}
+		for _, rect := range res.Regions {
+			drawOutline(output, rect, overlay, cfg.LineThickness)
 		}
-		avr /= float64(*blockSize * *blockSize)
-		avg /= float64(*blockSize * *blockSize)
-		avb /= float64(*blockSize * *blockSize)
-
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[0][0].y})
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[0][1].y})
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[1][0].y})
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[0][0].r})
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[0][0].g})
-		features = append(features, feature{x: block.x, y: block.y, coef: dctPixels[0][0].b})
-
-		// Append average R,G,B values to the features vector(slice).
-		features = append(features, feature{x: block.x, y: block.y, coef: avr})
-		features = append(features, feature{x: block.x, y: block.y, coef: avb})
-		features = append(features, feature{x: block.x, y: block.y, coef: avg})
-		bar.Increment()
+		return output
 	}
-	bar.Finish()
 
-	// Lexicographically sort the feature vectors
-	sort.Sort(featVec(features))
-
-	bar = pb.StartNew(len(features) - 1)
-	bar.Prefix("Analyze: ")
-
-	for i := 0; i < len(features)-1; i++ {
-		blockA, blockB := features[i], features[i+1]
-		result := analyzeBlocks(blockA, blockB)
-
-		if result != nil {
-			vectors = append(vectors, *result)
+	forgedImg := image.NewRGBA(output.Bounds())
+	for _, rect := range res.Destinations {
+		draw.Draw(forgedImg, rect, &image.Uniform{complement}, image.ZP, draw.Over)
+	}
+	if res.Mask != nil {
+		draw.DrawMask(forgedImg, forgedImg.Bounds(), &image.Uniform{overlay}, image.ZP, res.Mask, res.Mask.Bounds().Min, draw.Over)
+	} else {
+		for _, rect := range res.Regions {
+			draw.Draw(forgedImg, rect, &image.Uniform{overlay}, image.ZP, draw.Over)
 		}
-		bar.Increment()
 	}
-	bar.Finish()
 
-	simBlocks := getSuspiciousBlocks(vectors)
-	forgedBlocks, _ := filterOutNeighbors(simBlocks)
+	final := StackBlur(imgToNRGBA(forgedImg), 10)
+	draw.Draw(output, output.Bounds(), final, image.ZP, draw.Over)
 
-	simBlocksNum := len(simBlocks)
-	forgedBlocksNum := len(forgedBlocks)
+	return output
+}
 
-	// precision indicates the detection accuracy
-	var precision = 0.0
-	if forgedBlocksNum > 0 {
-		precision = 100 - (float64(forgedBlocksNum) / (float64(forgedBlocksNum + simBlocksNum)) * 100)
+// drawOutline draws the outline of the rectangle with the provided line thickness, inside the rectangle.
+func drawOutline(dst draw.Image, r image.Rectangle, c color.Color, thickness int) {
+	u := &image.Uniform{c}
+	t := thickness
+	for _, side := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+t),
+		image.Rect(r.Min.X, r.Max.Y-t, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+t, r.Max.Y),
+		image.Rect(r.Max.X-t, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(dst, side.Intersect(r), u, image.ZP, draw.Over)
 	}
+}
 
-	forgedImg := image.NewRGBA(img.Bounds())
-	overlay := color.RGBA{255, 0, 0, 255}
-
-	fmt.Println("\nNumber of forged blocks detected: ", forgedBlocksNum)
-	for _, bl := range forgedBlocks {
-		draw.Draw(forgedImg, image.Rect(bl.xa, bl.ya, bl.xa+*blockSize*2, bl.ya+*blockSize*2), &image.Uniform{overlay}, image.ZP, draw.Over)
+// saveImage encodes the image into the destination file. The format is either png or jpeg,
+// or it is inferred from the file extension when empty. The quality applies only to the JPEG images.
+func saveImage(path string, img image.Image, format string, quality int) error {
+	if format == "" {
+		format = "png"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".jpg" || ext == ".jpeg" {
+			format = "jpeg"
+		}
 	}
 
-	final := StackBlur(imgToNRGBA(forgedImg), 10)
-	draw.Draw(output, img.Bounds(), final, image.ZP, draw.Over)
-
-	out, err := os.Create(*destination)
+	out, err := os.Create(path)
 	if err != nil {
-		fmt.Printf("Error creating output file: %v", err)
+		return err
 	}
+	defer out.Close()
 
-	if err := png.Encode(out, output); err != nil {
-		fmt.Printf("Error encoding image file: %v", err)
+	switch format {
+	case "png":
+		return png.Encode(out, img)
+	case "jpeg", "jpg":
+		return jpeg.Encode(out, img, &jpeg.Options{Quality: quality})
 	}
-	done <- struct{}{}
-
-	return precision
+	return fmt.Errorf("unknown output image format %q", format)
 }
 
 //convertRGBImageToYUV coverts the image from RGB to YUV color space.
@@ -325,99 +157,143 @@ func convertRGBImageToYUV(img image.Image) image.Image {
 	return yuvImage
 }
 
-// analyzeBlocks checks weather two neighboring blocks are considered almost identical.
-func analyzeBlocks(blockA, blockB feature) *vector {
-	// Compute the euclidean distance between two neighboring blocks.
-	dx := float64(blockA.x) - float64(blockB.x)
-	dy := float64(blockA.y) - float64(blockB.y)
-	dist := math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
+// analyzeBlocks checks weather two neighboring features in the sorted order are almost identical
+// and belong to distinct blocks. If so, it returns the shift vector between the two blocks.
+func analyzeBlocks(blockA, blockB feature, cfg Config) *vector {
+	// The mirrored blocks are matched only with the unmirrored blocks.
+	if blockA.flip != NoFlip && blockB.flip != NoFlip {
+		return nil
+	}
+	// The features must be almost identical.
+	if math.Abs(blockA.coef-blockB.coef) >= cfg.matchThreshold(blockA, blockB) {
+		return nil
+	}
+	return shiftVector(blockA, blockB, cfg)
+}
+
+// shiftVector returns the shift vector between two similar blocks, or nil
+// when the blocks are too close or too distant to be a copy.
+func shiftVector(blockA, blockB feature, cfg Config) *vector {
+	// Orient the shift vector from left to right (and top to bottom for vertical shifts),
+	// so the blocks of a copied region produce the same shift regardless of their sorted order.
+	if blockB.x < blockA.x || (blockB.x == blockA.x && blockB.y < blockA.y) {
+		blockA, blockB = blockB, blockA
+	}
+	dx := float64(blockB.x - blockA.x)
+	dy := float64(blockB.y - blockA.y)
+
+	// Close blocks overlap and are similar by nature, which doesn't indicate a copy,
+	// while the very distant blocks are rather similar by coincidence.
+	dist := distance(cfg.Metric, dx, dy)
+	if dist < cfg.minShift() || (cfg.MaxShift > 0 && dist > cfg.MaxShift) {
+		return nil
+	}
 
-	res := &vector{
+	v := &vector{
 		xa:      blockA.x,
 		ya:      blockA.y,
 		xb:      blockB.x,
 		yb:      blockB.y,
-		offsetX: math.Abs(dx),
-		offsetY: math.Abs(dy),
+		offsetX: dx,
+		offsetY: dy,
+		flip:    blockA.flip,
 	}
-
-	if dist < *distanceThreshold {
-		return res
+	// At most one of the blocks is mirrored.
+	if blockB.flip != NoFlip {
+		v.flip = blockB.flip
+	}
+	switch v.flip {
+	case FlipHorizontal:
+		v.offsetX = float64(blockA.x + blockB.x)
+	case FlipVertical:
+		v.offsetY = float64(blockA.y + blockB.y)
 	}
-	return nil
+	return v
 }
 
 type offset struct {
 	x, y float64
+	flip Flip
 }
 
 type newVector []vector
 
 // getSuspiciousBlocks analyze pair of candidate and check for
 // similarity by computing the accumulative number of shift vectors.
-func getSuspiciousBlocks(vect []vector) newVector {
+// The shift vectors are accumulated in the bins of a Hough accumulator, and the pairs voting
+// for a peak above the offset threshold are suspicious. It also returns the accumulator.
+func getSuspiciousBlocks(vect []vector, cfg Config) (newVector, map[offset]int) {
 	var suspiciousBlocks newVector
 	//For each pair of candidate compute the accumulative number of the corresponding shift vectors.
 	duplicates := make(map[offset]int)
+	for _, v := range vect {
+		duplicates[cfg.shiftBin(v)]++
+	}
 
-	bar := pb.StartNew(len(vect)).Prefix("Detect: ")
+	bar := newProgressBar(len(vect), "Detect: ")
 
+	// If the accumulative number of corresponding shift vectors is greater than
+	// a predefined threshold, the corresponding regions are marked as suspicious.
+	// The block pairs are collected only once, even if they have been matched repeatedly.
+	collected := make(map[vector]bool)
 	for _, v := range vect {
-		// Check for duplicate blocks
-		offsetX := v.offsetX
-		offsetY := v.offsetY
-		offset := &offset{offsetX, offsetY}
-
-		_, exists := duplicates[*offset]
-		if exists {
-			duplicates[*offset]++
-		} else {
-			duplicates[*offset] = 1
-		}
-
-		// If the accumulative number of corresponding shift vectors is greater than
-		// a predefined threshold, the corresponding regions are marked as suspicious.
-		if duplicates[*offset] > *offsetThreshold {
-			suspiciousBlocks = append(suspiciousBlocks, vector{
-				v.xa, v.ya, v.xb, v.yb, v.offsetX, v.offsetY,
-			})
+		if duplicates[cfg.shiftBin(v)] > cfg.OffsetThreshold && !collected[v] {
+			collected[v] = true
+			suspiciousBlocks = append(suspiciousBlocks, v)
 		}
 		bar.Increment()
 	}
 	bar.Finish()
-	return suspiciousBlocks
+	return suspiciousBlocks, duplicates
+}
+
+// topShifts returns the n most frequent shift vectors of the histogram sorted by decreasing count,
+// with the offsets scaled to the original image space.
+func topShifts(hist map[offset]int, n int, scale float64) []Shift {
+	shifts := make([]Shift, 0, len(hist))
+	for o, count := range hist {
+		shifts = append(shifts, Shift{X: o.x * scale, Y: o.y * scale, Count: count, Flip: o.flip.String()})
+	}
+	// The offsets are compared too, so the equally frequent shifts have a deterministic order.
+	sort.Slice(shifts, func(i, j int) bool {
+		a, b := shifts[i], shifts[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.Flip < b.Flip
+	})
+	if len(shifts) > n {
+		shifts = shifts[:n]
+	}
+	return shifts
 }
 
-// filterOutNeighbors filters out the neighboring blocks.
-func filterOutNeighbors(vect []vector) (newVector, bool) {
+// filterOutNeighbors filters out the matched pairs whose blocks are neighbors. The blocks of a pair
+// are compared by their own displacement: a copy is moved farther than the threshold, while the similar
+// blocks closer than it are parts of the same smooth area. An empty input yields no forged blocks.
+func filterOutNeighbors(vect []vector, cfg Config) (newVector, bool) {
 	var forgedBlocks newVector
-	var isForged bool
-
-	bar := pb.StartNew(len(vect)).Prefix("Filter: ")
-
-	for i := 1; i < len(vect); i++ {
-		blockA, blockB := vect[i-1], vect[i]
-
-		// Calculate the euclidean distance between both regions.
-		dx := float64(blockA.xa - blockB.xa)
-		dy := float64(blockA.ya - blockB.ya)
-		dist := math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
-
-		// Evaluate the euclidean distance distance between two regions
-		// and make sure the distance is greater than a predefined threshold.
-		if dist > *forgeryThreshold {
-			forgedBlocks = append(forgedBlocks, vector{
-				blockA.xa, blockA.ya, blockA.xb, blockA.yb, blockA.offsetX, vect[i].offsetY,
-			})
-			// We need to verify if an image is forged only once.
-			if !isForged {
-				isForged = true
-			}
+
+	bar := newProgressBar(len(vect), "Filter: ")
+
+	for _, v := range vect {
+		// Calculate the distance between both blocks of the pair.
+		dist := distance(cfg.Metric, float64(v.xa-v.xb), float64(v.ya-v.yb))
+
+		// Make sure the distance is greater than a predefined threshold.
+		if dist > cfg.ForgeryThreshold {
+			forgedBlocks = append(forgedBlocks, v)
 		}
 		bar.Increment()
 	}
 	bar.Finish()
-	return forgedBlocks, isForged
+	return forgedBlocks, len(forgedBlocks) > 0
 }
 
 // dct computes the Discrete Cosine Transform.
@@ -429,17 +305,19 @@ func dct(x, y, u, v, w float64) float64 {
 	return a * b
 }
 
-// idct computes the Inverse Discrete Cosine Transform. (Only for testing purposes.)
+// idct computes the Inverse Discrete Cosine Transform basis function of the u, v frequency at the x, y sample,
+// with the orthonormal normalization, so summing the DCT coefficients weighted by it recovers the samples.
+// It is used for verifying the DCT implementation, by reconstructing the blocks from their coefficients.
 func idct(u, v, x, y, w float64) float64 {
 	// normalization
 	alpha := func(a float64) float64 {
 		if a == 0 {
-			return 1.0 / math.Sqrt(2.0)
+			return math.Sqrt(1.0 / w)
 		}
-		return 1.0
+		return math.Sqrt(2.0 / w)
 	}
 
-	return dct(u, v, x, y, w) * alpha(u) * alpha(v)
+	return dct(x, y, u, v, w) * alpha(u) * alpha(v)
 }
 
 // Implement sorting function on feature vector
@@ -447,12 +325,43 @@ type featVec []feature
 
 func (a featVec) Len() int      { return len(a) }
 func (a featVec) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// Less orders the features by their coefficient. The equal coefficients are ordered by the block position and
+// mirroring, so the sorted order is fully specified and doesn't depend on the order of the input features.
 func (a featVec) Less(i, j int) bool {
-	if a[i].coef < a[j].coef {
-		return true
+	fa, fb := a[i], a[j]
+	if fa.coef != fb.coef {
+		return fa.coef < fb.coef
+	}
+	return lessPosition(fa, fb)
+}
+
+// lessPosition orders the features by a hash of the position and the mirroring of their blocks. The raster order
+// would line up the equal features of the flat regions, and their neighbors displaced by the same shifts
+// would be taken for a copy, so the hash scatters them like an arbitrary order, while being reproducible.
+func lessPosition(fa, fb feature) bool {
+	ha, hb := positionHash(fa), positionHash(fb)
+	if ha != hb {
+		return ha < hb
+	}
+	if fa.x != fb.x {
+		return fa.x < fb.x
 	}
-	if a[i].coef > a[j].coef {
-		return false
+	if fa.y != fb.y {
+		return fa.y < fb.y
 	}
-	return a[i].coef < a[j].coef
+	return fa.flip < fb.flip
+}
+
+// positionHash mixes the position and the mirroring of the block of the feature into a pseudorandom value.
+func positionHash(f feature) uint64 {
+	h := uint64(uint32(f.x))<<32 | uint64(uint32(f.y))
+	h ^= uint64(f.flip) * 0x9e3779b97f4a7c15
+	// The finalizer of the SplitMix64 generator.
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
 }
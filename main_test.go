@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetSuspiciousBlocksNoDuplicates(t *testing.T) {
+	pair := func(x, y, dx, dy int) vector {
+		return vector{xa: x, ya: y, xb: x + dx, yb: y + dy, offsetX: float64(dx), offsetY: float64(dy)}
+	}
+	// The offset 10,0 is voted 6 times, by 4 distinct pairs, one of them matched three times,
+	// while the offset 0,20 is voted twice.
+	vect := []vector{
+		pair(0, 0, 10, 0), pair(1, 0, 10, 0), pair(0, 0, 10, 0), pair(2, 0, 10, 0),
+		pair(5, 5, 0, 20), pair(3, 0, 10, 0), pair(0, 0, 10, 0), pair(6, 5, 0, 20),
+	}
+	cfg := DefaultConfig
+	cfg.OffsetThreshold = 3
+	suspicious, hist := getSuspiciousBlocks(vect, cfg)
+
+	if hist[offset{x: 10}] != 6 || hist[offset{y: 20}] != 2 {
+		t.Errorf("unexpected offset counts %v", hist)
+	}
+	want := newVector{pair(0, 0, 10, 0), pair(1, 0, 10, 0), pair(2, 0, 10, 0), pair(3, 0, 10, 0)}
+	if !reflect.DeepEqual(suspicious, want) {
+		t.Errorf("expected each pair of the frequent offset once, got %+v", suspicious)
+	}
+}
+
+func TestShiftVectorDisplacement(t *testing.T) {
+	// The blocks of a copy pasted 40 pixels to the right and 25 pixels down, which are found in
+	// either order in the sorted features.
+	src, dst := feature{x: 12, y: 30}, feature{x: 52, y: 55}
+	for _, pair := range [][2]feature{{src, dst}, {dst, src}} {
+		v := analyzeBlocks(pair[0], pair[1], DefaultConfig)
+		if v == nil {
+			t.Fatal("the copied blocks are not paired")
+		}
+		if v.xa != src.x || v.ya != src.y || v.xb != dst.x || v.yb != dst.y || v.offsetX != 40 || v.offsetY != 25 {
+			t.Errorf("expected the displacement 40,25 from the source to the destination block, got %+v", *v)
+		}
+	}
+
+	// The blocks of a copy pasted up and to the right keep a consistent vertical direction.
+	a, b := feature{x: 10, y: 80}, feature{x: 60, y: 20}
+	v1, v2 := analyzeBlocks(a, b, DefaultConfig), analyzeBlocks(b, a, DefaultConfig)
+	if v1 == nil || v2 == nil || *v1 != *v2 || v1.offsetX != 50 || v1.offsetY != -60 {
+		t.Errorf("expected the same displacement 50,-60 in both orders, got %+v and %+v", v1, v2)
+	}
+}
+
+func TestDominantOffsetIsPasteTranslation(t *testing.T) {
+	img, src, dst, err := SyntheticImage(320, 192, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shift := dst.Min.Sub(src.Min); len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("expected the dominant offset %v of the paste, got %+v", shift, res.Shifts)
+	}
+}
+
+func TestTopShifts(t *testing.T) {
+	hist := map[offset]int{
+		{x: 64, y: 32}:                       90,
+		{x: 3, y: -5}:                        12,
+		{x: -7, y: 4}:                        12,
+		{x: 40, flip: FlipHorizontal}:        12,
+		{x: 10, y: 10}:                       30,
+		{x: 1, y: 1, flip: FlipVertical}:     2,
+		{x: 20, y: 20, flip: FlipHorizontal}: 1,
+	}
+	want := []Shift{
+		{X: 128, Y: 64, Count: 90, Flip: NoFlip.String()},
+		{X: 20, Y: 20, Count: 30, Flip: NoFlip.String()},
+		// The equally frequent shifts are ordered by their offsets.
+		{X: -14, Y: 8, Count: 12, Flip: NoFlip.String()},
+		{X: 6, Y: -10, Count: 12, Flip: NoFlip.String()},
+		{X: 80, Y: 0, Count: 12, Flip: FlipHorizontal.String()},
+	}
+	// The offsets of the analysis at half the size are scaled back to the original image.
+	if got := topShifts(hist, 5, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected top shifts:\n got %+v\nwant %+v", got, want)
+	}
+	if got := topShifts(hist, 100, 1); len(got) != len(hist) {
+		t.Errorf("got %d shifts, expected the whole histogram of %d", len(got), len(hist))
+	}
+	if got := topShifts(nil, 5, 1); len(got) != 0 {
+		t.Errorf("the empty histogram has the shifts %+v", got)
+	}
+}
+
+func TestSaveImageFormats(t *testing.T) {
+	img, _, _, err := SyntheticImage(64, 48, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		file, format, want string
+	}{
+		{"out.png", "", "png"},
+		{"out.jpg", "", "jpeg"},
+		{"out.JPEG", "", "jpeg"},
+		{"out.img", "jpeg", "jpeg"},
+		{"out.jpg", "png", "png"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := saveImage(path, img, tt.format, 90); err != nil {
+			t.Fatalf("%s %q: %v", tt.file, tt.format, err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, format, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s %q: the output image can't be decoded: %v", tt.file, tt.format, err)
+		}
+		if format != tt.want {
+			t.Errorf("%s %q: the output image is encoded as %s, expected %s", tt.file, tt.format, format, tt.want)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Errorf("%s %q: the output image bounds are %v, expected %v", tt.file, tt.format, decoded.Bounds(), img.Bounds())
+		}
+		if format == "png" && !samePixels(decoded, img) {
+			t.Errorf("%s %q: the PNG output image differs from the input image", tt.file, tt.format)
+		}
+	}
+	if err := saveImage(filepath.Join(dir, "out.gif"), img, "gif", 90); err == nil {
+		t.Error("no error for an unknown output image format")
+	}
+}
+
+// samePixels reports whether both images have the same size and the same pixels.
+func samePixels(a, b image.Image) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	ba, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < ba.Dy(); y++ {
+		for x := 0; x < ba.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ba.Min.X+x, ba.Min.Y+y))
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if ca != cb {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestAnalyzeBlocksShiftBand(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.MinShift, cfg.MaxShift = 10, 100
+	a := feature{x: 0, y: 0, coef: 1}
+	tests := []struct {
+		x, y int
+		want bool
+	}{
+		// Too close to be a copy.
+		{5, 0, false},
+		// Mid-range shifts.
+		{10, 0, true},
+		{60, 80, true},
+		// Implausibly distant.
+		{100, 1, false},
+		{300, 0, false},
+	}
+	for _, tt := range tests {
+		b := feature{x: tt.x, y: tt.y, coef: 1}
+		if v := analyzeBlocks(a, b, cfg); (v != nil) != tt.want {
+			t.Errorf("the blocks shifted by %d,%d are matched: %v, expected %v", tt.x, tt.y, v != nil, tt.want)
+		}
+	}
+
+	// The shift band is unlimited without the maximum shift.
+	cfg.MaxShift = 0
+	if analyzeBlocks(a, feature{x: 300, coef: 1}, cfg) == nil {
+		t.Error("the distant blocks are not matched without the maximum shift")
+	}
+
+	for _, max := range []float64{-1, 5} {
+		cfg.MaxShift = max
+		if err := cfg.validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("the maximum shift %v: got the error %v, expected %v", max, err, ErrInvalidConfig)
+		}
+	}
+}
+
+func TestAnnotateColorAndThickness(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 50, 50))
+	res := &Result{
+		Regions:      []image.Rectangle{image.Rect(5, 5, 20, 20)},
+		Destinations: []image.Rectangle{image.Rect(25, 25, 40, 40)},
+	}
+	black := color.RGBA{0, 0, 0, 255}
+	tests := []struct {
+		cfg          Config
+		source, dest color.RGBA
+	}{
+		{Config{HighlightColor: color.RGBA{0, 255, 0, 255}, LineThickness: 3}, color.RGBA{0, 255, 0, 255}, color.RGBA{255, 0, 255, 255}},
+		// The highlight color defaults to red.
+		{Config{LineThickness: 1}, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 255, 255}},
+	}
+	for _, tt := range tests {
+		out := annotate(src, res, tt.cfg)
+		n := tt.cfg.LineThickness
+		for x := 0; x < n; x++ {
+			if c := out.RGBAAt(5+x, 10); c != tt.source {
+				t.Errorf("thickness %d: the source outline pixel %d,10 is %v, expected %v", n, 5+x, c, tt.source)
+			}
+			if c := out.RGBAAt(25+x, 30); c != tt.dest {
+				t.Errorf("thickness %d: the destination outline pixel %d,30 is %v, expected %v", n, 25+x, c, tt.dest)
+			}
+		}
+		if c := out.RGBAAt(5+n, 10); c != black {
+			t.Errorf("thickness %d: the pixel %d,10 inside the outline is %v, expected it unchanged", n, 5+n, c)
+		}
+		if c := out.RGBAAt(25+n, 30); c != black {
+			t.Errorf("thickness %d: the pixel %d,30 inside the outline is %v, expected it unchanged", n, 25+n, c)
+		}
+	}
+}
+
+func TestIDCTInverseOfDCT(t *testing.T) {
+	const n = 4
+	block := randomBlock(n, 1)
+	w := float64(n)
+	// The forward transform with the same orthonormal normalization as the inverse.
+	coefs := make([]float64, n*n)
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ {
+					sum += block[y*n+x] * idct(float64(u), float64(v), float64(x), float64(y), w)
+				}
+			}
+			coefs[v*n+u] = sum
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sum float64
+			for v := 0; v < n; v++ {
+				for u := 0; u < n; u++ {
+					sum += coefs[v*n+u] * idct(float64(u), float64(v), float64(x), float64(y), w)
+				}
+			}
+			if d := math.Abs(sum - block[y*n+x]); d > dctEpsilon {
+				t.Errorf("the sample %d,%d is reconstructed as %v instead of %v", x, y, sum, block[y*n+x])
+			}
+		}
+	}
+
+	// The basis functions are asymmetric in the frequency and the sample arguments.
+	if a, b := idct(1, 0, 0, 0, w), idct(0, 0, 1, 0, w); a == b {
+		t.Errorf("the frequency and the sample arguments are interchangeable: %v", a)
+	}
+}
+
+func TestFeatureSortDeterministic(t *testing.T) {
+	// Most features share one of a few coefficients, like the features of the flat blocks.
+	var feats []feature
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			for _, flip := range []Flip{NoFlip, FlipHorizontal} {
+				feats = append(feats, feature{x: x, y: y, coef: float64((x + y) % 3), flip: flip})
+			}
+		}
+	}
+
+	sorted := func(seed int64) []feature {
+		shuffled := append([]feature(nil), feats...)
+		rnd := rand.New(rand.NewSource(seed))
+		rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		sort.Sort(featVec(shuffled))
+		return shuffled
+	}
+	a, b := sorted(1), sorted(2)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("the equal features are sorted differently depending on their input order")
+	}
+	for i := 1; i < len(a); i++ {
+		p, f := a[i-1], a[i]
+		if p.coef == f.coef && !lessPosition(p, f) {
+			t.Fatalf("the equal features %+v and %+v are not ordered by their position", p, f)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+)
+
+// regionMask returns the binary forgery mask of the provided size, where the pixels covered by
+// the regions are white (255) and the rest of the pixels are black.
+func regionMask(bounds image.Rectangle, regions []image.Rectangle) *image.Gray {
+	mask := image.NewGray(bounds)
+	for _, r := range regions {
+		draw.Draw(mask, r.Intersect(bounds), image.White, image.ZP, draw.Src)
+	}
+	return mask
+}
+
+// areaPercentage returns the percentage of the bounds covered by the union of the regions.
+// The overlapping regions are rasterized into a mask, so their common pixels are counted only once.
+func areaPercentage(bounds image.Rectangle, regions []image.Rectangle) float64 {
+	if bounds.Empty() {
+		return 0
+	}
+	var covered int
+	for _, v := range regionMask(bounds, regions).Pix {
+		if v > 0 {
+			covered++
+		}
+	}
+	return 100 * float64(covered) / float64(bounds.Dx()*bounds.Dy())
+}
+
+// closeMask applies a morphological closing, a dilation followed by an erosion, with a square
+// structuring element of the provided size. It merges the nearby detections and fills the gaps
+// narrower than the structuring element, while keeping the outer extent of the regions.
+func closeMask(mask *image.Gray, size int) *image.Gray {
+	if size < 2 {
+		return mask
+	}
+	return morph(morph(mask, size, true), size, false)
+}
+
+// morph dilates (or erodes) the binary mask with a square structuring element of the provided size.
+// The square element is separable, so the mask is filtered horizontally and then vertically.
+// The window is clamped to the mask edges, so the regions touching the edges are not eroded.
+func morph(mask *image.Gray, size int, dilate bool) *image.Gray {
+	b := mask.Bounds()
+	before := (size - 1) / 2
+	after := size - 1 - before
+
+	filter := func(src *image.Gray, horizontal bool) *image.Gray {
+		dst := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				lo, hi := x-before, x+after
+				if !horizontal {
+					lo, hi = y-before, y+after
+				}
+				// The dilation looks for any set pixel in the window, the erosion for any unset pixel.
+				set := !dilate
+				for i := lo; i <= hi && set != dilate; i++ {
+					px, py := i, y
+					if !horizontal {
+						px, py = x, i
+					}
+					if !image.Pt(px, py).In(b) {
+						continue
+					}
+					set = src.Pix[src.PixOffset(px, py)] > 0
+				}
+				if set {
+					dst.Pix[dst.PixOffset(x, y)] = 255
+				}
+			}
+		}
+		return dst
+	}
+	return filter(filter(mask, true), false)
+}
+
+// connectedComponents labels the 4-connected regions of the set pixels in the binary mask.
+// It returns the label of each pixel in row order (-1 for the unset pixels) and the area of each region.
+func connectedComponents(mask *image.Gray) ([]int, []int) {
+	b := mask.Bounds()
+	w, h := b.Dx(), b.Dy()
+	labels := make([]int, w*h)
+	for i := range labels {
+		labels[i] = -1
+	}
+
+	var areas, queue []int
+	for start := range labels {
+		x, y := start%w, start/w
+		if labels[start] >= 0 || mask.Pix[mask.PixOffset(b.Min.X+x, b.Min.Y+y)] == 0 {
+			continue
+		}
+		label := len(areas)
+		areas = append(areas, 0)
+		labels[start] = label
+		queue = append(queue[:0], start)
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			areas[label]++
+
+			x, y := i%w, i/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[0] >= w || n[1] < 0 || n[1] >= h {
+					continue
+				}
+				j := n[1]*w + n[0]
+				if labels[j] < 0 && mask.Pix[mask.PixOffset(b.Min.X+n[0], b.Min.Y+n[1])] > 0 {
+					labels[j] = label
+					queue = append(queue, j)
+				}
+			}
+		}
+	}
+	return labels, areas
+}
+
+// removeSmallRegions clears the connected regions of the mask smaller than minArea pixels
+// and returns the rectangles which are not part of a removed region.
+func removeSmallRegions(mask *image.Gray, rects []image.Rectangle, minArea int) []image.Rectangle {
+	labels, areas := connectedComponents(mask)
+	b := mask.Bounds()
+	w := b.Dx()
+
+	for i, label := range labels {
+		if label >= 0 && areas[label] < minArea {
+			mask.Pix[mask.PixOffset(b.Min.X+i%w, b.Min.Y+i/w)] = 0
+		}
+	}
+
+	var kept []image.Rectangle
+	for _, r := range rects {
+		// The rectangles are fully set in the mask, so each of them belongs to a single region.
+		r = r.Intersect(b)
+		if r.Empty() {
+			continue
+		}
+		label := labels[(r.Min.Y-b.Min.Y)*w+r.Min.X-b.Min.X]
+		if areas[label] >= minArea {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCloseMaskFillsHole(t *testing.T) {
+	// The 4 pixel blocks cover a 16x16 region, except the block holding a one block hole.
+	const block = 4
+	hole := image.Rect(16, 16, 20, 20)
+	var regions []image.Rectangle
+	for y := 12; y < 28; y += block {
+		for x := 12; x < 28; x += block {
+			if r := image.Rect(x, y, x+block, y+block); r != hole {
+				regions = append(regions, r)
+			}
+		}
+	}
+	mask := regionMask(image.Rect(0, 0, 40, 40), regions)
+	if mask.GrayAt(17, 17).Y != 0 {
+		t.Fatal("the hole is already filled in the raw mask")
+	}
+
+	closed := closeMask(mask, block+1)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			want := uint8(0)
+			if (image.Point{x, y}).In(image.Rect(12, 12, 28, 28)) {
+				want = 255
+			}
+			if got := closed.GrayAt(x, y).Y; got != want {
+				t.Fatalf("the closed mask is %d at %d,%d, expected %d", got, x, y, want)
+			}
+		}
+	}
+	// The raw mask is left unchanged.
+	if mask.GrayAt(17, 17).Y != 0 {
+		t.Error("the closing modified the raw mask")
+	}
+}
+
+func TestCloseMaskMergesNearbyRegions(t *testing.T) {
+	regions := []image.Rectangle{image.Rect(10, 10, 18, 30), image.Rect(22, 10, 30, 30)}
+	// The gap narrower than the structuring element is filled, while the wider one is kept.
+	closed := closeMask(regionMask(image.Rect(0, 0, 40, 40), regions), 5)
+	if closed.GrayAt(20, 20).Y != 255 {
+		t.Error("the 4 pixel gap between the regions is not filled")
+	}
+	closed = closeMask(regionMask(image.Rect(0, 0, 40, 40), regions), 3)
+	if closed.GrayAt(20, 20).Y != 0 {
+		t.Error("the 4 pixel gap is filled by the smaller structuring element")
+	}
+	if closed.GrayAt(9, 15).Y != 0 || closed.GrayAt(30, 15).Y != 0 || closed.GrayAt(15, 30).Y != 0 {
+		t.Error("the closing grew the outer extent of the regions")
+	}
+}
+
+func TestRemoveSmallRegions(t *testing.T) {
+	// The two overlapping rectangles form a single large region, and the others are tiny spurious regions.
+	large := []image.Rectangle{image.Rect(10, 10, 18, 18), image.Rect(14, 14, 40, 40)}
+	tiny := []image.Rectangle{image.Rect(50, 2, 52, 4), image.Rect(2, 50, 5, 53), image.Rect(55, 55, 59, 59)}
+	mask := regionMask(image.Rect(0, 0, 60, 60), append(append([]image.Rectangle(nil), large...), tiny...))
+
+	kept := removeSmallRegions(mask, append(append([]image.Rectangle(nil), large...), tiny...), 50)
+	if !reflect.DeepEqual(kept, large) {
+		t.Errorf("kept the regions %v, expected only %v", kept, large)
+	}
+	for _, r := range tiny {
+		if mask.GrayAt(r.Min.X, r.Min.Y).Y != 0 {
+			t.Errorf("the tiny region %v is still in the mask", r)
+		}
+	}
+	if mask.GrayAt(20, 20).Y != 255 {
+		t.Error("the large region has been cleared from the mask")
+	}
+}
+
+func TestDetectMinRegionArea(t *testing.T) {
+	img, src, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.MinRegionArea = 400
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged {
+		t.Fatalf("the large copied region has been filtered out")
+	}
+	labels, areas := connectedComponents(res.Mask)
+	for i, label := range labels {
+		if label >= 0 && areas[label] < cfg.MinRegionArea {
+			t.Fatalf("the mask holds a region of %d pixels at %d", areas[label], i)
+		}
+	}
+	for _, r := range res.Regions {
+		if !r.Overlaps(src.Inset(-cfg.BlockSize)) {
+			t.Errorf("the region %v outside of the copied region %v survived the filter", r, src)
+		}
+	}
+}
+
+func TestAreaPercentage(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 50)
+	regions := []image.Rectangle{
+		image.Rect(0, 0, 10, 10),
+		// Overlaps the first region by 5x5 pixels, which are counted once.
+		image.Rect(5, 5, 15, 15),
+		// Only the 5x5 pixels inside the image are counted.
+		image.Rect(95, 45, 110, 60),
+	}
+	if got, want := areaPercentage(bounds, regions), 100*float64(100+100-25+25)/5000; got != want {
+		t.Errorf("got the forged area %v%%, expected %v%%", got, want)
+	}
+	if got := areaPercentage(bounds, nil); got != 0 {
+		t.Errorf("got the forged area %v%% without regions, expected 0", got)
+	}
+}
+
+func TestDetectForgedArea(t *testing.T) {
+	img, src, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The forged area is measured on the union of the regions, like the mask.
+	var forged int
+	for _, v := range res.Mask.Pix {
+		if v != 0 {
+			forged++
+		}
+	}
+	if want := 100 * float64(forged) / float64(len(res.Mask.Pix)); math.Abs(res.ForgedArea-want) > 1e-9 {
+		t.Errorf("got the forged area %v%%, expected the %v%% of the mask", res.ForgedArea, want)
+	}
+	// The regions cover the copied region, widened by the footprint of its edge blocks.
+	if want := 100 * float64(src.Dx()*src.Dy()) / (256 * 256); res.ForgedArea < want || res.ForgedArea > want+1 {
+		t.Errorf("got the forged area %v%%, expected about the %v%% of the copied region", res.ForgedArea, want)
+	}
+}
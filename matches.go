@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"image"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Match is a matched block pair confirmed by the verification, in the original image space.
+// For the mirrored copies the offset along the mirroring direction is the sum of the block coordinates.
+type Match struct {
+	// XA and YA are the top left corner of the first block, XB and YB of the second one.
+	XA, YA, XB, YB int
+	// OffsetX and OffsetY are the shift vector between the blocks.
+	OffsetX, OffsetY float64
+}
+
+// collectMatches maps the matched block pairs of the analyzed image to the original image space,
+// which the analyzed image has been downscaled from by the scale and cropped from at the origin.
+func collectMatches(vect []vector, scale float64, origin image.Point) []Match {
+	matches := make([]Match, len(vect))
+	for i, v := range vect {
+		a := scalePoint(image.Pt(v.xa, v.ya), scale).Add(origin)
+		b := scalePoint(image.Pt(v.xb, v.yb), scale).Add(origin)
+		matches[i] = Match{XA: a.X, YA: a.Y, XB: b.X, YB: b.Y, OffsetX: v.offsetX * scale, OffsetY: v.offsetY * scale}
+		// The offsets of the mirrored copies are sums of the coordinates, so they are shifted by the origin twice.
+		switch v.flip {
+		case FlipHorizontal:
+			matches[i].OffsetX += float64(2 * origin.X)
+		case FlipVertical:
+			matches[i].OffsetY += float64(2 * origin.Y)
+		}
+	}
+	sortMatches(matches)
+	return matches
+}
+
+// scalePoint scales the point coordinates by the provided factor.
+func scalePoint(p image.Point, scale float64) image.Point {
+	return image.Pt(int(round(float64(p.X)*scale)), int(round(float64(p.Y)*scale)))
+}
+
+// sortMatches orders the matches by the positions of their blocks, so the list
+// doesn't depend on the order the blocks have been matched in.
+func sortMatches(matches []Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.YA != b.YA:
+			return a.YA < b.YA
+		case a.XA != b.XA:
+			return a.XA < b.XA
+		case a.YB != b.YB:
+			return a.YB < b.YB
+		}
+		return a.XB < b.XB
+	})
+}
+
+// writeMatchesCSV writes the matches as a CSV correspondence list, one xa,ya,xb,yb,offsetX,offsetY row
+// per matched block pair, after a header row.
+func writeMatchesCSV(w io.Writer, matches []Match) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"xa", "ya", "xb", "yb", "offsetX", "offsetY"})
+	for _, m := range matches {
+		cw.Write([]string{
+			strconv.Itoa(m.XA), strconv.Itoa(m.YA), strconv.Itoa(m.XB), strconv.Itoa(m.YB),
+			strconv.FormatFloat(m.OffsetX, 'g', -1, 64), strconv.FormatFloat(m.OffsetY, 'g', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// saveMatches writes the matches as a CSV correspondence list to the file.
+func saveMatches(path string, matches []Match) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeMatchesCSV(out, matches); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
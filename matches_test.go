@@ -0,0 +1,88 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMatchesCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "forged.png")
+	if err := saveImage(path, img, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	// The stats count the shift vectors matched on each feature, and the matches report each block pair once.
+	cfg := DefaultConfig
+	cfg.CollectMatches, cfg.CollectStats = true, true
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matched int
+	for _, s := range res.Stats {
+		if s.Stage == statsMatch {
+			matched = s.Items
+		}
+	}
+	if matched == 0 || len(res.Matches) == 0 || len(res.Matches) > matched {
+		t.Fatalf("expected at most the %d matched pairs, got %d matches", matched, len(res.Matches))
+	}
+	seen := make(map[Match]bool, len(res.Matches))
+	for _, m := range res.Matches {
+		if seen[m] {
+			t.Fatalf("the pair %+v is reported several times", m)
+		}
+		seen[m] = true
+	}
+
+	csvPath := filepath.Join(dir, "matches.csv")
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "FORENSIC_MAIN=-in "+path+" -matches "+csvPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the tool failed: %v\n%s", err, out)
+	}
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(res.Matches)+1 {
+		t.Fatalf("expected a header and %d rows, got %d rows", len(res.Matches), len(rows))
+	}
+	for i, row := range rows[1:] {
+		var v [6]float64
+		for j, s := range row {
+			if v[j], err = strconv.ParseFloat(s, 64); err != nil {
+				t.Fatalf("row %d: %v", i, err)
+			}
+		}
+		if v[2]-v[0] != v[4] || v[3]-v[1] != v[5] {
+			t.Errorf("row %d: the offset %v,%v is not the displacement between the blocks %v,%v and %v,%v", i, v[4], v[5], v[0], v[1], v[2], v[3])
+		}
+		m := res.Matches[i]
+		if int(v[0]) != m.XA || int(v[1]) != m.YA || int(v[2]) != m.XB || int(v[3]) != m.YB {
+			t.Errorf("row %d: got the pair %v, expected %+v", i, row, m)
+		}
+	}
+}
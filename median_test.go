@@ -0,0 +1,122 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMedianFilterRemovesImpulses(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = 100
+	}
+	img.Pix[img.PixOffset(5, 5)] = 255
+	img.Pix[img.PixOffset(10, 3)] = 0
+
+	filtered := medianFilter(img, 3)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if v := filtered.Pix[filtered.PixOffset(x, y)]; v != 100 {
+				t.Fatalf("the luminance at %d,%d is %d, expected 100", x, y, v)
+			}
+		}
+	}
+	if filtered.Pix[1] != img.Pix[1] {
+		t.Error("the chroma should not be filtered")
+	}
+}
+
+func TestMedianWindowValidation(t *testing.T) {
+	for window, valid := range map[int]bool{0: true, 1: true, 2: false, 3: true, 4: false, 5: true} {
+		cfg := DefaultConfig
+		cfg.MedianWindow = window
+		if err := cfg.validate(); (err == nil) != valid {
+			t.Errorf("the median filter window %d: got the error %v", window, err)
+		}
+	}
+}
+
+// copyFeatureDistance returns the mean distance between the luminance DCT coefficients of the blocks
+// of the copied region and the coefficients of their source blocks, after the YUV conversion of the image
+// and the median filter of the provided window (0 disables it).
+func copyFeatureDistance(img *image.NRGBA, src image.Rectangle, shift image.Point, window int) float64 {
+	const blockSize = 4
+	yuv := image.NewRGBA(img.Bounds())
+	draw.Draw(yuv, yuv.Bounds(), convertRGBImageToYUV(img), image.Point{}, draw.Src)
+	if window > 1 {
+		yuv = medianFilter(yuv, window)
+	}
+	coefs := func(x0, y0 int) []float64 {
+		c := make([]float64, 0, blockSize*blockSize)
+		for u := 0; u < blockSize; u++ {
+			for v := 0; v < blockSize; v++ {
+				var sum float64
+				for x := 0; x < blockSize; x++ {
+					for y := 0; y < blockSize; y++ {
+						sum += dct(float64(x), float64(y), float64(u), float64(v), blockSize) * float64(yuv.Pix[yuv.PixOffset(x0+x, y0+y)])
+					}
+				}
+				c = append(c, sum/blockSize)
+			}
+		}
+		return c
+	}
+	var sum float64
+	var n int
+	for y := src.Min.Y; y <= src.Max.Y-blockSize; y += blockSize {
+		for x := src.Min.X; x <= src.Max.X-blockSize; x += blockSize {
+			a, b := coefs(x, y), coefs(x+shift.X, y+shift.Y)
+			for i := range a {
+				sum += math.Abs(a[i] - b[i])
+			}
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
+// impulseNoiseImage returns a smoothly textured image with a square region copied within it, sprinkled with
+// salt and pepper noise over the luminance of the whole image, so the copy and its source get different impulses.
+func impulseNoiseImage(size int, seed int64) (img *image.NRGBA, src, dst image.Rectangle) {
+	rnd := rand.New(rand.NewSource(seed))
+	img = image.NewNRGBA(image.Rect(0, 0, size, size))
+	fx, fy := 0.05+0.1*rnd.Float64(), 0.05+0.1*rnd.Float64()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := 128 + 60*math.Sin(fx*float64(x))*math.Cos(fy*float64(y)) + 40*math.Sin(0.3*float64(x+2*y))
+			img.SetNRGBA(x, y, color.NRGBA{clamp255(v), clamp255(v + 20), clamp255(v - 20), 255})
+		}
+	}
+	side := size / 4
+	src = image.Rect(size/8, size/8, size/8+side, size/8+side)
+	dst = src.Add(image.Pt(size/2, size/2))
+	draw.Draw(img, dst, img, src.Min, draw.Src)
+
+	for i := 0; i < size*size/25; i++ {
+		x, y := rnd.Intn(size), rnd.Intn(size)
+		c := img.NRGBAAt(x, y)
+		delta := 80
+		if rnd.Intn(2) == 1 {
+			delta = -80
+		}
+		c.R, c.G, c.B = clamp255(float64(int(c.R)+delta)), clamp255(float64(int(c.G)+delta)), clamp255(float64(int(c.B)+delta))
+		img.SetNRGBA(x, y, c)
+	}
+	return img, src, dst
+}
+
+func TestMedianFilterImpulseNoise(t *testing.T) {
+	img, src, dst := impulseNoiseImage(256, 7)
+	shift := dst.Min.Sub(src.Min)
+
+	without := copyFeatureDistance(img, src, shift, 0)
+	with := copyFeatureDistance(img, src, shift, 3)
+	t.Logf("mean feature distance of the copied blocks without the median filter: %.3f, with it: %.3f", without, with)
+	if with >= without {
+		t.Errorf("the median filter should bring the features of the noisy copy closer to its source: %.3f without, %.3f with", without, with)
+	}
+}
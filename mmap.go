@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"os"
+)
+
+// errMmapUnsupported is returned by mapFile on the platforms without memory mapping.
+var errMmapUnsupported = errors.New("memory mapping not supported")
+
+// loadMappedImage decodes the image file found under the provided path from its memory mapped contents,
+// so the encoded bytes are read directly from the page cache instead of being copied through read buffers,
+// which pays off for the very large evidence files. It falls back to reading the file where the memory mapping is not available, and to
+// loadImage for the HTTP(S) URLs. It returns ErrUnsupportedFormat when the image format is not recognized.
+func loadMappedImage(path string) (image.Image, error) {
+	if isURL(path) {
+		return loadImage(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, unmap, err := mapFile(file)
+	if err == errMmapUnsupported {
+		return loadImage(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The decoded pixels are copied out of the mapping, so it can be released right after the decoding.
+	defer unmap()
+
+	return decodeImage(bytes.NewReader(data))
+}
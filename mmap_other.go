@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// mapFile reports that the memory mapping is not available on this platform.
+func mapFile(file *os.File) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMappedImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, _, _, err := SyntheticImage(96, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"fixture.png", "fixture.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := saveImage(path, img, "", 90); err != nil {
+			t.Fatal(err)
+		}
+		read, err := loadImage(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapped, err := loadMappedImage(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !samePixels(read, mapped) {
+			t.Errorf("%s: the memory mapped file is decoded differently", name)
+		}
+	}
+
+	// The mapping holds the file contents, where it is supported.
+	path := filepath.Join(dir, "fixture.png")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data, unmap, err := mapFile(file)
+	if err == errMmapUnsupported {
+		t.Skip("the memory mapping is not supported on this platform")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Error("the mapping differs from the file contents")
+	}
+	if err := unmap(); err != nil {
+		t.Error(err)
+	}
+
+	empty := filepath.Join(dir, "empty.png")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMappedImage(empty); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat for the empty file, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// ncc computes the normalized cross-correlation between the R,G,B values of two
+// square patches of the provided size, having their top left corner at a and b. The patch b is mirrored by the flip.
+// The result is between -1 and 1, where 1 means the patches are identical up to brightness and contrast.
+func ncc(img *image.NRGBA, a, b image.Point, size int, flip Flip) float64 {
+	pa := patchValues(img, a, size)
+	pb := flipValues(patchValues(img, b, size), size, 3, flip)
+
+	var meanA, meanB float64
+	for i := range pa {
+		meanA += pa[i]
+		meanB += pb[i]
+	}
+	meanA /= float64(len(pa))
+	meanB /= float64(len(pb))
+
+	var num, varA, varB float64
+	for i := range pa {
+		da, db := pa[i]-meanA, pb[i]-meanB
+		num += da * db
+		varA += da * da
+		varB += db * db
+	}
+
+	// The correlation of the flat patches is undefined, consider them identical only if they have the same value.
+	if varA == 0 || varB == 0 {
+		if varA == varB && meanA == meanB {
+			return 1
+		}
+		return 0
+	}
+	return num / math.Sqrt(varA*varB)
+}
+
+// patchValues returns the R,G,B values of the square patch having its top left corner at p.
+func patchValues(img *image.NRGBA, p image.Point, size int) []float64 {
+	values := make([]float64, 0, size*size*3)
+	for y := p.Y; y < p.Y+size; y++ {
+		i := img.PixOffset(p.X, y)
+		for x := 0; x < size; x++ {
+			values = append(values, float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2]))
+			i += 4
+		}
+	}
+	return values
+}
+
+// verifyNCC keeps only the candidate matches whose pixel patches have a normalized
+// cross-correlation at least equal with the threshold. This rejects the pairs
+// which features coincidentally match, even though their content is different.
+func verifyNCC(img *image.NRGBA, vectors []vector, cfg Config) []vector {
+	var verified []vector
+	for _, v := range vectors {
+		if ncc(img, image.Pt(v.xa, v.ya), image.Pt(v.xb, v.yb), cfg.BlockSize, v.flip) >= cfg.NCCThreshold {
+			verified = append(verified, v)
+		}
+	}
+	return verified
+}
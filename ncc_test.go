@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// randomNRGBA returns an image of random pixels.
+func randomNRGBA(w, h int, seed int64) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rnd.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// copyPatch copies the square patch of the provided size from the top left corner src to dst,
+// transforming each R,G,B value through fn.
+func copyPatch(img *image.NRGBA, src, dst image.Point, size int, fn func(uint8) uint8) {
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			i, j := img.PixOffset(src.X+x, src.Y+y), img.PixOffset(dst.X+x, dst.Y+y)
+			for c := 0; c < 3; c++ {
+				img.Pix[j+c] = fn(img.Pix[i+c])
+			}
+		}
+	}
+}
+
+func TestVerifyNCC(t *testing.T) {
+	const size = 8
+	img := randomNRGBA(64, 32, 1)
+	a, copied, brighter, collision := image.Pt(2, 2), image.Pt(20, 2), image.Pt(38, 2), image.Pt(20, 20)
+
+	// The copy is exact, and the brighter copy differs by its brightness and contrast only.
+	copyPatch(img, a, copied, size, func(v uint8) uint8 { return v })
+	copyPatch(img, a, brighter, size, func(v uint8) uint8 { return uint8(20 + int(v)*3/4) })
+	// The coincidental collision holds the pixels of the block in a shuffled order, so both blocks have
+	// the same mean, hence the same DC coefficient, although their content is different.
+	pixels := patchValues(img, a, size)
+	rand.New(rand.NewSource(2)).Shuffle(size*size, func(i, j int) {
+		for c := 0; c < 3; c++ {
+			pixels[3*i+c], pixels[3*j+c] = pixels[3*j+c], pixels[3*i+c]
+		}
+	})
+	for k := 0; k < size*size; k++ {
+		i := img.PixOffset(collision.X+k%size, collision.Y+k/size)
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8(pixels[3*k+c])
+		}
+	}
+
+	if s := ncc(img, a, copied, size, NoFlip); s < 0.999 {
+		t.Errorf("the NCC of the exact copy is %.3f, expected 1", s)
+	}
+	if s := ncc(img, a, collision, size, NoFlip); s > 0.5 {
+		t.Errorf("the NCC of the coincidental collision is %.3f, expected a low correlation", s)
+	}
+
+	vectors := []vector{
+		{xa: a.X, ya: a.Y, xb: copied.X, yb: copied.Y},
+		{xa: a.X, ya: a.Y, xb: brighter.X, yb: brighter.Y},
+		{xa: a.X, ya: a.Y, xb: collision.X, yb: collision.Y},
+	}
+	cfg := DefaultConfig
+	cfg.BlockSize, cfg.NCCThreshold = size, 0.9
+	verified := verifyNCC(img, vectors, cfg)
+	if len(verified) != 2 || verified[0] != vectors[0] || verified[1] != vectors[1] {
+		t.Errorf("expected the copies to pass and the collision to be rejected, got %+v", verified)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+// Option modifies the settings of a detector created with NewDetector.
+type Option func(*Config)
+
+// NewDetector returns a detector with the default settings modified by the options.
+// It returns ErrInvalidConfig when the resulting settings are not valid.
+func NewDetector(opts ...Option) (*Detector, error) {
+	cfg := DefaultConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &Detector{Config: cfg}, nil
+}
+
+// WithConfig replaces all the settings.
+func WithConfig(cfg Config) Option {
+	return func(c *Config) { *c = cfg }
+}
+
+// WithBlockSize sets the size of the compared blocks.
+func WithBlockSize(size int) Option {
+	return func(c *Config) { c.BlockSize = size }
+}
+
+// WithBlurRadius sets the radius of the blur applied before the analysis.
+func WithBlurRadius(radius int) Option {
+	return func(c *Config) { c.BlurRadius = radius }
+}
+
+// WithThresholds sets the minimum number of the matches displaced by the same shift,
+// the maximum difference between the features of two matched blocks and the minimum
+// distance between the forged regions.
+func WithThresholds(offset int, distance, forgery float64) Option {
+	return func(c *Config) {
+		c.OffsetThreshold = offset
+		c.DistanceThreshold = distance
+		c.ForgeryThreshold = forgery
+	}
+}
+
+// WithFeatures selects the block descriptors used for matching.
+func WithFeatures(features FeatureSet) Option {
+	return func(c *Config) { c.Features = features }
+}
+
+// WithMaxImageSize sets the maximum width or height the images are downscaled to (0 disables the downscaling).
+func WithMaxImageSize(size int) Option {
+	return func(c *Config) { c.MaxImageSize = size }
+}
+
+// WithWorkers sets the number of goroutines extracting and comparing the block features concurrently.
+func WithWorkers(n int) Option {
+	return func(c *Config) { c.Workers = n }
+}
+
+// WithFeatureCache reuses the block features cached on disk for the images analyzed again.
+func WithFeatureCache(cache *FeatureCache) Option {
+	return func(c *Config) { c.FeatureCache = cache }
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewDetectorOptions(t *testing.T) {
+	d, err := NewDetector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Config, DefaultConfig) {
+		t.Errorf("the detector without options has the settings %+v, expected the default settings", d.Config)
+	}
+
+	d, err = NewDetector(
+		WithBlockSize(8),
+		WithBlurRadius(2),
+		WithThresholds(20, 0.5, 40),
+		WithFeatures(FeatureDCT|FeatureSobel),
+		WithMaxImageSize(512),
+		WithWorkers(4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultConfig
+	want.BlockSize = 8
+	want.BlurRadius = 2
+	want.OffsetThreshold, want.DistanceThreshold, want.ForgeryThreshold = 20, 0.5, 40
+	want.Features = FeatureDCT | FeatureSobel
+	want.MaxImageSize = 512
+	want.Workers = 4
+	if !reflect.DeepEqual(d.Config, want) {
+		t.Errorf("got the settings %+v, expected %+v", d.Config, want)
+	}
+
+	// The options are applied in order, so WithConfig replaces the previous ones.
+	cfg := DefaultConfig
+	cfg.Step = 2
+	if d, err = NewDetector(WithBlockSize(8), WithConfig(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Config, cfg) {
+		t.Errorf("got the settings %+v, expected the settings provided by WithConfig", d.Config)
+	}
+}
+
+func TestNewDetectorInvalidOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"block size too large": WithBlockSize(500),
+		"block size too small": WithBlockSize(1),
+		"negative blur radius": WithBlurRadius(-1),
+		"negative workers":     WithWorkers(-1),
+		"negative max size":    WithMaxImageSize(-1),
+		"negative offset":      WithThresholds(-1, 0.4, 50),
+	} {
+		d, err := NewDetector(opt)
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: got the error %v, expected %v", name, err, ErrInvalidConfig)
+		}
+		if d != nil {
+			t.Errorf("%s: got a detector with invalid settings", name)
+		}
+	}
+}
+
+func TestNewDetectorWorkers(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The number of the workers doesn't change the result.
+	var results [2]*Result
+	for i, n := range []int{1, 4} {
+		d, err := NewDetector(WithWorkers(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[i], err = d.Detect(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Error("the detection with 4 workers differs from the detection with a single worker")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// pcaReduce projects the per-block feature vectors onto their top k principal components.
+// The features of each block are expected to be stored contiguously, dims values per block.
+func pcaReduce(features []feature, dims, k int) []feature {
+	blocks := len(features) / dims
+	if blocks == 0 || k >= dims {
+		return features
+	}
+
+	// Center the feature vectors.
+	mean := make([]float64, dims)
+	for i := 0; i < blocks; i++ {
+		for j := 0; j < dims; j++ {
+			mean[j] += features[i*dims+j].coef
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(blocks)
+	}
+
+	// Compute the covariance matrix.
+	cov := make([][]float64, dims)
+	for j := range cov {
+		cov[j] = make([]float64, dims)
+	}
+	for i := 0; i < blocks; i++ {
+		row := features[i*dims : (i+1)*dims]
+		for a := 0; a < dims; a++ {
+			da := row[a].coef - mean[a]
+			for b := a; b < dims; b++ {
+				cov[a][b] += da * (row[b].coef - mean[b])
+			}
+		}
+	}
+	for a := 0; a < dims; a++ {
+		for b := a; b < dims; b++ {
+			cov[a][b] /= float64(blocks)
+			cov[b][a] = cov[a][b]
+		}
+	}
+
+	components := principalComponents(cov, k)
+
+	reduced := make([]feature, 0, blocks*k)
+	for i := 0; i < blocks; i++ {
+		row := features[i*dims : (i+1)*dims]
+		for _, c := range components {
+			var coef float64
+			for j := 0; j < dims; j++ {
+				coef += (row[j].coef - mean[j]) * c[j]
+			}
+			reduced = append(reduced, feature{x: row[0].x, y: row[0].y, coef: coef, variance: row[0].variance, flip: row[0].flip})
+		}
+	}
+	return reduced
+}
+
+// principalComponents returns the eigenvectors of the symmetric covariance
+// matrix corresponding to its k largest eigenvalues.
+func principalComponents(cov [][]float64, k int) [][]float64 {
+	values, vectors := eigenSymmetric(cov)
+
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return values[idx[a]] > values[idx[b]]
+	})
+
+	components := make([][]float64, k)
+	for c := 0; c < k; c++ {
+		components[c] = make([]float64, len(cov))
+		for j := range cov {
+			components[c][j] = vectors[j][idx[c]]
+		}
+	}
+	return components
+}
+
+// eigenSymmetric computes the eigenvalues and eigenvectors of a symmetric matrix using the Jacobi
+// eigenvalue algorithm. The eigenvectors are stored in the columns of the returned matrix.
+func eigenSymmetric(m [][]float64) ([]float64, [][]float64) {
+	n := len(m)
+	a := make([][]float64, n)
+	v := make([][]float64, n)
+	for i := range m {
+		a[i] = make([]float64, n)
+		copy(a[i], m[i])
+		v[i] = make([]float64, n)
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+		var off float64
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-18 {
+			break
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// Compute the rotation which zeroes the a[p][q] element.
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = a[i][i]
+	}
+	return values, v
+}
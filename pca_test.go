@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEigenSymmetric(t *testing.T) {
+	m := [][]float64{{4, 1, 2}, {1, 3, 0}, {2, 0, 5}}
+	values, vectors := eigenSymmetric(m)
+	for c := range values {
+		for r := range m {
+			var mv float64
+			for k := range m {
+				mv += m[r][k] * vectors[k][c]
+			}
+			if math.Abs(mv-values[c]*vectors[r][c]) > 1e-9 {
+				t.Fatalf("the column %d is not an eigenvector of the eigenvalue %v", c, values[c])
+			}
+		}
+	}
+}
+
+func TestPCAReconstructionError(t *testing.T) {
+	const dims, blocks = 6, 500
+	// The features mix three latent values of decreasing scale, with a little noise on every dimension.
+	rnd := rand.New(rand.NewSource(1))
+	mixing := [dims][3]float64{{1, 0, 0}, {2, 1, 0}, {0, 1, 1}, {1, -1, 0}, {0, 0, 1}, {1, 1, 1}}
+	feats := make([]feature, 0, blocks*dims)
+	for i := 0; i < blocks; i++ {
+		latent := [3]float64{10 * rnd.NormFloat64(), 4 * rnd.NormFloat64(), rnd.NormFloat64()}
+		for _, m := range mixing {
+			coef := 0.1 * rnd.NormFloat64()
+			for j, l := range latent {
+				coef += m[j] * l
+			}
+			feats = append(feats, feature{x: i, y: i, coef: coef})
+		}
+	}
+
+	var mean [dims]float64
+	for i, f := range feats {
+		mean[i%dims] += f.coef / blocks
+	}
+	var total float64
+	for i, f := range feats {
+		total += (f.coef - mean[i%dims]) * (f.coef - mean[i%dims])
+	}
+
+	// The components are orthonormal, so the squared reconstruction error is the centered energy
+	// of the features less the energy of their projections.
+	residuals := make([]float64, dims)
+	prev := math.Inf(1)
+	for k := 1; k < dims; k++ {
+		reduced := pcaReduce(feats, dims, k)
+		if len(reduced) != blocks*k {
+			t.Fatalf("k %d: %d reduced features, expected %d", k, len(reduced), blocks*k)
+		}
+		if reduced[k].x != 1 || reduced[k].y != 1 {
+			t.Fatalf("k %d: the reduced features lost the position of their block", k)
+		}
+		projected := 0.0
+		for _, f := range reduced {
+			projected += f.coef * f.coef
+		}
+		residual := (total - projected) / total
+		if residual >= prev || residual < -1e-9 {
+			t.Errorf("k %d: the relative reconstruction error %.6f doesn't decrease from %.6f", k, residual, prev)
+		}
+		residuals[k], prev = residual, residual
+	}
+	// The three latent values explain all but the noise.
+	if residuals[3] > 1e-3 {
+		t.Errorf("the relative reconstruction error of the 3 components is %.6f", residuals[3])
+	}
+	if got := pcaReduce(feats, dims, dims); len(got) != len(feats) {
+		t.Errorf("the features should be kept when k is the number of the dimensions")
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"path/filepath"
+	"sort"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// pHashSize is the width and height of the downscaled image the perceptual hash is computed on.
+	pHashSize = 32
+	// pHashDctSize is the size of the low frequency DCT region used for the hash bits.
+	pHashDctSize = 8
+	// signatureSize is the width and height of the luminance thumbnail the coarse DCT signature is computed on.
+	signatureSize = 16
+	// signatureDctSize is the size of the low frequency DCT region of the coarse DCT signature.
+	signatureDctSize = 4
+	// signatureTolerance is the maximum distance between the DCT signatures of two duplicate images,
+	// relative to the magnitude of the larger signature.
+	signatureTolerance = 0.1
+)
+
+// imageHash contains the image file path, its perceptual hash and its coarse DCT signature.
+type imageHash struct {
+	path      string
+	hash      uint64
+	signature []float64
+}
+
+// duplicate contains a pair of near identical images and the Hamming distance between their hashes.
+type duplicate struct {
+	a, b string
+	dist int
+}
+
+// PHash computes the perceptual hash of an image. Visually similar images
+// produce hashes with a small Hamming distance between them.
+func PHash(img image.Image) (uint64, error) {
+	if img == nil || img.Bounds().Empty() {
+		return 0, errors.New("cannot compute the perceptual hash of an empty image")
+	}
+	small := resize.Resize(pHashSize, pHashSize, img, resize.Bilinear)
+
+	// Obtain the grayscale pixel values.
+	var px [pHashSize][pHashSize]float64
+	bounds := small.Bounds()
+	for x := 0; x < pHashSize; x++ {
+		for y := 0; y < pHashSize; y++ {
+			r, g, b, _ := small.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			px[x][y] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+
+	// Compute the low frequency DCT coefficients.
+	coefs := make([]float64, 0, pHashDctSize*pHashDctSize)
+	for u := 0; u < pHashDctSize; u++ {
+		for v := 0; v < pHashDctSize; v++ {
+			var sum float64
+			for x := 0; x < pHashSize; x++ {
+				for y := 0; y < pHashSize; y++ {
+					sum += dct(float64(x), float64(y), float64(u), float64(v), pHashSize) * px[x][y]
+				}
+			}
+			coefs = append(coefs, sum)
+		}
+	}
+
+	// The DC coefficient is excluded from the median since it would skew the hash.
+	sorted := make([]float64, len(coefs)-1)
+	copy(sorted, coefs[1:])
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range coefs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+// dctSignature computes the coarse DCT signature of an image: the low frequency DCT coefficients of its
+// luminance thumbnail. Unlike the perceptual hash, which keeps only the signs of the coefficients relative
+// to their median, the signature keeps their magnitudes, including the mean brightness of the DC coefficient.
+func dctSignature(img image.Image) []float64 {
+	small := resize.Resize(signatureSize, signatureSize, img, resize.Bilinear)
+	bounds := small.Bounds()
+	px := make([]float64, signatureSize*signatureSize)
+	for y := 0; y < signatureSize; y++ {
+		for x := 0; x < signatureSize; x++ {
+			r, g, b, _ := small.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			px[y*signatureSize+x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+	coefs := blockDCT(px, signatureSize)
+
+	signature := make([]float64, 0, signatureDctSize*signatureDctSize)
+	for v := 0; v < signatureDctSize; v++ {
+		signature = append(signature, coefs[v*signatureSize:v*signatureSize+signatureDctSize]...)
+	}
+	return signature
+}
+
+// signatureDistance returns the Euclidean distance between two DCT signatures,
+// relative to the magnitude of the larger one.
+func signatureDistance(a, b []float64) float64 {
+	var d, na, nb float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if n := math.Max(na, nb); n > 0 {
+		return math.Sqrt(d / n)
+	}
+	return 0
+}
+
+// hammingDistance returns the number of different bits between two hashes.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// findDuplicates computes the perceptual hash and the coarse DCT signature of every image from the provided
+// directory and returns the image pairs having a Hamming distance lower or equal than maxDist between their hashes
+// and similar signatures. The signature rejects the pairs whose hashes match only by coincidence.
+func findDuplicates(dir string, maxDist int) ([]duplicate, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []imageHash
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		img, err := loadImage(path)
+		if err != nil {
+			// Skip the files which are not images.
+			continue
+		}
+		hash, err := PHash(img)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		hashes = append(hashes, imageHash{path, hash, dctSignature(img)})
+	}
+
+	var duplicates []duplicate
+	for i := 0; i < len(hashes); i++ {
+		for j := i + 1; j < len(hashes); j++ {
+			dist := hammingDistance(hashes[i].hash, hashes[j].hash)
+			if dist <= maxDist && signatureDistance(hashes[i].signature, hashes[j].signature) <= signatureTolerance {
+				duplicates = append(duplicates, duplicate{hashes[i].path, hashes[j].path, dist})
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// duplicateClusters groups the images of the duplicate pairs into clusters of near identical images,
+// where each image is a near duplicate of at least one other image of its cluster.
+// The clusters and their images are sorted by the image paths.
+func duplicateClusters(duplicates []duplicate) [][]string {
+	parent := make(map[string]string)
+	var find func(p string) string
+	find = func(p string) string {
+		if parent[p] != p {
+			parent[p] = find(parent[p])
+		}
+		return parent[p]
+	}
+	for _, d := range duplicates {
+		for _, p := range []string{d.a, d.b} {
+			if _, ok := parent[p]; !ok {
+				parent[p] = p
+			}
+		}
+		parent[find(d.a)] = find(d.b)
+	}
+
+	groups := make(map[string][]string)
+	for p := range parent {
+		root := find(p)
+		groups[root] = append(groups[root], p)
+	}
+	clusters := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		sort.Strings(g)
+		clusters = append(clusters, g)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPHashNearIdenticalImages(t *testing.T) {
+	img, _, _, err := SyntheticImage(200, 150, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A visually identical copy, slightly brightened and recompressed.
+	brighter := image.NewNRGBA(img.Bounds())
+	copy(brighter.Pix, img.Pix)
+	for i := range brighter.Pix {
+		if i%4 != 3 && brighter.Pix[i] < 250 {
+			brighter.Pix[i] += 4
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, brighter, &jpeg.Options{Quality: 85}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, _, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _, err := SyntheticImage(200, 150, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := PHash(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	similar, err := PHash(recompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	different, err := PHash(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := hammingDistance(hash, similar); d > 4 {
+		t.Errorf("the visually identical images are %d bits apart", d)
+	}
+	if d := hammingDistance(hash, different); d <= 10 {
+		t.Errorf("the different images are only %d bits apart", d)
+	}
+
+	if _, err := PHash(image.NewNRGBA(image.Rectangle{})); err == nil {
+		t.Error("the empty image should not be hashed")
+	}
+}
+
+func TestFindDuplicateClusters(t *testing.T) {
+	dir := t.TempDir()
+	img, _, _, err := SyntheticImage(128, 96, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brighter := cloneNRGBA(img)
+	for i := range brighter.Pix {
+		if i%4 != 3 && brighter.Pix[i] < 250 {
+			brighter.Pix[i] += 3
+		}
+	}
+	images := map[string]image.Image{"a.png": img, "b.png": brighter}
+	// The distinct images.
+	for seed := int64(3); seed <= 6; seed++ {
+		other, _, _, err := SyntheticImage(128, 96, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		images[fmt.Sprintf("other%d.png", seed)] = other
+	}
+	for name, img := range images {
+		if err := saveImage(filepath.Join(dir, name), img, "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	duplicates, err := findDuplicates(dir, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters := duplicateClusters(duplicates)
+	want := [][]string{{filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")}}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Errorf("got the duplicate clusters %v, expected %v", clusters, want)
+	}
+
+	// The chained duplicates form a single cluster.
+	chained := duplicateClusters([]duplicate{{a: "c", b: "d"}, {a: "a", b: "b"}, {a: "d", b: "e"}})
+	if want := [][]string{{"a", "b"}, {"c", "d", "e"}}; !reflect.DeepEqual(chained, want) {
+		t.Errorf("got the duplicate clusters %v, expected %v", chained, want)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"time"
+)
+
+// blockCost is the wall time a single worker spends on a block of the default settings, from the extraction of
+// its DCT features to the matching and the verification of its shift vectors. It was calibrated on random images
+// of up to a megapixel, which have no copy to be verified, so the estimate is a lower bound for the forged images.
+const blockCost = 14 * time.Microsecond
+
+// Plan is the work the detection would do on an image, computed from the image size and the settings
+// without extracting the block features.
+type Plan struct {
+	// Width and Height are the size of the analyzed image, after the cropping to the region of interest
+	// and the downscaling.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Levels is the number of the analyzed pyramid levels.
+	Levels int `json:"levels"`
+	// Tiles is the number of the tiles the image is processed in, summed over the pyramid levels.
+	Tiles int `json:"tiles"`
+	// Blocks is the number of the blocks the features are extracted from, once sampled to the maximum number
+	// of features, summed over the pyramid levels.
+	Blocks int `json:"blocks"`
+	// Features is the number of the features the blocks would produce. The transparent, excluded and flat blocks
+	// produce no features, so the detection can extract less of them.
+	Features int `json:"features"`
+	// Seconds is the estimated wall time of the detection, from the number of blocks and the number of workers.
+	Seconds float64 `json:"seconds"`
+}
+
+// PlanDetection returns the work the detection of the image would do with the settings, without running it.
+// The image is validated like by the detection, so the images the detection rejects have no plan.
+func PlanDetection(src image.Image, cfg Config) (*Plan, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, ErrImageTooSmall
+	}
+	if cfg.Exclude != nil {
+		if err := checkExcludeMask(cfg.Exclude, src); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.InputYUV {
+		if err := checkYUVInput(src); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{}
+	levels := cfg.PyramidLevels
+	if levels < 1 {
+		levels = 1
+	}
+	// The levels are sized like by the pyramid, which halves the image rounding up.
+	size := src.Bounds().Size()
+	for l := 0; l < levels; l++ {
+		lcfg := cfg
+		if l > 0 {
+			size = image.Pt((size.X+1)/2, (size.Y+1)/2)
+			if cfg.MaxImageSize > 0 {
+				lcfg.MaxImageSize = cfg.MaxImageSize >> uint(l)
+			}
+			if size.X < cfg.BlockSize || size.Y < cfg.BlockSize || (cfg.MaxImageSize > 0 && lcfg.MaxImageSize < cfg.BlockSize) {
+				break
+			}
+			if !cfg.ROI.Empty() {
+				unit := 1<<uint(l) - 1
+				lcfg.ROI = image.Rect(cfg.ROI.Min.X>>uint(l), cfg.ROI.Min.Y>>uint(l), (cfg.ROI.Max.X+unit)>>uint(l), (cfg.ROI.Max.Y+unit)>>uint(l))
+			}
+		}
+		analyzed, err := analyzedSize(size, lcfg)
+		if err != nil {
+			if l > 0 && errors.Is(err, ErrImageTooSmall) {
+				break
+			}
+			return nil, err
+		}
+		if l == 0 {
+			plan.Width, plan.Height = analyzed.X, analyzed.Y
+		}
+		tiles := splitTiles(image.Rectangle{Max: analyzed}, cfg.TileSize, cfg.tileOverlap(), cfg.BlockSize)
+		for _, tile := range tiles {
+			plan.Blocks += cfg.tileBlocks(tile)
+		}
+		plan.Tiles += len(tiles)
+		plan.Levels++
+	}
+	plan.Features = plan.Blocks * featuresPerBlock(cfg)
+	// The mirrored copies of the blocks triple the extracted and matched features.
+	estimate := time.Duration(plan.Blocks) * blockCost / time.Duration(cfg.workers())
+	if cfg.DetectFlips {
+		estimate *= 3
+	}
+	plan.Seconds = estimate.Seconds()
+	return plan, nil
+}
+
+// analyzedSize returns the size of the image of the provided size once cropped to the region of interest
+// and downscaled, or ErrImageTooSmall when it can't hold a block.
+func analyzedSize(size image.Point, cfg Config) (image.Point, error) {
+	if size.X < cfg.BlockSize || size.Y < cfg.BlockSize {
+		return image.Point{}, ErrImageTooSmall
+	}
+	if !cfg.ROI.Empty() {
+		if !cfg.ROI.In(image.Rectangle{Max: size}) {
+			return image.Point{}, invalidConfig("the region of interest %v lies outside of the image bounds", cfg.ROI)
+		}
+		size = cfg.ROI.Size()
+	}
+	// The missing dimension is rounded like by the resize package.
+	if maxDim := cfg.MaxImageSize; maxDim > 0 && (size.X > maxDim || size.Y > maxDim) {
+		if size.X >= size.Y {
+			size = image.Pt(maxDim, int(0.7+float64(size.Y)*float64(maxDim)/float64(size.X)))
+		} else {
+			size = image.Pt(int(0.7+float64(size.X)*float64(maxDim)/float64(size.Y)), maxDim)
+		}
+	}
+	if size.X < cfg.BlockSize || size.Y < cfg.BlockSize {
+		return image.Point{}, ErrImageTooSmall
+	}
+	return size, nil
+}
+
+// featuresPerBlock returns the number of the features extracted from each block with the settings,
+// including the features of the mirrored copies of the block.
+func featuresPerBlock(cfg Config) int {
+	b := image.NewRGBA(image.Rect(0, 0, cfg.BlockSize, cfg.BlockSize))
+	var src blockSources
+	if cfg.featureSet().has(FeatureSobel) {
+		src.grad = sobel(b)
+	}
+	// The blank block would be gated out by its texture energy.
+	cfg.MinTextureEnergy = 0
+	n := len(blockFeatures(src, b, 0, 0, cfg))
+	if cfg.DetectFlips {
+		n *= 3
+	}
+	return n
+}
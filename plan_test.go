@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestPlanDetection(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.MaxImageSize = 0
+	for _, size := range []image.Point{{64, 48}, {100, 37}, {cfg.BlockSize, cfg.BlockSize + 1}} {
+		plan, err := PlanDetection(image.NewNRGBA(image.Rectangle{Max: size}), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks := (size.X - cfg.BlockSize + 1) * (size.Y - cfg.BlockSize + 1)
+		if plan.Blocks != blocks {
+			t.Errorf("%v: expected %d blocks, got %d", size, blocks, plan.Blocks)
+		}
+		if plan.Width != size.X || plan.Height != size.Y || plan.Tiles != 1 || plan.Levels != 1 {
+			t.Errorf("%v: unexpected plan %+v", size, plan)
+		}
+		if plan.Features != blocks*featuresPerBlock(cfg) || plan.Seconds <= 0 {
+			t.Errorf("%v: unexpected features or estimate %+v", size, plan)
+		}
+	}
+
+	if _, err := PlanDetection(image.NewNRGBA(image.Rect(0, 0, cfg.BlockSize-1, 64)), cfg); !errors.Is(err, ErrImageTooSmall) {
+		t.Errorf("expected ErrImageTooSmall, got %v", err)
+	}
+}
+
+// TestPlanMatchesDetection checks the planned blocks against the blocks the detection extracts,
+// with the settings changing the analyzed image.
+func TestPlanMatchesDetection(t *testing.T) {
+	img := randomNRGBA(90, 70, 1)
+	for name, change := range map[string]func(*Config){
+		"downscale": func(c *Config) { c.MaxImageSize = 50 },
+		"roi":       func(c *Config) { c.ROI = image.Rect(10, 5, 60, 45) },
+		"step":      func(c *Config) { c.Step = 3 },
+		"tiles":     func(c *Config) { c.TileSize, c.TileOverlap = 40, 16 },
+		"flips":     func(c *Config) { c.DetectFlips = true },
+		"sampled":   func(c *Config) { c.MaxFeatures = 9000 },
+	} {
+		cfg := DefaultConfig
+		cfg.CollectStats = true
+		change(&cfg)
+		plan, err := PlanDetection(img, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		res, err := Detect(img, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, stage := range res.Stats {
+			if stage.Stage == statsBlocks && stage.Items != plan.Blocks {
+				t.Errorf("%s: planned %d blocks, the detection extracted %d", name, plan.Blocks, stage.Items)
+			}
+			if stage.Stage == statsFeatures && stage.Items != plan.Features {
+				t.Errorf("%s: planned %d features, the detection extracted %d", name, plan.Features, stage.Items)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"time"
+)
+
+// The JSON profiles of the detection result printed by -json.
+const (
+	// nativeProfile is the Result encoded as is.
+	nativeProfile = "native"
+	// flatProfile is the FlatRecord, for the case management and threat intelligence platforms.
+	flatProfile = "flat"
+)
+
+// flatSchema identifies the schema of the flat profile, so the consumers can detect its future revisions.
+const flatSchema = "forensic.copy-move.v1"
+
+// flatCoordinates describes the coordinate system of the regions of the flat profile.
+const flatCoordinates = "image_pixels_top_left"
+
+// FlatRecord is the detection result in the flat JSON profile, meant to be ingested by the forensic case
+// management and threat intelligence platforms, like MISP attributes or STIX observables. Every field has a fixed
+// type and is always present, the units are part of the field names and the coordinates are the pixels of the
+// original image, with the origin at its top left corner and the y axis pointing down.
+type FlatRecord struct {
+	Schema       string `json:"schema"`
+	Tool         string `json:"tool"`
+	ToolVersion  string `json:"tool_version"`
+	Source       string `json:"source"`
+	SourceSHA256 string `json:"source_sha256"`
+	// AnalyzedAt is the time of the detection in RFC 3339 format, in UTC.
+	AnalyzedAt string `json:"analyzed_at"`
+	// Verdict is forged, authentic or not_analyzed.
+	Verdict             string  `json:"verdict"`
+	Forged              bool    `json:"forged"`
+	PrecisionPercent    float64 `json:"precision_percent"`
+	CopyConfidenceRatio float64 `json:"copy_confidence_ratio"`
+	ForgedAreaPercent   float64 `json:"forged_area_percent"`
+	ImageWidthPx        int     `json:"image_width_px"`
+	ImageHeightPx       int     `json:"image_height_px"`
+	CoordinateSystem    string  `json:"coordinate_system"`
+	RegionCount         int     `json:"region_count"`
+	InstanceCount       int     `json:"instance_count"`
+	// JPEGQuality is the estimated quality factor of the JPEG image, or 0 when unknown.
+	JPEGQuality int          `json:"jpeg_quality"`
+	Regions     []FlatRegion `json:"regions"`
+}
+
+// FlatRegion is a forged region of the flat profile, with the position of its copy when known.
+type FlatRegion struct {
+	Index          int  `json:"index"`
+	XPx            int  `json:"x_px"`
+	YPx            int  `json:"y_px"`
+	WidthPx        int  `json:"width_px"`
+	HeightPx       int  `json:"height_px"`
+	HasDestination bool `json:"has_destination"`
+	DestinationXPx int  `json:"destination_x_px"`
+	DestinationYPx int  `json:"destination_y_px"`
+}
+
+// newFlatRecord returns the flat profile of the detection of the source image, analyzed at the provided time.
+func newFlatRecord(source string, src image.Image, res *Result, analyzedAt time.Time) FlatRecord {
+	verdict := "authentic"
+	switch {
+	case !res.Analyzed:
+		verdict = "not_analyzed"
+	case res.Forged:
+		verdict = "forged"
+	}
+	size := src.Bounds().Size()
+	r := FlatRecord{
+		Schema:              flatSchema,
+		Tool:                "forensic",
+		ToolVersion:         buildInfo().Version,
+		Source:              source,
+		SourceSHA256:        fileSHA256(source),
+		AnalyzedAt:          analyzedAt.UTC().Format(time.RFC3339),
+		Verdict:             verdict,
+		Forged:              res.Forged,
+		PrecisionPercent:    res.Precision,
+		CopyConfidenceRatio: res.CopyConfidence,
+		ForgedAreaPercent:   res.ForgedArea,
+		ImageWidthPx:        size.X,
+		ImageHeightPx:       size.Y,
+		CoordinateSystem:    flatCoordinates,
+		RegionCount:         len(res.Regions),
+		InstanceCount:       len(res.Instances),
+		JPEGQuality:         res.JPEGQuality,
+		Regions:             make([]FlatRegion, len(res.Regions)),
+	}
+	for i, region := range res.Regions {
+		r.Regions[i] = FlatRegion{Index: i, XPx: region.Min.X, YPx: region.Min.Y, WidthPx: region.Dx(), HeightPx: region.Dy()}
+		// The destinations are paired with the regions by their index.
+		if len(res.Destinations) == len(res.Regions) {
+			r.Regions[i].HasDestination = true
+			r.Regions[i].DestinationXPx, r.Regions[i].DestinationYPx = res.Destinations[i].Min.X, res.Destinations[i].Min.Y
+		}
+	}
+	return r
+}
+
+// fileSHA256 returns the hexadecimal SHA-256 digest of the file, or an empty string for the URLs and the files
+// which can't be read.
+func fileSHA256(path string) string {
+	if isURL(path) {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkJSONProfile checks the name of the JSON profile.
+func checkJSONProfile(profile string) error {
+	if profile != nativeProfile && profile != flatProfile {
+		return fmt.Errorf("unknown JSON profile %q", profile)
+	}
+	return nil
+}
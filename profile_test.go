@@ -0,0 +1,178 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The documented fields of the flat profile, with the JSON type of their values.
+var (
+	flatRecordSchema = map[string]string{
+		"schema":                "string",
+		"tool":                  "string",
+		"tool_version":          "string",
+		"source":                "string",
+		"source_sha256":         "string",
+		"analyzed_at":           "string",
+		"verdict":               "string",
+		"forged":                "boolean",
+		"precision_percent":     "number",
+		"copy_confidence_ratio": "number",
+		"forged_area_percent":   "number",
+		"image_width_px":        "number",
+		"image_height_px":       "number",
+		"coordinate_system":     "string",
+		"region_count":          "number",
+		"instance_count":        "number",
+		"jpeg_quality":          "number",
+		"regions":               "array",
+	}
+	flatRegionSchema = map[string]string{
+		"index":            "number",
+		"x_px":             "number",
+		"y_px":             "number",
+		"width_px":         "number",
+		"height_px":        "number",
+		"has_destination":  "boolean",
+		"destination_x_px": "number",
+		"destination_y_px": "number",
+	}
+)
+
+// checkSchema checks that the object has exactly the fields of the schema, with values of their types.
+func checkSchema(t *testing.T, name string, obj map[string]interface{}, schema map[string]string) {
+	t.Helper()
+	for field, kind := range schema {
+		value, ok := obj[field]
+		if !ok {
+			t.Errorf("%s: missing field %q", name, field)
+			continue
+		}
+		var got string
+		switch value.(type) {
+		case string:
+			got = "string"
+		case bool:
+			got = "boolean"
+		case float64:
+			got = "number"
+		case []interface{}:
+			got = "array"
+		default:
+			got = "null"
+		}
+		if got != kind {
+			t.Errorf("%s: the field %q is a %s, expected a %s", name, field, got, kind)
+		}
+	}
+	for field := range obj {
+		if _, ok := schema[field]; !ok {
+			t.Errorf("%s: undocumented field %q", name, field)
+		}
+	}
+}
+
+func TestFlatProfile(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "forged.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || len(res.Destinations) != len(res.Regions) {
+		t.Fatalf("expected the synthetic copy to be detected with its destinations, got %+v", res)
+	}
+
+	analyzedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	data, err := json.Marshal(newFlatRecord(path, img, res, analyzedAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, "record", record, flatRecordSchema)
+	regions, _ := record["regions"].([]interface{})
+	if len(regions) != len(res.Regions) || record["region_count"] != float64(len(res.Regions)) {
+		t.Fatalf("%d regions and a count of %v, expected %d", len(regions), record["region_count"], len(res.Regions))
+	}
+	for i, r := range regions {
+		region, ok := r.(map[string]interface{})
+		if !ok {
+			t.Fatalf("the region %d is not an object", i)
+		}
+		checkSchema(t, "region", region, flatRegionSchema)
+		want := res.Regions[i]
+		if region["index"] != float64(i) || region["x_px"] != float64(want.Min.X) || region["y_px"] != float64(want.Min.Y) ||
+			region["width_px"] != float64(want.Dx()) || region["height_px"] != float64(want.Dy()) ||
+			region["has_destination"] != true || region["destination_x_px"] != float64(res.Destinations[i].Min.X) {
+			t.Errorf("the region %d %v doesn't match the region %v copied to %v", i, region, want, res.Destinations[i])
+		}
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(contents)
+	for field, want := range map[string]interface{}{
+		"schema":            flatSchema,
+		"source":            path,
+		"source_sha256":     hex.EncodeToString(digest[:]),
+		"analyzed_at":       "2024-03-01T11:30:00Z",
+		"verdict":           "forged",
+		"image_width_px":    float64(128),
+		"coordinate_system": flatCoordinates,
+	} {
+		if record[field] != want {
+			t.Errorf("the field %q is %v, expected %v", field, record[field], want)
+		}
+	}
+}
+
+func TestFlatProfileVerdict(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for _, tc := range []struct {
+		res  Result
+		want string
+	}{
+		{Result{Analyzed: true, Forged: true}, "forged"},
+		{Result{Analyzed: true}, "authentic"},
+		{Result{}, "not_analyzed"},
+	} {
+		r := newFlatRecord("https://example.com/image.png", img, &tc.res, time.Now())
+		if r.Verdict != tc.want {
+			t.Errorf("verdict %q, expected %q", r.Verdict, tc.want)
+		}
+		// The remote images are not hashed, and the regions are an empty array rather than null.
+		if r.SourceSHA256 != "" || r.Regions == nil {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}
+	if err := checkJSONProfile("stix"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
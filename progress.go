@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"time"
+)
+
+// The stages of the detection reported by the progress events.
+const (
+	StagePreprocess = "preprocess"
+	StageExtract    = "extract"
+	StageVerify     = "verify"
+	StageAnalyze    = "analyze"
+	StageDone       = "done"
+)
+
+// progressEvents is the buffer size of the progress event channel.
+const progressEvents = 64
+
+// ProgressEvent reports the progress of the detection, e.g. for rendering a progress bar in a user interface.
+type ProgressEvent struct {
+	// Stage is the name of the current detection stage.
+	Stage string
+	// Fraction is the completed fraction of the whole detection, between 0 and 1.
+	Fraction float64
+	// Elapsed is the time elapsed since the detection started.
+	Elapsed time.Duration
+	// Result and Err are the outcome of the detection, set only on the final event of the done stage.
+	Result *Result
+	Err    error
+}
+
+// DetectProgress runs the detection in a new goroutine and returns the channel of the progress events.
+// The final event of the done stage carries the outcome of the detection, then the channel is closed.
+// The intermediate events are dropped when the consumer doesn't keep up, so a slow consumer never blocks
+// the detection, but the channel must be drained until it is closed to receive the final event.
+func (d *Detector) DetectProgress(ctx context.Context, src image.Image) <-chan ProgressEvent {
+	events := make(chan ProgressEvent, progressEvents)
+	d.events, d.started = events, time.Now()
+	go func() {
+		res, err := d.DetectContext(ctx, src)
+		d.events = nil
+		events <- ProgressEvent{Stage: StageDone, Fraction: 1, Elapsed: time.Since(d.started), Result: res, Err: err}
+		close(events)
+	}()
+	return events
+}
+
+// progress emits a progress event without blocking, when the detection has been started by DetectProgress.
+func (d *Detector) progress(stage string, fraction float64) {
+	if d.events == nil {
+		return
+	}
+	select {
+	case d.events <- ProgressEvent{Stage: stage, Fraction: fraction, Elapsed: time.Since(d.started)}:
+	default:
+	}
+}
+
+// The share of the whole detection taken by the feature extraction, which is by far the slowest stage.
+const (
+	extractStart = 0.05
+	extractEnd   = 0.85
+)
+
+// eventBar is a progress bar which emits the progress events of the feature extraction as well.
+// It is safe for concurrent use by the extraction workers.
+type eventBar struct {
+	progressBar
+	d     *Detector
+	done  int64
+	total int
+}
+
+// Increment increments the progress and emits an event for every percent of the processed blocks.
+func (b *eventBar) Increment() int {
+	b.progressBar.Increment()
+	done := int(atomic.AddInt64(&b.done, 1))
+	if step := b.total / 100; step == 0 || done%step == 0 {
+		b.d.progress(StageExtract, extractStart+(extractEnd-extractStart)*float64(done)/float64(b.total))
+	}
+	return done
+}
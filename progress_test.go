@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDetectProgressEvents(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDetector(WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []ProgressEvent
+	for ev := range d.DetectProgress(context.Background(), img) {
+		// The slow consumer doesn't block the detection, the intermediate events are dropped instead.
+		if len(events)%20 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		events = append(events, ev)
+	}
+	if len(events) < 2 {
+		t.Fatalf("got %d progress events, expected the intermediate events and the final event", len(events))
+	}
+	for _, ev := range events[:len(events)-1] {
+		if ev.Stage == StageDone || ev.Result != nil || ev.Err != nil {
+			t.Errorf("the intermediate event %+v carries the outcome of the detection", ev)
+		}
+		if ev.Fraction < 0 || ev.Fraction >= 1 {
+			t.Errorf("the intermediate event of the %s stage has the fraction %v", ev.Stage, ev.Fraction)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Stage != StageDone || last.Fraction != 1 || last.Err != nil || last.Result == nil {
+		t.Fatalf("the final event %+v doesn't report the completion", last)
+	}
+	if !last.Result.Forged {
+		t.Errorf("the final event reports an unforged result")
+	}
+	if last.Elapsed <= 0 {
+		t.Errorf("the final event reports the elapsed time %v", last.Elapsed)
+	}
+}
+
+func TestDetectProgressCanceled(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d := &Detector{Config: DefaultConfig}
+	var last ProgressEvent
+	for ev := range d.DetectProgress(ctx, img) {
+		last = ev
+	}
+	if last.Stage != StageDone || !errors.Is(last.Err, context.Canceled) || last.Result != nil {
+		t.Errorf("the final event %+v doesn't report the cancellation", last)
+	}
+}
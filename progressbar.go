@@ -0,0 +1,21 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"os"
+
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+// newProgressBar starts a new progress bar which is displayed only in verbose mode.
+func newProgressBar(total int, prefix string) progressBar {
+	bar := pb.New(total).Prefix(prefix)
+	if verbose {
+		bar.Output = os.Stderr
+	} else {
+		bar.NotPrint = true
+	}
+	return bar.Start()
+}
@@ -0,0 +1,20 @@
+package main
+
+// silentBar is a progress bar which doesn't display anything, since there is no terminal in the browser.
+type silentBar struct {
+	current int
+}
+
+// newProgressBar returns a progress bar which only counts the progress.
+func newProgressBar(total int, prefix string) progressBar {
+	return &silentBar{}
+}
+
+// Increment increments the progress counter.
+func (b *silentBar) Increment() int {
+	b.current++
+	return b.current
+}
+
+// Finish finishes the progress.
+func (b *silentBar) Finish() {}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// maxPyramidLevels is the largest supported number of pyramid levels. The deeper levels are too small to be analyzed.
+const maxPyramidLevels = 8
+
+// binomial5 is the 5-tap binomial kernel approximating the Gaussian blur of the pyramid levels. Its weights sum to 16.
+var binomial5 = [5]int{1, 4, 6, 4, 1}
+
+// pyramidDown returns the next level of the Gaussian pyramid: the image blurred by the binomial kernel,
+// which removes the frequencies the subsampling would alias, with every second row and column dropped.
+// The level has half the size of the image, rounded up, and the pixel x, y covers the pixels 2x, 2y of the image.
+func pyramidDown(img *image.NRGBA) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// The horizontal pass keeps only the even columns, the vertical pass only the even rows.
+	hw, hh := (w+1)/2, (h+1)/2
+	tmp := make([]int, hw*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < hw; x++ {
+			for k, wt := range binomial5 {
+				sx := clampInt(2*x+k-2, 0, w-1)
+				i := img.PixOffset(b.Min.X+sx, b.Min.Y+y)
+				for c := 0; c < 4; c++ {
+					tmp[(y*hw+x)*4+c] += wt * int(img.Pix[i+c])
+				}
+			}
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, hw, hh))
+	for y := 0; y < hh; y++ {
+		for x := 0; x < hw; x++ {
+			var sum [4]int
+			for k, wt := range binomial5 {
+				sy := clampInt(2*y+k-2, 0, h-1)
+				for c := 0; c < 4; c++ {
+					sum[c] += wt * tmp[(sy*hw+x)*4+c]
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + 128) / 256)
+			}
+		}
+	}
+	return dst
+}
+
+// pyramidMask shrinks the exclusion mask to the pyramid level of the provided size. A pixel of the level
+// is excluded when any pixel of the mask it covers is excluded, so the excluded regions are never shrunk.
+func pyramidMask(mask image.Image, size image.Point, level int) *image.Gray {
+	dst := image.NewGray(image.Rectangle{Max: size})
+	mb := mask.Bounds()
+	for y := 0; y < mb.Dy(); y++ {
+		for x := 0; x < mb.Dx(); x++ {
+			if color.GrayModel.Convert(mask.At(mb.Min.X+x, mb.Min.Y+y)).(color.Gray).Y >= 128 {
+				dst.SetGray(x>>uint(level), y>>uint(level), color.Gray{Y: 255})
+			}
+		}
+	}
+	return dst
+}
+
+// detectPyramid runs the detection at each level of the Gaussian pyramid of the image and fuses the detections
+// in the full resolution. The settings in pixels, like the block size and the shift limits, apply to each level
+// in its own pixels, so the coarse levels cover the large copies with fewer blocks and reach the copies displaced
+// beyond the maximum shift, while the small copies, which are too small for the coarse levels, are caught by
+// the fine levels. The levels too small for the analysis are skipped. The shift vectors are not accumulated in the detector.
+func (d *Detector) detectPyramid(ctx context.Context, src image.Image) (*Result, error) {
+	cfg := d.Config
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, ErrImageTooSmall
+	}
+	if cfg.Exclude != nil {
+		if err := checkExcludeMask(cfg.Exclude, src); err != nil {
+			return nil, err
+		}
+	}
+	// The planes of the YUV input are extracted before building the pyramid, which would convert them to RGB.
+	if cfg.InputYUV {
+		if err := checkYUVInput(src); err != nil {
+			return nil, err
+		}
+		src = yuvChannels(src)
+	}
+
+	bounds := image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+	var levels []*Result
+	img := src
+	for l := 0; l < cfg.PyramidLevels; l++ {
+		d.progress(StageExtract, float64(l)/float64(cfg.PyramidLevels))
+		lcfg := cfg
+		lcfg.PyramidLevels = 0
+		if l > 0 {
+			img = pyramidDown(imgToNRGBA(img))
+			size := img.Bounds().Size()
+			// The downscaled size of the level shrinks with the level, otherwise the levels of a large image
+			// would all be downscaled to the same size.
+			if cfg.MaxImageSize > 0 {
+				lcfg.MaxImageSize = cfg.MaxImageSize >> uint(l)
+			}
+			if size.X < cfg.BlockSize || size.Y < cfg.BlockSize || (cfg.MaxImageSize > 0 && lcfg.MaxImageSize < cfg.BlockSize) {
+				break
+			}
+			if !cfg.ROI.Empty() {
+				unit := 1<<uint(l) - 1
+				lcfg.ROI = image.Rect(cfg.ROI.Min.X>>uint(l), cfg.ROI.Min.Y>>uint(l), (cfg.ROI.Max.X+unit)>>uint(l), (cfg.ROI.Max.Y+unit)>>uint(l))
+			}
+			if cfg.Exclude != nil {
+				lcfg.Exclude = pyramidMask(cfg.Exclude, size, l)
+			}
+			lcfg.DetectGrid = false
+		}
+
+		ld := &Detector{Config: lcfg, features: d.features}
+		res, err := ld.DetectContext(ctx, img)
+		d.features = ld.features[:0]
+		if l > 0 && errors.Is(err, ErrImageTooSmall) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, scaleResult(res, 1<<uint(l), bounds))
+	}
+	return fuseLevels(levels, bounds), nil
+}
+
+// scaleResult maps the detection of a pyramid level to the full resolution, scaling its coordinates by the factor.
+func scaleResult(res *Result, factor int, bounds image.Rectangle) *Result {
+	if factor == 1 {
+		return res
+	}
+	f := float64(factor)
+	scaleRects := func(rects []image.Rectangle) []image.Rectangle {
+		for i, r := range rects {
+			rects[i] = scaleRect(r, f).Intersect(bounds)
+		}
+		return rects
+	}
+	res.Regions = scaleRects(res.Regions)
+	res.Destinations = scaleRects(res.Destinations)
+	for i := range res.Instances {
+		res.Instances[i].X *= f
+		res.Instances[i].Y *= f
+		res.Instances[i].Blocks = scaleRects(res.Instances[i].Blocks)
+	}
+	for i := range res.Arrows {
+		res.Arrows[i].From = res.Arrows[i].From.Mul(factor)
+		res.Arrows[i].To = res.Arrows[i].To.Mul(factor)
+	}
+	for i := range res.Shifts {
+		res.Shifts[i].X *= f
+		res.Shifts[i].Y *= f
+	}
+	for i := range res.Confidence {
+		res.Confidence[i].Block = scaleRect(res.Confidence[i].Block, f).Intersect(bounds)
+	}
+	for i, m := range res.Matches {
+		res.Matches[i] = Match{XA: m.XA * factor, YA: m.YA * factor, XB: m.XB * factor, YB: m.YB * factor, OffsetX: m.OffsetX * f, OffsetY: m.OffsetY * f}
+	}
+	res.AffineResidual *= f
+
+	mask := image.NewGray(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			mask.Pix[y*mask.Stride+x] = res.Mask.GrayAt(x/factor, y/factor).Y
+		}
+	}
+	res.Mask = mask
+	return res
+}
+
+// fuseLevels fuses the detections of the pyramid levels, mapped to the full resolution. The image is forged
+// when a level detects a forgery, and the regions, copies and masks of the forged levels are merged, while
+// the scores and the shifts are taken from the most precise forged level. When no level detects a forgery,
+// the detection of the finest level is reported, so the spurious matches of the other levels are not piled up.
+func fuseLevels(levels []*Result, bounds image.Rectangle) *Result {
+	var forged []*Result
+	for _, res := range levels {
+		if res.Forged {
+			forged = append(forged, res)
+		}
+	}
+	if len(forged) == 0 {
+		forged = levels[:1]
+	}
+	best := forged[0]
+	for _, res := range forged[1:] {
+		if res.Precision > best.Precision {
+			best = res
+		}
+	}
+	fused := *best
+	fused.InsufficientBlocks = levels[0].InsufficientBlocks
+	fused.Regions, fused.Destinations, fused.Instances, fused.Arrows, fused.Confidence, fused.Matches = nil, nil, nil, nil, nil, nil
+	fused.GridMisaligned = levels[0].GridMisaligned
+	fused.Mask = image.NewGray(bounds)
+	fused.Stats = nil
+	for _, res := range levels {
+		fused.Stats = addStats(fused.Stats, res.Stats)
+		fused.Analyzed = fused.Analyzed || res.Analyzed
+	}
+	for _, res := range forged {
+		fused.Regions = append(fused.Regions, res.Regions...)
+		fused.Destinations = append(fused.Destinations, res.Destinations...)
+		fused.Instances = append(fused.Instances, res.Instances...)
+		fused.Arrows = append(fused.Arrows, res.Arrows...)
+		fused.Confidence = append(fused.Confidence, res.Confidence...)
+		fused.Matches = append(fused.Matches, res.Matches...)
+		for i, v := range res.Mask.Pix {
+			if v > fused.Mask.Pix[i] {
+				fused.Mask.Pix[i] = v
+			}
+		}
+	}
+	sortRegionPairs(fused.Regions, fused.Destinations)
+	sort.SliceStable(fused.Instances, func(i, j int) bool { return len(fused.Instances[i].Blocks) > len(fused.Instances[j].Blocks) })
+	sort.SliceStable(fused.Confidence, func(i, j int) bool {
+		a, b := fused.Confidence[i].Block.Min, fused.Confidence[j].Block.Min
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+	sortMatches(fused.Matches)
+	fused.ForgedArea = areaPercentage(bounds, fused.Regions)
+	return &fused
+}
+
+// addStats accumulates the statistics of the pipeline stages of another run into the totals.
+func addStats(total, stats []StageStats) []StageStats {
+	if total == nil {
+		return append([]StageStats(nil), stats...)
+	}
+	for i := range stats {
+		total[i].Seconds += stats[i].Seconds
+		total[i].Items += stats[i].Items
+	}
+	return total
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPyramidDown(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 9, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 9; x++ {
+			// The finest vertical stripes are removed by the binomial kernel, leaving their mean.
+			v := uint8(100)
+			if x%2 == 1 {
+				v = 140
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	level := pyramidDown(img)
+	if got := level.Bounds(); got != image.Rect(0, 0, 5, 3) {
+		t.Fatalf("got the level of %v, expected half the size rounded up", got)
+	}
+	for y := 0; y < 3; y++ {
+		// The clamped edge columns repeat the border pixels, so only the inner columns are exactly the mean.
+		for x := 1; x < 4; x++ {
+			if c := level.NRGBAAt(x, y); c != (color.NRGBA{120, 120, 120, 255}) {
+				t.Errorf("the pixel %d,%d is %v, expected the mean of the stripes", x, y, c)
+			}
+		}
+	}
+}
+
+func TestPyramidDetection(t *testing.T) {
+	// The large copy is displaced beyond the maximum shift, the small copy lies close to its source.
+	img, large, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := image.Rect(100, 16, 128, 44)
+	for y := 0; y < small.Dy(); y++ {
+		for x := 0; x < small.Dx(); x++ {
+			img.SetNRGBA(140+x, 60+y, img.NRGBAAt(small.Min.X+x, small.Min.Y+y))
+		}
+	}
+	cfg := DefaultConfig
+	cfg.MaxShift = 100
+
+	detected := func(res *Result, r image.Rectangle) bool {
+		for _, region := range res.Regions {
+			if region.Overlaps(r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The fine level misses the far copy, while the coarse level, where its shift is halved, misses the small copy.
+	fine, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !detected(fine, small) || detected(fine, large) {
+		t.Errorf("expected the full resolution to detect only the small copy, got %v", fine.Regions)
+	}
+	coarse, err := Detect(pyramidDown(img), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := func(r image.Rectangle) image.Rectangle { return image.Rect(r.Min.X/2, r.Min.Y/2, r.Max.X/2, r.Max.Y/2) }
+	if !coarse.Forged || !detected(coarse, half(large)) || len(coarse.Shifts) == 0 || coarse.Shifts[0].X != 64 || coarse.Shifts[0].Y != 64 {
+		t.Errorf("expected the coarse level to detect the large copy, got %+v", coarse.Shifts)
+	}
+
+	cfg.PyramidLevels = 2
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || !detected(res, small) || !detected(res, large) {
+		t.Errorf("expected the pyramid to detect both copies, got %v", res.Regions)
+	}
+	if res.Mask.Bounds() != img.Bounds() {
+		t.Fatalf("the mask %v doesn't cover the image", res.Mask.Bounds())
+	}
+	center := func(r image.Rectangle) image.Point { return r.Min.Add(r.Max).Div(2) }
+	if res.Mask.GrayAt(center(large).X, center(large).Y).Y != 255 || res.Mask.GrayAt(center(small).X, center(small).Y).Y != 255 {
+		t.Error("expected the fused mask to cover both copies")
+	}
+	for _, r := range res.Regions {
+		if !r.In(img.Bounds()) {
+			t.Errorf("the region %v lies outside of the image", r)
+		}
+	}
+}
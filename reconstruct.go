@@ -0,0 +1,60 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// dctEpsilon is the maximum reconstruction error of a correct forward and inverse DCT pair,
+// allowing for the floating point rounding.
+const dctEpsilon = 1e-6
+
+// reconstructBlock reconstructs the samples of an n×n block from its orthonormal DCT coefficients,
+// stored as returned by blockDCT, by summing the inverse transform basis functions.
+func reconstructBlock(coefs []float64, n int) []float64 {
+	w := float64(n)
+	block := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sum float64
+			for v := 0; v < n; v++ {
+				for u := 0; u < n; u++ {
+					sum += coefs[v*n+u] * idct(float64(u), float64(v), float64(x), float64(y), w)
+				}
+			}
+			block[y*n+x] = sum
+		}
+	}
+	return block
+}
+
+// dctReconstructionError transforms the Y,R,G,B planes of the YUV image blocks with the DCT of the block features, reconstructs them
+// with the inverse transform and returns the maximum and the mean absolute difference from the original samples,
+// together with the number of verified blocks. For a correct forward and inverse pair both errors are close to zero.
+func dctReconstructionError(img *image.RGBA, blockSize, step int) (maxErr, meanErr float64, blocks int) {
+	b := img.Bounds()
+	var sum float64
+	var samples int
+	coefs, tmp := make([]float64, blockSize*blockSize), make([]float64, blockSize*blockSize)
+	for y := b.Min.Y; y <= b.Max.Y-blockSize; y += step {
+		for x := b.Min.X; x <= b.Max.X-blockSize; x += step {
+			sub := img.SubImage(image.Rect(x, y, x+blockSize, y+blockSize)).(*image.RGBA)
+			yPlane, rPlane, gPlane, bPlane, _, _, _ := blockPlanes(sub, blockSize)
+			for _, plane := range [][]float64{yPlane, rPlane, gPlane, bPlane} {
+				separableDCT(plane, coefs, tmp, blockSize)
+				rec := reconstructBlock(coefs, blockSize)
+				for i, v := range plane {
+					d := math.Abs(rec[i] - v)
+					maxErr = math.Max(maxErr, d)
+					sum += d
+					samples++
+				}
+			}
+			blocks++
+		}
+	}
+	if samples > 0 {
+		meanErr = sum / float64(samples)
+	}
+	return maxErr, meanErr, blocks
+}
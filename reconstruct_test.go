@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestReconstructBlockRoundTrip(t *testing.T) {
+	for _, n := range []int{2, 3, 4, 5, 8, 16} {
+		for seed := int64(1); seed <= 20; seed++ {
+			block := randomBlock(n, seed)
+			rec := reconstructBlock(blockDCT(block, n), n)
+			for i := range block {
+				if d := math.Abs(rec[i] - block[i]); d > dctEpsilon {
+					t.Fatalf("%dx%d block %d: the sample %d is reconstructed as %v instead of %v", n, n, seed, i, rec[i], block[i])
+				}
+			}
+		}
+	}
+}
+
+func TestDCTReconstructionError(t *testing.T) {
+	img, _, _, err := SyntheticImage(32, 32, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yuv := image.NewRGBA(img.Bounds())
+	draw.Draw(yuv, yuv.Bounds(), convertRGBImageToYUV(img), image.Point{}, draw.Src)
+	maxErr, meanErr, blocks := dctReconstructionError(yuv, 8, 4)
+	// The 32x32 image holds 7x7 blocks of 8 pixels spaced by 4 pixels.
+	if blocks != 7*7 {
+		t.Errorf("%d blocks have been verified, expected %d", blocks, 7*7)
+	}
+	if maxErr > dctEpsilon || meanErr > maxErr {
+		t.Errorf("got the reconstruction errors max %v and mean %v, expected them below %v", maxErr, meanErr, dctEpsilon)
+	}
+}
@@ -0,0 +1,198 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"time"
+)
+
+// reportTemplate is the self-contained HTML page of the detection report. The annotated image is embedded
+// as a data URL, so the page can be shared as a single file.
+const reportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Forensic report: {{.Source}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 1000px; color: #222; }
+h1 { font-size: 1.5em; }
+.verdict { font-size: 1.3em; font-weight: bold; padding: 0.5em; }
+.forged { background: #fdd; }
+.authentic { background: #dfd; }
+.unknown { background: #eee; }
+img { max-width: 100%; border: 1px solid #ccc; }
+img.thumb { min-width: 48px; image-rendering: pixelated; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th { background: #eee; }
+footer { color: #777; font-size: 0.9em; margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>Copy-move forgery detection: {{.Source}}</h1>
+<p class="verdict {{if .Result.Forged}}forged{{else if not .Result.Analyzed}}unknown{{else}}authentic{{end}}">{{.Verdict}}</p>
+<table>
+<tr><th>Precision</th><td>{{printf "%.1f" .Result.Precision}}%</td></tr>
+<tr><th>Copy confidence</th><td>{{printf "%.2f" .Result.CopyConfidence}} ({{.Result.AffineInliers}} affine inliers)</td></tr>
+<tr><th>Forged area</th><td>{{printf "%.2f" .Result.ForgedArea}}% of the image</td></tr>
+<tr><th>Forged regions</th><td>{{len .Result.Regions}}</td></tr>
+<tr><th>Copy-move instances</th><td>{{len .Result.Instances}}</td></tr>
+{{- if .Result.JPEGQuality}}
+<tr><th>Estimated JPEG quality</th><td>{{.Result.JPEGQuality}}</td></tr>
+{{- end}}
+</table>
+<img src="{{.Image}}" alt="Annotated image">
+{{- if .Regions}}
+<h2>Forged regions</h2>
+<table>
+<tr><th>#</th><th>X</th><th>Y</th><th>Width</th><th>Height</th><th>Source</th><th>Copied to</th><th>Destination</th></tr>
+{{- range $i, $r := .Regions}}
+<tr><td>{{inc $i}}</td><td>{{$r.Min.X}}</td><td>{{$r.Min.Y}}</td><td>{{$r.Dx}}</td><td>{{$r.Dy}}</td>
+<td><img class="thumb" src="{{$r.Source}}" alt="Region {{inc $i}}"></td>
+{{- if $r.Destination}}
+<td>{{$r.To.Min.X}}, {{$r.To.Min.Y}}</td><td><img class="thumb" src="{{$r.Destination}}" alt="Copy of the region {{inc $i}}"></td>
+{{- else}}
+<td></td><td></td>
+{{- end}}
+</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Result.Shifts}}
+<h2>Most frequent shifts</h2>
+<table>
+<tr><th>X</th><th>Y</th><th>Matches</th></tr>
+{{- range .Result.Shifts}}
+<tr><td>{{printf "%.0f" .X}}</td><td>{{printf "%.0f" .Y}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h2>Settings</h2>
+<table>
+<tr><th>Block size</th><td>{{.Config.BlockSize}}</td></tr>
+<tr><th>Block descriptors</th><td>{{.Features}}</td></tr>
+<tr><th>Offset threshold</th><td>{{if .Config.OffsetFraction}}{{.Config.OffsetFraction}} of the blocks{{else}}{{.Config.OffsetThreshold}}{{end}}</td></tr>
+<tr><th>Distance threshold</th><td>{{.Config.DistanceThreshold}}</td></tr>
+<tr><th>Forgery threshold</th><td>{{.Config.ForgeryThreshold}}</td></tr>
+<tr><th>Minimum forged blocks</th><td>{{.Config.MinForgedBlocks}}</td></tr>
+<tr><th>Maximum image size</th><td>{{.Config.MaxImageSize}}</td></tr>
+</table>
+<footer>Generated by {{.Build}} on {{.Generated}}.</footer>
+</body>
+</html>
+`
+
+// reportPage is the parsed report template.
+var reportPage = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(reportTemplate))
+
+// thumbnailSize is the maximum width and height of the region thumbnails of the report.
+const thumbnailSize = 96
+
+// reportRegion is a forged region of the report, with the thumbnails of its content and of its copy.
+type reportRegion struct {
+	image.Rectangle
+	To          image.Rectangle
+	Source      template.URL
+	Destination template.URL
+}
+
+// verdict returns the verdict of the detection, with the confidence in it.
+func verdict(res *Result) string {
+	if !res.Analyzed {
+		return "The image could not be analyzed!"
+	}
+	if res.Forged && res.Precision > 50.0 {
+		return fmt.Sprintf("%.0f%% the image is forged!", res.Precision)
+	}
+	return fmt.Sprintf("%.0f%% the image is NOT forged!", 100-res.Precision)
+}
+
+// writeReport writes the HTML report of the detection of the source image: the verdict, the scores, the annotated
+// image, the table of the forged regions with the thumbnails of their content and of their copies, and the settings
+// and the version the detection has been run with.
+func writeReport(w io.Writer, source string, src image.Image, res *Result, cfg Config) error {
+	annotated, err := dataURL(annotate(src, res, cfg))
+	if err != nil {
+		return err
+	}
+	regions := make([]reportRegion, len(res.Regions))
+	for i, r := range res.Regions {
+		regions[i].Rectangle = r
+		if regions[i].Source, err = thumbnail(src, r, cfg.Resampling); err != nil {
+			return err
+		}
+		// The destinations are paired with the regions by their index.
+		if len(res.Destinations) == len(res.Regions) {
+			regions[i].To = res.Destinations[i]
+			if regions[i].Destination, err = thumbnail(src, regions[i].To, cfg.Resampling); err != nil {
+				return err
+			}
+		}
+	}
+	return reportPage.Execute(w, struct {
+		Source    string
+		Verdict   string
+		Result    *Result
+		Image     template.URL
+		Regions   []reportRegion
+		Features  FeatureSet
+		Config    Config
+		Build     BuildInfo
+		Generated string
+	}{
+		Source:    source,
+		Verdict:   verdict(res),
+		Result:    res,
+		Image:     annotated,
+		Regions:   regions,
+		Features:  cfg.featureSet(),
+		Config:    cfg,
+		Build:     buildInfo(),
+		Generated: time.Now().Format(time.RFC1123),
+	})
+}
+
+// thumbnail returns the data URL of the content of the region of the image, downscaled to fit in
+// thumbnailSize pixels. The region is clipped to the image, and an empty region has no thumbnail.
+func thumbnail(img image.Image, r image.Rectangle, resampling Resampling) (template.URL, error) {
+	b := img.Bounds()
+	r = r.Add(b.Min).Intersect(b)
+	if r.Empty() {
+		return "", nil
+	}
+	thumb, _ := downscale(cropImage(img, r), thumbnailSize, resampling)
+	return dataURL(thumb)
+}
+
+// dataURL encodes the image as a PNG data URL, to embed it in the report.
+func dataURL(img image.Image) (template.URL, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// saveReport writes the HTML report of the detection to the file.
+func saveReport(path, source string, src image.Image, res *Result, cfg Config) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeReport(out, source, src, res, cfg); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,109 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || len(res.Regions) == 0 {
+		t.Fatalf("expected the synthetic copy to be detected, got %+v", res)
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "synthetic.png", img, res, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, s := range []string{
+		verdict(res),
+		fmt.Sprintf("<tr><th>Forged regions</th><td>%d</td></tr>", len(res.Regions)),
+		`<img src="data:image/png;base64,`,
+		"synthetic.png",
+		buildInfo().String(),
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("the report doesn't contain %q", s)
+		}
+	}
+	if n := strings.Count(page, "<tr><td>"); n < len(res.Regions) {
+		t.Errorf("expected a row per forged region, got %d rows for %d regions", n, len(res.Regions))
+	}
+}
+
+func TestReportThumbnails(t *testing.T) {
+	img, _, _, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A large region is downscaled to fit in the thumbnail size.
+	res.Regions = append(res.Regions, image.Rect(0, 0, 250, 120))
+	res.Destinations = append(res.Destinations, image.Rect(4, 100, 254, 220))
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "synthetic.png", img, res, DefaultConfig); err != nil {
+		t.Fatal(err)
+	}
+	thumbs := regexp.MustCompile(`<img class="thumb" src="data:image/png;base64,([^"]+)"`).FindAllStringSubmatch(buf.String(), -1)
+	if len(thumbs) != 2*len(res.Regions) {
+		t.Fatalf("expected a source and a destination thumbnail for each of the %d regions, got %d thumbnails", len(res.Regions), len(thumbs))
+	}
+	sizes := make([]image.Rectangle, len(thumbs))
+	for i, m := range thumbs {
+		data, err := base64.StdEncoding.DecodeString(html.UnescapeString(m[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		thumb, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := thumb.Bounds()
+		if b.Empty() || b.Dx() > thumbnailSize || b.Dy() > thumbnailSize {
+			t.Errorf("thumbnail %d: expected at most %dx%d pixels, got %dx%d", i, thumbnailSize, thumbnailSize, b.Dx(), b.Dy())
+		}
+		sizes[i] = b
+	}
+	// The thumbnail of the large region keeps its aspect ratio.
+	if b := sizes[len(sizes)-2]; b.Dx() != thumbnailSize || b.Dy() != 46 {
+		t.Errorf("expected a %dx46 thumbnail of the large region, got %dx%d", thumbnailSize, b.Dx(), b.Dy())
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	for _, tt := range []struct {
+		res  Result
+		want string
+	}{
+		{Result{Analyzed: true, Forged: true, Precision: 80}, "80% the image is forged!"},
+		{Result{Analyzed: true, Precision: 10}, "90% the image is NOT forged!"},
+		// The image without features is not reported as authentic.
+		{Result{}, "The image could not be analyzed!"},
+	} {
+		if got := verdict(&tt.res); got != tt.want {
+			t.Errorf("%+v: got the verdict %q, expected %q", tt.res, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+// Resampling is the interpolation the large images are downscaled with.
+type Resampling int
+
+const (
+	// Bilinear interpolates the pixels linearly, which smooths the image without blurring its details much.
+	Bilinear Resampling = iota
+	// NearestNeighbor keeps the nearest pixel, which is the fastest, but aliases the fine textures.
+	NearestNeighbor
+	// CatmullRom interpolates the pixels with the Catmull-Rom cubic spline, which is the smoothest and sharpest,
+	// but also the slowest.
+	CatmullRom
+)
+
+// resamplingNames maps the resampling names accepted on the command line to their values.
+var resamplingNames = map[string]Resampling{
+	"bilinear":   Bilinear,
+	"nearest":    NearestNeighbor,
+	"catmullrom": CatmullRom,
+}
+
+// parseResampling returns the resampling corresponding to its name.
+func parseResampling(name string) (Resampling, error) {
+	r, ok := resamplingNames[strings.ToLower(name)]
+	if !ok {
+		return 0, invalidConfig("unknown resampling %q", name)
+	}
+	return r, nil
+}
+
+// resize resizes the image to the width and height, where a zero dimension preserves the aspect ratio.
+// The filters of the resize package are widened by the reduction factor, which averages the pixels covered by each
+// resized pixel, even for its nearest neighbor filter, so the nearest pixels are sampled directly instead.
+// The bicubic filter of the resize package is the Catmull-Rom spline.
+func (r Resampling) resize(img image.Image, width, height uint) image.Image {
+	switch r {
+	case NearestNeighbor:
+		return nearestResize(img, width, height)
+	case CatmullRom:
+		return resize.Resize(width, height, img, resize.Bicubic)
+	default:
+		return resize.Resize(width, height, img, resize.Bilinear)
+	}
+}
+
+// nearestResize resizes the image to the width and height, where a zero dimension preserves the aspect ratio,
+// keeping the pixel of the image nearest to the center of each resized pixel. The 16-bit images keep their depth.
+func nearestResize(img image.Image, width, height uint) image.Image {
+	b := img.Bounds()
+	// The missing dimension is rounded like by the resize package.
+	if width == 0 {
+		width = uint(0.7 + float64(b.Dx())*float64(height)/float64(b.Dy()))
+	}
+	if height == 0 {
+		height = uint(0.7 + float64(b.Dy())*float64(width)/float64(b.Dx()))
+	}
+	w, h := int(width), int(height)
+	var dst draw.Image = image.NewNRGBA(image.Rect(0, 0, w, h))
+	if is16Bit(img) {
+		dst = image.NewNRGBA64(dst.Bounds())
+	}
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + (2*y+1)*b.Dy()/(2*h)
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, img.At(b.Min.X+(2*x+1)*b.Dx()/(2*w), sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"os"
+)
+
+// SaveResult writes the detection result to the file, so it can be archived and visualized later without
+// running the detection again. Unlike the JSON encoding, the file keeps the forgery mask and the block confidence.
+func SaveResult(r Result, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	if err := gob.NewEncoder(w).Encode(r); err != nil {
+		out.Close()
+		return fmt.Errorf("encoding the result: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// LoadResult reads the detection result saved by SaveResult.
+func LoadResult(path string) (Result, error) {
+	var r Result
+	in, err := os.Open(path)
+	if err != nil {
+		return r, err
+	}
+	defer in.Close()
+	if err := gob.NewDecoder(bufio.NewReader(in)).Decode(&r); err != nil {
+		return Result{}, fmt.Errorf("decoding the result: %w", err)
+	}
+	return r, nil
+}
+
+// checkResultImage checks that the loaded result has been detected on an image of the same size,
+// since its mask and regions are aligned with the image origin.
+func checkResultImage(r *Result, img image.Image) error {
+	if r.Mask != nil && r.Mask.Bounds().Size() != img.Bounds().Size() {
+		return fmt.Errorf("%w: the result is for a %v image, got %v", ErrSizeMismatch, r.Mask.Bounds().Size(), img.Bounds().Size())
+	}
+	return nil
+}
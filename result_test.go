@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveLoadResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.CollectStats = true
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || len(res.Confidence) == 0 {
+		t.Fatalf("expected a forged result with the block confidence, got %+v", res)
+	}
+
+	path := filepath.Join(dir, "result.gob")
+	if err := SaveResult(*res, path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadResult(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, *res) {
+		t.Errorf("the loaded result differs from the saved one:\n%+v\n%+v", loaded, *res)
+	}
+
+	if err := checkResultImage(&loaded, image.NewGray(image.Rect(0, 0, 64, 128))); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected the result of another image size to be rejected, got %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("not a result"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadResult(path); err == nil {
+		t.Error("expected the malformed result file to be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+// extractedFeatures extracts the features of the whole synthetic image with the settings.
+func extractedFeatures(t *testing.T, cfg Config) []feature {
+	img, _, _, err := SyntheticImage(96, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yuv := image.NewRGBA(img.Bounds())
+	draw.Draw(yuv, yuv.Bounds(), convertRGBImageToYUV(img), image.Point{}, draw.Src)
+	features, _, err := extractFeatures(context.Background(), nil, blockSources{yuv: yuv}, yuv.Bounds(), cfg, pb.New(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return features
+}
+
+func TestExtractFeaturesStep(t *testing.T) {
+	blocks := make(map[int]int)
+	for _, step := range []int{1, 2, 3, 4, 8} {
+		cfg := DefaultConfig
+		cfg.Step = step
+		positions := make(map[image.Point]bool)
+		for _, f := range extractedFeatures(t, cfg) {
+			if f.x%step != 0 || f.y%step != 0 {
+				t.Fatalf("step %d: the block %d,%d is off the sampling grid", step, f.x, f.y)
+			}
+			positions[image.Pt(f.x, f.y)] = true
+		}
+		// The 96x64 image holds (96-bs)/step+1 blocks per row and (64-bs)/step+1 per column.
+		bs := cfg.BlockSize
+		if want := ((96-bs)/step + 1) * ((64-bs)/step + 1); len(positions) != want {
+			t.Errorf("step %d: %d blocks, expected %d", step, len(positions), want)
+		}
+		if want := countBlocks(image.Rect(0, 0, 96, 64), bs, step); len(positions) != want {
+			t.Errorf("step %d: %d blocks, but %d counted", step, len(positions), want)
+		}
+		blocks[step] = len(positions)
+	}
+	// The block count decreases with the square of the step.
+	for _, step := range []int{2, 4, 8} {
+		ratio := float64(blocks[1]) / float64(blocks[step])
+		if want := float64(step * step); ratio < want*0.75 || ratio > want*1.25 {
+			t.Errorf("step %d: the block count decreased %.1f times, expected about %.0f", step, ratio, want)
+		}
+	}
+}
+
+func TestMaxFeatures(t *testing.T) {
+	cfg := DefaultConfig
+	all := len(extractedFeatures(t, cfg))
+	perBlock := featuresPerBlock(cfg)
+	for _, seed := range []int64{0, 1, 7} {
+		for _, limit := range []int{2 * perBlock, 1000, all / 3, all} {
+			cfg := DefaultConfig
+			cfg.MaxFeatures, cfg.SampleSeed = limit, seed
+			features := extractedFeatures(t, cfg)
+			if len(features) > limit {
+				t.Errorf("seed %d: %d features exceed the limit of %d", seed, len(features), limit)
+			}
+			if want := limit / perBlock * perBlock; len(features) != want {
+				t.Errorf("seed %d: %d features, expected %d", seed, len(features), want)
+			}
+
+			// The same seed samples the same blocks.
+			again := extractedFeatures(t, cfg)
+			if len(again) != len(features) {
+				t.Fatalf("seed %d: %d features, then %d", seed, len(features), len(again))
+			}
+			for i := range features {
+				if features[i].x != again[i].x || features[i].y != again[i].y {
+					t.Fatalf("seed %d: the block %d,%d was sampled, then %d,%d", seed, features[i].x, features[i].y, again[i].x, again[i].y)
+				}
+			}
+		}
+	}
+
+	// The different seeds sample different blocks.
+	positions := func(seed int64) map[image.Point]bool {
+		cfg := DefaultConfig
+		cfg.MaxFeatures, cfg.SampleSeed = all/3, seed
+		sampled := make(map[image.Point]bool)
+		for _, f := range extractedFeatures(t, cfg) {
+			sampled[image.Pt(f.x, f.y)] = true
+		}
+		return sampled
+	}
+	a, b := positions(1), positions(2)
+	var common int
+	for p := range a {
+		if b[p] {
+			common++
+		}
+	}
+	if common == len(a) {
+		t.Error("the seeds 1 and 2 sampled the same blocks")
+	}
+
+	cfg.MaxFeatures = perBlock
+	if err := cfg.validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a limit below two blocks, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+)
+
+// selfCheckTolerance is the maximum difference between a computed and an expected coefficient of the self-check.
+// The expected coefficients are rounded to 13 significant digits, far below the errors of a wrong transform.
+const selfCheckTolerance = 1e-8
+
+// dctTestVector is a standard 4×4 test pattern, with the orthonormal DCT coefficients precomputed independently
+// of the implementations, laid out like the coefficients of blockDCT.
+type dctTestVector struct {
+	name  string
+	block []float64
+	coefs []float64
+}
+
+// dctTestVectors are the test patterns of the self-check. The impulse excites every frequency, the constant
+// block only the DC coefficient, the horizontal ramp only the horizontal frequencies and the checkerboard
+// mostly the highest one, so a wrong scale factor, basis or frequency layout shows up in at least one of them.
+var dctTestVectors = []dctTestVector{
+	{
+		name: "impulse",
+		block: []float64{
+			255, 0, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+		},
+		coefs: []float64{
+			63.75, 83.29338901087, 63.75, 34.50125138432,
+			83.29338901087, 108.8280573006, 83.29338901087, 45.07805730064,
+			63.75, 83.29338901087, 63.75, 34.50125138432,
+			34.50125138432, 45.07805730064, 34.50125138432, 18.67194269936,
+		},
+	},
+	{
+		name: "constant",
+		block: []float64{
+			128, 128, 128, 128,
+			128, 128, 128, 128,
+			128, 128, 128, 128,
+			128, 128, 128, 128,
+		},
+		coefs: []float64{
+			512, 0, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+		},
+	},
+	{
+		name: "ramp",
+		block: []float64{
+			0, 85, 170, 255,
+			0, 85, 170, 255,
+			0, 85, 170, 255,
+			0, 85, 170, 255,
+		},
+		coefs: []float64{
+			510, -379.1752245559, 0, -26.94715352279,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+			0, 0, 0, 0,
+		},
+	},
+	{
+		name: "checkerboard",
+		block: []float64{
+			255, 0, 255, 0,
+			0, 255, 0, 255,
+			255, 0, 255, 0,
+			0, 255, 0, 255,
+		},
+		coefs: []float64{
+			510, 0, 0, 0,
+			0, 74.68777079743, 0, 180.3122292026,
+			0, 0, 0, 0,
+			0, 180.3122292026, 0, 435.3122292026,
+		},
+	},
+}
+
+// dctTransform is a DCT implementation checked by the self-check.
+type dctTransform struct {
+	name string
+	dct  func(block []float64, n int) []float64
+}
+
+// dctTransforms are the DCT implementations of the detection.
+var dctTransforms = []dctTransform{
+	{"direct", directDCT},
+	{"fft", fftDCT},
+	{"separable", func(block []float64, n int) []float64 {
+		// The buffers are dirty, like the ones reused across the blocks, so the accumulators must be reset.
+		coefs, tmp := make([]float64, n*n), make([]float64, n*n)
+		for i := range coefs {
+			coefs[i], tmp[i] = math.MaxFloat32, -math.MaxFloat32
+		}
+		separableDCT(block, coefs, tmp, n)
+		return coefs
+	}},
+}
+
+// DCTCheck is the outcome of a DCT implementation on a test pattern of the self-check.
+type DCTCheck struct {
+	Pattern   string `json:"pattern"`
+	Transform string `json:"transform"`
+	// MaxError is the largest difference between the computed and the expected coefficients.
+	MaxError float64 `json:"max_error"`
+	Passed   bool    `json:"passed"`
+}
+
+// selfCheckDCT runs each of the DCT implementations on every test pattern and compares the coefficients
+// with the expected ones. The patterns are transformed twice, so the state cached by the first transform,
+// like the DCT basis, is checked as well.
+func selfCheckDCT(transforms []dctTransform) []DCTCheck {
+	var checks []DCTCheck
+	for _, vector := range dctTestVectors {
+		for _, transform := range transforms {
+			check := DCTCheck{Pattern: vector.name, Transform: transform.name}
+			for run := 0; run < 2; run++ {
+				coefs := transform.dct(vector.block, 4)
+				for i, want := range vector.coefs {
+					d := math.Abs(coefs[i] - want)
+					// A NaN is never within the tolerance, and it can't be encoded as JSON.
+					if math.IsNaN(d) {
+						d = math.MaxFloat64
+					}
+					check.MaxError = math.Max(check.MaxError, d)
+				}
+			}
+			check.Passed = check.MaxError <= selfCheckTolerance
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
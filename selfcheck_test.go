@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSelfCheckDCT(t *testing.T) {
+	checks := selfCheckDCT(dctTransforms)
+	if len(checks) != len(dctTestVectors)*len(dctTransforms) {
+		t.Fatalf("%d checks, expected %d", len(checks), len(dctTestVectors)*len(dctTransforms))
+	}
+	for _, check := range checks {
+		if !check.Passed {
+			t.Errorf("%s %s: max error %g", check.Pattern, check.Transform, check.MaxError)
+		}
+	}
+}
+
+// TestSelfCheckVectors checks the test vectors themselves: the orthonormal DCT preserves the energy of the block.
+func TestSelfCheckVectors(t *testing.T) {
+	for _, vector := range dctTestVectors {
+		var blockEnergy, coefEnergy float64
+		for i := range vector.block {
+			blockEnergy += vector.block[i] * vector.block[i]
+			coefEnergy += vector.coefs[i] * vector.coefs[i]
+		}
+		if math.Abs(blockEnergy-coefEnergy) > 1e-6*blockEnergy {
+			t.Errorf("%s: the coefficients have the energy %v, the block %v", vector.name, coefEnergy, blockEnergy)
+		}
+	}
+}
+
+func TestSelfCheckCatchesBugs(t *testing.T) {
+	for _, transform := range []dctTransform{
+		// The DC coefficient scaled like the AC coefficients.
+		{"normalization", func(block []float64, n int) []float64 {
+			coefs := directDCT(block, n)
+			coefs[0] *= math.Sqrt2
+			return coefs
+		}},
+		// The coefficients accumulated across the blocks.
+		{"accumulator", func() func([]float64, int) []float64 {
+			var acc []float64
+			return func(block []float64, n int) []float64 {
+				if acc == nil {
+					acc = make([]float64, n*n)
+				}
+				for i, c := range directDCT(block, n) {
+					acc[i] += c
+				}
+				return append([]float64(nil), acc...)
+			}
+		}()},
+		// The horizontal and vertical frequencies swapped.
+		{"layout", func(block []float64, n int) []float64 {
+			coefs, swapped := directDCT(block, n), make([]float64, n*n)
+			for v := 0; v < n; v++ {
+				for u := 0; u < n; u++ {
+					swapped[u*n+v] = coefs[v*n+u]
+				}
+			}
+			return swapped
+		}},
+		{"nan", func(block []float64, n int) []float64 {
+			coefs := directDCT(block, n)
+			coefs[1] = math.NaN()
+			return coefs
+		}},
+	} {
+		var failed bool
+		for _, check := range selfCheckDCT([]dctTransform{transform}) {
+			failed = failed || !check.Passed
+		}
+		if !failed {
+			t.Errorf("the %s bug passed the self-check", transform.name)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "math"
+
+// applySensitivity maps the sensitivity, between 0 and 1, onto the counting thresholds. The higher
+// sensitivity lowers the number of matches displaced by the same shift required for the suspicious
+// blocks (OffsetThreshold) and the number of forged blocks required for a forged verdict (MinForgedBlocks).
+// Both are halved for each increase of 0.5, and the sensitivity of 0.5 gives the default thresholds:
+//
+//	sensitivity      0     0.25  0.5  0.75  1
+//	OffsetThreshold  144   102   72   51    36
+//	MinForgedBlocks  4     3     2    1     1
+//
+// The sensitivity is clamped to the 0-1 range.
+func applySensitivity(c *Config, sensitivity float64) {
+	sensitivity = math.Max(0, math.Min(sensitivity, 1))
+	factor := math.Pow(2, 1-2*sensitivity)
+	c.OffsetThreshold = int(math.Round(float64(DefaultConfig.OffsetThreshold) * factor))
+	c.MinForgedBlocks = int(math.Max(1, math.Round(float64(DefaultConfig.MinForgedBlocks)*factor)))
+}
+
+// WithSensitivity tunes the counting thresholds with a single sensitivity value between 0 and 1.
+// See applySensitivity for the mapping.
+func WithSensitivity(sensitivity float64) Option {
+	return func(c *Config) { applySensitivity(c, sensitivity) }
+}
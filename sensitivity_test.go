@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestApplySensitivity(t *testing.T) {
+	tests := []struct {
+		sensitivity float64
+		offset      int
+		forged      int
+	}{
+		{-1, 144, 4},
+		{0, 144, 4},
+		{0.25, 102, 3},
+		{0.5, 72, 2},
+		{0.75, 51, 1},
+		{1, 36, 1},
+		{2, 36, 1},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig
+		applySensitivity(&cfg, tt.sensitivity)
+		if cfg.OffsetThreshold != tt.offset || cfg.MinForgedBlocks != tt.forged {
+			t.Errorf("sensitivity %v: got the thresholds %d and %d, expected %d and %d",
+				tt.sensitivity, cfg.OffsetThreshold, cfg.MinForgedBlocks, tt.offset, tt.forged)
+		}
+	}
+}
+
+func TestSensitivityRegionsMonotonic(t *testing.T) {
+	img, _ := authenticImage(t, 256, 256)
+	// The copies of different sizes are supported by different numbers of matches,
+	// so the more sensitive detection flags the smaller copies too.
+	for i, size := range []int{16, 20, 24, 28} {
+		copyPatch(img, image.Pt(10+50*i, 10), image.Pt(10+50*i, 150+5*i), size, func(v uint8) uint8 { return v })
+	}
+
+	var counts []int
+	for _, s := range []float64{0, 0.25, 0.5, 0.75, 1} {
+		d, err := NewDetector(WithSensitivity(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := d.Detect(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, len(res.Regions))
+	}
+	for i := 1; i < len(counts); i++ {
+		if counts[i] < counts[i-1] {
+			t.Errorf("the number of the flagged regions decreases with the sensitivity: %v", counts)
+			break
+		}
+	}
+	if counts[0] >= counts[len(counts)-1] {
+		t.Errorf("the sensitivity doesn't change the number of the flagged regions: %v", counts)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// sobelBins is the number of the gradient orientation histogram bins.
+const sobelBins = 8
+
+// gradient contains the Sobel gradient magnitude and orientation of every pixel of an image.
+type gradient struct {
+	width     int
+	magnitude []float64
+	// orientation is the unsigned gradient orientation in the [0, π) range.
+	orientation []float64
+}
+
+// sobel computes the gradient of the luminance channel of a YUV image, stored in the red component.
+func sobel(img *image.RGBA) *gradient {
+	bounds := img.Bounds()
+	dx, dy := bounds.Dx(), bounds.Dy()
+	grad := &gradient{
+		width:       dx,
+		magnitude:   make([]float64, dx*dy),
+		orientation: make([]float64, dx*dy),
+	}
+
+	lum := func(x, y int) float64 {
+		x = clampInt(x, 0, dx-1)
+		y = clampInt(y, 0, dy-1)
+		return float64(img.Pix[img.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)])
+	}
+
+	for y := 0; y < dy; y++ {
+		for x := 0; x < dx; x++ {
+			gx := lum(x+1, y-1) + 2*lum(x+1, y) + lum(x+1, y+1) -
+				lum(x-1, y-1) - 2*lum(x-1, y) - lum(x-1, y+1)
+			gy := lum(x-1, y+1) + 2*lum(x, y+1) + lum(x+1, y+1) -
+				lum(x-1, y-1) - 2*lum(x, y-1) - lum(x+1, y-1)
+
+			angle := math.Atan2(gy, gx)
+			if angle < 0 {
+				angle += math.Pi
+			}
+			if angle >= math.Pi {
+				angle -= math.Pi
+			}
+			grad.magnitude[y*dx+x] = math.Hypot(gx, gy)
+			grad.orientation[y*dx+x] = angle
+		}
+	}
+	return grad
+}
+
+// sobelFeatures returns the gradient orientation histogram of the block having its top left corner at bx, by.
+// Each bin accumulates the mean gradient magnitude of the pixels oriented in its range.
+func sobelFeatures(grad *gradient, bx, by int, blockSize int) []feature {
+	var hist [sobelBins]float64
+	for y := by; y < by+blockSize; y++ {
+		for x := bx; x < bx+blockSize; x++ {
+			i := y*grad.width + x
+			bin := int(grad.orientation[i] / math.Pi * sobelBins)
+			if bin >= sobelBins {
+				bin = sobelBins - 1
+			}
+			hist[bin] += grad.magnitude[i]
+		}
+	}
+
+	features := make([]feature, 0, sobelBins)
+	for _, h := range hist {
+		features = append(features, feature{x: bx, y: by, coef: h / float64(blockSize*blockSize)})
+	}
+	return features
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+func TestSobelFeatures(t *testing.T) {
+	const size = 8
+	// The luminance is stored in the red component of the YUV image.
+	edge := func(vertical bool) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				var v uint8 = 40
+				if (vertical && x >= size/2) || (!vertical && y >= size/2) {
+					v = 200
+				}
+				img.SetRGBA(x, y, color.RGBA{v, 128, 128, 255})
+			}
+		}
+		return img
+	}
+
+	flat := sobelFeatures(sobel(image.NewRGBA(image.Rect(0, 0, size, size))), 0, 0, size)
+	for i, f := range flat {
+		if f.coef != 0 {
+			t.Errorf("the bin %d of the flat block is %v, expected 0", i, f.coef)
+		}
+	}
+	// The gradient of a vertical edge is horizontal, and the gradient of a horizontal edge is vertical.
+	for _, tc := range []struct {
+		vertical bool
+		bin      int
+	}{
+		{true, 0},
+		{false, sobelBins / 2},
+	} {
+		feats := sobelFeatures(sobel(edge(tc.vertical)), 0, 0, size)
+		if len(feats) != sobelBins {
+			t.Fatalf("%d features, expected %d", len(feats), sobelBins)
+		}
+		for i, f := range feats {
+			if (i == tc.bin) != (f.coef > 0) {
+				t.Errorf("vertical edge %v: the bin %d is %v, expected only the bin %d to be set", tc.vertical, i, f.coef, tc.bin)
+			}
+		}
+	}
+}
+
+// edgeImage returns an image of overlapping flat rectangles, whose content are mostly sharp edges,
+// with the region src copied to dst.
+func edgeImage(size int, seed int64) (img *image.NRGBA, src, dst image.Rectangle) {
+	rnd := rand.New(rand.NewSource(seed))
+	img = image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	for i := 0; i < size; i++ {
+		x, y := rnd.Intn(size), rnd.Intn(size)
+		r := image.Rect(x, y, x+2+rnd.Intn(size/8), y+2+rnd.Intn(size/8))
+		c := color.NRGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255}
+		draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	src = image.Rect(size/8, size/8, size/8+size/4, size/8+size/4)
+	dst = src.Add(image.Pt(size/2, size/2))
+	draw.Draw(img, dst, img, src.Min, draw.Src)
+	return img, src, dst
+}
+
+func TestDetectEdgeImageSobel(t *testing.T) {
+	img, src, dst := edgeImage(256, 1)
+	cfg := DefaultConfig
+	cfg.Features = FeatureSobel
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged {
+		t.Fatalf("the copy of the edge image is not detected")
+	}
+	var found bool
+	for _, r := range res.Regions {
+		found = found || r.Overlaps(src)
+	}
+	if !found {
+		t.Errorf("no region of the %v copied region has been detected", src)
+	}
+	shift := dst.Min.Sub(src.Min)
+	if len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Errorf("expected the dominant shift %v, got %+v", shift, res.Shifts)
+	}
+}
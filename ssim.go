@@ -0,0 +1,72 @@
+package main
+
+import (
+	"image"
+)
+
+// SSIM stabilization constants for 8 bit values.
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// ssim computes the structural similarity index between the luminance of two
+// square patches of the provided size, having their top left corner at a and b. The patch b is mirrored by the flip.
+// Identical patches have an index of 1.
+func ssim(img *image.NRGBA, a, b image.Point, size int, flip Flip) float64 {
+	pa := lumaValues(img, a, size)
+	pb := flipValues(lumaValues(img, b, size), size, 1, flip)
+	n := float64(len(pa))
+
+	var meanA, meanB float64
+	for i := range pa {
+		meanA += pa[i]
+		meanB += pb[i]
+	}
+	meanA /= n
+	meanB /= n
+
+	var varA, varB, cov float64
+	for i := range pa {
+		da, db := pa[i]-meanA, pb[i]-meanB
+		varA += da * da
+		varB += db * db
+		cov += da * db
+	}
+	varA /= n
+	varB /= n
+	cov /= n
+
+	return ((2*meanA*meanB + ssimC1) * (2*cov + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varA + varB + ssimC2))
+}
+
+// lumaValues returns the luminance values of the square patch having its top left corner at p.
+func lumaValues(img *image.NRGBA, p image.Point, size int) []float64 {
+	values := make([]float64, 0, size*size)
+	for y := p.Y; y < p.Y+size; y++ {
+		i := img.PixOffset(p.X, y)
+		for x := 0; x < size; x++ {
+			values = append(values, 0.299*float64(img.Pix[i])+0.587*float64(img.Pix[i+1])+0.114*float64(img.Pix[i+2]))
+			i += 4
+		}
+	}
+	return values
+}
+
+// verifySSIM keeps only the candidate matches whose luminance patches have a structural
+// similarity at least equal with the threshold. It also returns the mean SSIM of the confirmed matches.
+func verifySSIM(img *image.NRGBA, vectors []vector, cfg Config) ([]vector, float64) {
+	var verified []vector
+	var sum float64
+	for _, v := range vectors {
+		if s := ssim(img, image.Pt(v.xa, v.ya), image.Pt(v.xb, v.yb), cfg.BlockSize, v.flip); s >= cfg.SSIMThreshold {
+			verified = append(verified, v)
+			sum += s
+		}
+	}
+	if len(verified) == 0 {
+		return verified, 0
+	}
+	return verified, sum / float64(len(verified))
+}
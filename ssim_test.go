@@ -0,0 +1,81 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestVerifySSIMBlurredCopy(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The copied region is slightly blurred after being pasted.
+	blurred := imgToNRGBA(img)
+	draw.Draw(blurred, dst, StackBlur(imgToNRGBA(img), 1), dst.Min, draw.Src)
+
+	const size = 16
+	// The copied blocks, and the pairs of the copied blocks with other blocks of the pasted region, which are not a copy.
+	var copies, unrelated []vector
+	for y := 0; y+size <= src.Dy(); y += size {
+		for x := 0; x+size <= src.Dx(); x += size {
+			a := src.Min.Add(image.Pt(x, y))
+			copies = append(copies, vector{xa: a.X, ya: a.Y, xb: dst.Min.X + x, yb: dst.Min.Y + y})
+			unrelated = append(unrelated, vector{xa: a.X, ya: a.Y, xb: dst.Min.X + (x+src.Dx()/2)%src.Dx(), yb: dst.Min.Y + y})
+		}
+	}
+
+	var sumNCC, sumSSIM float64
+	for _, v := range copies {
+		sumNCC += ncc(blurred, image.Pt(v.xa, v.ya), image.Pt(v.xb, v.yb), size, NoFlip)
+		sumSSIM += ssim(blurred, image.Pt(v.xa, v.ya), image.Pt(v.xb, v.yb), size, NoFlip)
+	}
+	// The NCC ignores the contrast lost by the blur, which the SSIM accounts for.
+	if sumSSIM >= sumNCC {
+		t.Errorf("the mean SSIM %.3f of the blurred copy should be lower than its mean NCC %.3f",
+			sumSSIM/float64(len(copies)), sumNCC/float64(len(copies)))
+	}
+
+	cfg := DefaultConfig
+	cfg.BlockSize, cfg.NCCThreshold, cfg.SSIMThreshold = size, 0.9, 0.9
+	byNCC := verifyNCC(blurred, copies, cfg)
+	bySSIM, mean := verifySSIM(blurred, copies, cfg)
+	if len(byNCC) < len(copies)*9/10 || len(bySSIM) < len(copies)*9/10 {
+		t.Errorf("the NCC accepted %d and the SSIM %d of the %d blurred blocks", len(byNCC), len(bySSIM), len(copies))
+	}
+	if len(bySSIM) > len(byNCC) {
+		t.Errorf("the SSIM accepted %d blurred blocks, more than the %d of the NCC", len(bySSIM), len(byNCC))
+	}
+	if mean < cfg.SSIMThreshold || mean > 1 {
+		t.Errorf("the mean SSIM of the confirmed matches is %.3f", mean)
+	}
+
+	if got := verifyNCC(blurred, unrelated, cfg); len(got) > len(unrelated)/10 {
+		t.Errorf("the NCC accepted %d of the %d unrelated blocks", len(got), len(unrelated))
+	}
+	if got, _ := verifySSIM(blurred, unrelated, cfg); len(got) > len(unrelated)/10 {
+		t.Errorf("the SSIM accepted %d of the %d unrelated blocks", len(got), len(unrelated))
+	}
+}
+
+func TestDetectMeanSSIM(t *testing.T) {
+	img := randomNRGBA(256, 256, 1)
+	copyPatch(img, image.Pt(16, 16), image.Pt(176, 160), 48, func(v uint8) uint8 { return v })
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The mean SSIM is reported only by the verification.
+	if !res.Forged || res.MeanSSIM != 0 {
+		t.Fatalf("the unverified copy is reported forged %v with the mean SSIM %.3f", res.Forged, res.MeanSSIM)
+	}
+	cfg := DefaultConfig
+	cfg.SSIMThreshold = 0.9
+	if res, err = Detect(img, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || res.MeanSSIM < cfg.SSIMThreshold || res.MeanSSIM > 1 {
+		t.Errorf("the exact copy is reported with the mean SSIM %.3f", res.MeanSSIM)
+	}
+}
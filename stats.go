@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// The pipeline stages reported by the statistics, in their processing order.
+const (
+	statsYUV      = "yuv"
+	statsBlocks   = "blocks"
+	statsFeatures = "features"
+	statsSort     = "sort"
+	statsMatch    = "match"
+	statsFilter   = "filter"
+)
+
+// StageStats is the wall time spent in a stage of the detection pipeline, and the number of items it produced.
+type StageStats struct {
+	Stage string `json:"stage"`
+	// Seconds is the wall time of the stage, accumulated over the tiles.
+	Seconds float64 `json:"seconds"`
+	// Items is the number of the converted pixels, extracted blocks, computed features,
+	// sorted features, matched shift vectors or forged block pairs, depending on the stage.
+	Items int `json:"items"`
+}
+
+// pipelineStats collects the statistics of the pipeline stages. The nil collector ignores the measurements,
+// so the stages are measured unconditionally, without checking whether the statistics are enabled.
+type pipelineStats struct {
+	stages []StageStats
+}
+
+// newPipelineStats returns a statistics collector with an entry for each stage, or nil when disabled.
+func newPipelineStats(enabled bool) *pipelineStats {
+	if !enabled {
+		return nil
+	}
+	s := &pipelineStats{}
+	for _, stage := range []string{statsYUV, statsBlocks, statsFeatures, statsSort, statsMatch, statsFilter} {
+		s.stages = append(s.stages, StageStats{Stage: stage})
+	}
+	return s
+}
+
+// add accumulates the time elapsed since start and the number of items into the stage.
+func (s *pipelineStats) add(stage string, start time.Time, items int) {
+	if s == nil {
+		return
+	}
+	elapsed := time.Since(start).Seconds()
+	for i := range s.stages {
+		if s.stages[i].Stage == stage {
+			s.stages[i].Seconds += elapsed
+			s.stages[i].Items += items
+			return
+		}
+	}
+}
+
+// result returns the collected statistics, or nil when disabled.
+func (s *pipelineStats) result() []StageStats {
+	if s == nil {
+		return nil
+	}
+	return s.stages
+}
+
+// printStats writes the statistics of the pipeline stages as a table.
+func printStats(w io.Writer, stats []StageStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Stage\tTime (s)\tItems\t")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%.4f\t%d\t\n", s.Stage, s.Seconds, s.Items)
+	}
+	tw.Flush()
+}
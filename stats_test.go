@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDetectStats(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Detect(img, DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats != nil {
+		t.Fatalf("expected no statistics unless collected, got %+v", res.Stats)
+	}
+
+	cfg := DefaultConfig
+	cfg.CollectStats = true
+	res, err = Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := []string{statsYUV, statsBlocks, statsFeatures, statsSort, statsMatch, statsFilter}
+	if len(res.Stats) != len(stages) {
+		t.Fatalf("expected %d stages, got %+v", len(stages), res.Stats)
+	}
+	for i, s := range res.Stats {
+		if s.Stage != stages[i] {
+			t.Errorf("stage %d: expected %q, got %q", i, stages[i], s.Stage)
+		}
+		if s.Seconds < 0 || s.Items < 0 {
+			t.Errorf("%s: expected non-negative measurements, got %v s and %d items", s.Stage, s.Seconds, s.Items)
+		}
+	}
+	if got := res.Stats[0].Items; got != 128*128 {
+		t.Errorf("expected %d converted pixels, got %d", 128*128, got)
+	}
+	if res.Stats[2].Items == 0 || res.Stats[4].Items == 0 {
+		t.Errorf("expected the features and the matches of the synthetic copy to be counted, got %+v", res.Stats)
+	}
+
+	var buf bytes.Buffer
+	printStats(&buf, res.Stats)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(stages)+1 || !strings.Contains(lines[0], "Stage") {
+		t.Fatalf("expected a header and a row per stage, got:\n%s", buf.String())
+	}
+	for i, stage := range stages {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i+1]), stage) {
+			t.Errorf("row %d: expected the %q stage, got %q", i, stage, lines[i+1])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+)
+
+// minSyntheticSize is the smallest width and height of a synthetic image, which leaves room for
+// a copied region of a few blocks.
+const minSyntheticSize = 32
+
+// SyntheticImage generates a reproducible test image of the provided size, holding a single known copy-move
+// forgery: a quarter of the width and the height of a seeded noise texture is copied to the opposite quarter of
+// the image. The same seed and size always produce the same image on every machine, so the tests and the benchmarks
+// need no fixture files, and the -benchmark-image flag writes it as a reproducible input.
+// It returns the image, the copied region and the region it has been pasted to.
+func SyntheticImage(width, height int, seed int64) (*image.NRGBA, image.Rectangle, image.Rectangle, error) {
+	if width < minSyntheticSize || height < minSyntheticSize {
+		return nil, image.Rectangle{}, image.Rectangle{}, fmt.Errorf("the synthetic image must be at least %dx%d", minSyntheticSize, minSyntheticSize)
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	// The texture combines the coarse and the fine value noise with the pixel noise, so its blocks are distinct
+	// like the blocks of a natural photo, instead of the flat or the periodic blocks matching each other.
+	var layers [3][]noiseLayer
+	for c := range layers {
+		layers[c] = []noiseLayer{
+			newNoiseLayer(rnd, width, height, 32, 120),
+			newNoiseLayer(rnd, width, height, 8, 60),
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var px [3]uint8
+			for c := range layers {
+				v := 40 + rnd.Float64()*20
+				for _, l := range layers[c] {
+					v += l.at(x, y)
+				}
+				px[c] = clamp255(v)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{px[0], px[1], px[2], 255})
+		}
+	}
+
+	src := image.Rect(width/8, height/8, width/8+width/4, height/8+height/4)
+	dst := src.Add(image.Pt(width/2, height/2))
+	for y := src.Min.Y; y < src.Max.Y; y++ {
+		for x := src.Min.X; x < src.Max.X; x++ {
+			img.SetNRGBA(x+width/2, y+height/2, img.NRGBAAt(x, y))
+		}
+	}
+	return img, src, dst, nil
+}
+
+// noiseLayer is a grid of random values, bilinearly interpolated between the grid points.
+type noiseLayer struct {
+	cell   int
+	cols   int
+	values []float64
+}
+
+// newNoiseLayer returns a noise layer covering the image with the grid cells of the provided size,
+// whose values are uniformly distributed between 0 and the amplitude.
+func newNoiseLayer(rnd *rand.Rand, width, height, cell int, amplitude float64) noiseLayer {
+	cols, rows := width/cell+2, height/cell+2
+	l := noiseLayer{cell: cell, cols: cols, values: make([]float64, cols*rows)}
+	for i := range l.values {
+		l.values[i] = rnd.Float64() * amplitude
+	}
+	return l
+}
+
+// at returns the interpolated noise value at the pixel.
+func (l noiseLayer) at(x, y int) float64 {
+	gx, gy := x/l.cell, y/l.cell
+	fx, fy := float64(x%l.cell)/float64(l.cell), float64(y%l.cell)/float64(l.cell)
+	i := gy*l.cols + gx
+	top := l.values[i]*(1-fx) + l.values[i+1]*fx
+	bottom := l.values[i+l.cols]*(1-fx) + l.values[i+l.cols+1]*fx
+	return top*(1-fy) + bottom*fy
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSyntheticImageIsDeterministic(t *testing.T) {
+	img, src, dst, err := SyntheticImage(96, 64, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, againSrc, againDst, err := SyntheticImage(96, 64, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePixels(img, again) || src != againSrc || dst != againDst {
+		t.Error("the same seed and size generated different images")
+	}
+	if other, _, _, _ := SyntheticImage(96, 64, 8); samePixels(img, other) {
+		t.Error("another seed generated the same image")
+	}
+
+	// The known copy is pasted pixel for pixel.
+	if src.Size() != dst.Size() || !samePixels(cropImage(img, src), cropImage(img, dst)) {
+		t.Errorf("the region %v has not been copied to %v", src, dst)
+	}
+	if _, _, _, err := SyntheticImage(minSyntheticSize-1, 64, 7); err == nil {
+		t.Error("expected the image too small for a copy to be rejected")
+	}
+}
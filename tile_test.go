@@ -0,0 +1,237 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+func TestTilesCoverOverlap(t *testing.T) {
+	const size, overlap, blockSize = 96, 24, 4
+	bounds := image.Rect(0, 0, 300, 200)
+	tiles := splitTiles(bounds, size, overlap, blockSize)
+	if len(tiles) != 12 {
+		t.Fatalf("got %d tiles, expected 12", len(tiles))
+	}
+	for _, tile := range tiles {
+		if !tile.In(bounds) || tile.Dx() > size || tile.Dy() > size {
+			t.Fatalf("the tile %v exceeds the tile size or the %v bounds", tile, bounds)
+		}
+	}
+
+	// Every copy straddling a tile boundary, whose shift is at most the overlap minus the block size,
+	// has its source and destination blocks in a common tile.
+	rnd := rand.New(rand.NewSource(1))
+	const maxShift = overlap - blockSize
+	for i := 0; i < 10000; i++ {
+		a := image.Pt(rnd.Intn(bounds.Dx()-blockSize+1), rnd.Intn(bounds.Dy()-blockSize+1))
+		b := a.Add(image.Pt(rnd.Intn(2*maxShift+1)-maxShift, rnd.Intn(2*maxShift+1)-maxShift))
+		if !b.In(image.Rect(0, 0, bounds.Dx()-blockSize+1, bounds.Dy()-blockSize+1)) {
+			continue
+		}
+		pair := image.Rectangle{a, a.Add(image.Pt(blockSize, blockSize))}.Union(image.Rectangle{b, b.Add(image.Pt(blockSize, blockSize))})
+		var covered bool
+		for _, tile := range tiles {
+			if pair.In(tile) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Fatalf("no tile holds both blocks of the pair at %v and %v", a, b)
+		}
+	}
+
+	if got := splitTiles(bounds, 0, overlap, blockSize); len(got) != 1 || got[0] != bounds {
+		t.Errorf("expected a single tile without tiling, got %v", got)
+	}
+}
+
+func TestTiledDetectionAcrossSeams(t *testing.T) {
+	// A noise image with a copy straddling the seams at x=96 and y=96 of the 96 pixel tiles.
+	rnd := rand.New(rand.NewSource(2))
+	img := image.NewRGBA(image.Rect(0, 0, 160, 160))
+	rnd.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	src := image.Rect(40, 40, 64, 64)
+	dst := src.Add(image.Pt(44, 44))
+	draw.Draw(img, dst, img, src.Min, draw.Src)
+
+	cfg := DefaultConfig
+	cfg.BlurRadius = 0
+	cfg.TileSize, cfg.TileOverlap = 96, 64
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != 44 || res.Shifts[0].Y != 44 {
+		t.Fatalf("the copy straddling the tile seams is not detected, shifts %+v", res.Shifts)
+	}
+	// The source blocks copied past the seams are matched in the tiles overlapping the first one.
+	shift := dst.Min.Sub(src.Min)
+	past := dst.Intersect(image.Rect(96, 96, 160, 160)).Sub(shift)
+	var found bool
+	for _, r := range res.Regions {
+		found = found || r.Min.In(past)
+	}
+	if !found {
+		t.Errorf("no region of the blocks %v copied past the seams has been detected: %v", past, res.Regions)
+	}
+}
+
+func TestCountBlocks(t *testing.T) {
+	for _, tt := range []struct {
+		r    image.Rectangle
+		want int
+	}{
+		{image.Rect(0, 0, 4, 4), 1},
+		{image.Rect(10, 20, 16, 25), 6},
+		{image.Rect(0, 0, 3, 10), 0},
+	} {
+		if got := countBlocks(tt.r, 4, 1); got != tt.want {
+			t.Errorf("%v: got %d blocks, expected %d", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestTilesCoverMaxShift(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.TileSize, cfg.MaxShift = 96, 40
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	bounds := image.Rect(0, 0, 300, 200)
+	tiles := splitTiles(bounds, cfg.TileSize, cfg.tileOverlap(), cfg.BlockSize)
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		a := image.Pt(rnd.Intn(bounds.Dx()-cfg.BlockSize+1), rnd.Intn(bounds.Dy()-cfg.BlockSize+1))
+		shift := image.Pt(rnd.Intn(81)-40, rnd.Intn(81)-40)
+		b := a.Add(shift)
+		if !b.In(image.Rect(0, 0, bounds.Dx()-cfg.BlockSize+1, bounds.Dy()-cfg.BlockSize+1)) {
+			continue
+		}
+		pair := image.Rectangle{a, a.Add(image.Pt(cfg.BlockSize, cfg.BlockSize))}.Union(image.Rectangle{b, b.Add(image.Pt(cfg.BlockSize, cfg.BlockSize))})
+		var covered bool
+		for _, tile := range tiles {
+			if pair.In(tile) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Fatalf("no tile holds both blocks of the pair at %v and %v", a, b)
+		}
+	}
+}
+
+func TestTiledDetectionWithMaxShift(t *testing.T) {
+	img, src, dst, err := SyntheticImage(256, 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig
+	cfg.TileSize = 160
+	// Without the maximum shift no tile holds both the source and the destination of the copy.
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Forged {
+		t.Fatal("the copy farther than the tile overlap should not be matched")
+	}
+
+	cfg.TileSize, cfg.MaxShift = 224, 200
+	res, err = Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shift := dst.Min.Sub(src.Min)
+	if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != float64(shift.X) || res.Shifts[0].Y != float64(shift.Y) {
+		t.Fatalf("the copy within the maximum shift is not detected, shifts %+v", res.Shifts)
+	}
+
+	cfg.MaxShift = 230
+	if err := cfg.validate(); err == nil {
+		t.Fatal("the maximum shift plus the block size should not exceed the tile size")
+	}
+}
+
+func TestTiledMatchesStitched(t *testing.T) {
+	// A noise image with a copy straddling the seam at x=96 of the 96 pixel tiles.
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 160, 160))
+	rnd.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	src := image.Rect(40, 40, 64, 64)
+	dst := src.Add(image.Pt(36, 0))
+	draw.Draw(img, dst, img, src.Min, draw.Src)
+
+	cfg := DefaultConfig
+	cfg.BlurRadius = 0
+	cfg.TileSize, cfg.TileOverlap = 96, 64
+	cfg.CollectMatches = true
+	res, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(splitTiles(image.Rect(0, 0, 160, 160), cfg.TileSize, cfg.TileOverlap, cfg.BlockSize)) < 2 {
+		t.Fatal("expected the image to be split into several tiles")
+	}
+	if !res.Forged || len(res.Shifts) == 0 || res.Shifts[0].X != 36 || res.Shifts[0].Y != 0 {
+		t.Fatalf("the copy straddling the tile seam is not detected, shifts %+v", res.Shifts)
+	}
+
+	// The pairs in the overlap of several tiles are reported once.
+	seen := make(map[Match]bool)
+	var copied int
+	for _, m := range res.Matches {
+		if seen[m] {
+			t.Fatalf("the pair %+v is reported several times", m)
+		}
+		seen[m] = true
+		if m.OffsetX == 36 && m.OffsetY == 0 {
+			copied++
+		}
+	}
+	if copied != res.Shifts[0].Count {
+		t.Errorf("the shift is counted %d times for %d pairs", res.Shifts[0].Count, copied)
+	}
+}
+
+func TestTiledShiftCount(t *testing.T) {
+	// A noise patch over a flat background, copied across the seam at x=96 of the 96 pixel tiles. The flat blocks
+	// are skipped, and the copy is counted in the tiles as often as in the whole image.
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 160, 160))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{128, 128, 128, 255}), image.Point{}, draw.Src)
+	src := image.Rect(40, 40, 64, 64)
+	for y := src.Min.Y; y < src.Max.Y; y++ {
+		for x := src.Min.X; x < src.Max.X; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255})
+		}
+	}
+	draw.Draw(img, src.Add(image.Pt(36, 0)), img, src.Min, draw.Src)
+
+	cfg := DefaultConfig
+	cfg.BlurRadius = 0
+	cfg.MinTextureEnergy = 1
+	untiled, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TileSize, cfg.TileOverlap = 96, 64
+	tiled, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(untiled.Shifts) == 0 || len(tiled.Shifts) == 0 || untiled.Shifts[0] != tiled.Shifts[0] {
+		t.Errorf("the untiled detection counts the shifts %+v, the tiled one %+v", untiled.Shifts, tiled.Shifts)
+	}
+}
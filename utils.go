@@ -2,8 +2,12 @@ package main
 
 import (
 	"math"
+	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
+	"io"
+	"os"
 )
 
 // round rounds float number to it's nearest integer part.
@@ -26,6 +30,17 @@ func clamp255(x float64) uint8 {
 	return uint8(x)
 }
 
+// clampInt restricts an integer value between the min and max bounds.
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
 // max returns the biggest value between two numbers.
 func max(x, y int) float64 {
 	if x > y {
@@ -65,6 +80,13 @@ func YUVtoRGB(y, u, v uint32) (uint32, uint32, uint32) {
 	return uint32(r), uint32(g), uint32(b)
 }
 
+// cloneNRGBA returns a copy of the image.
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(img.Bounds())
+	copy(dst.Pix, img.Pix)
+	return dst
+}
+
 // Converts any image type to *image.NRGBA with min-point at (0, 0).
 func imgToNRGBA(img image.Image) *image.NRGBA {
 	srcBounds := img.Bounds()
@@ -122,3 +144,109 @@ func imgToNRGBA(img image.Image) *image.NRGBA {
 	}
 	return dst
 }
+
+// loadImage opens and decodes the image file found under the provided path, which may also be an HTTP(S) URL.
+// It returns ErrUnsupportedFormat when the image format is not recognized and ErrCorruptImage when the decoder
+// breaks down on the malformed data.
+func loadImage(path string) (image.Image, error) {
+	if isURL(path) {
+		return fetchImage(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return decodeImage(file)
+}
+
+// decodeImage decodes the image data. It returns ErrUnsupportedFormat when the image format is not recognized,
+// and ErrCorruptImage when the decoder panics on the malformed data, so a single corrupt file doesn't bring down
+// the whole process.
+func decodeImage(r io.Reader) (img image.Image, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			img, err = nil, fmt.Errorf("%w: the decoder failed: %v", ErrCorruptImage, p)
+		}
+	}()
+	img, _, err = image.Decode(r)
+	if err == image.ErrFormat {
+		return nil, ErrUnsupportedFormat
+	}
+	return img, err
+}
+
+// downscale resizes the image with the provided resampling, so that its largest dimension is at most maxDim,
+// preserving the aspect ratio. It returns the resized image and the scale factor which maps
+// the coordinates of the resized image back to the original image space.
+func downscale(img image.Image, maxDim int, resampling Resampling) (image.Image, float64) {
+	dx, dy := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxDim <= 0 || (dx <= maxDim && dy <= maxDim) {
+		return img, 1
+	}
+	if dx >= dy {
+		res := resampling.resize(img, uint(maxDim), 0)
+		return res, float64(dx) / float64(res.Bounds().Dx())
+	}
+	res := resampling.resize(img, 0, uint(maxDim))
+	return res, float64(dy) / float64(res.Bounds().Dy())
+}
+
+// scaleRect scales the rectangle coordinates by the provided factor.
+func scaleRect(r image.Rectangle, scale float64) image.Rectangle {
+	return image.Rect(
+		int(round(float64(r.Min.X)*scale)),
+		int(round(float64(r.Min.Y)*scale)),
+		int(round(float64(r.Max.X)*scale)),
+		int(round(float64(r.Max.Y)*scale)),
+	)
+}
+
+// parseHexColor parses a color in the #rrggbb hexadecimal notation.
+func parseHexColor(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 255}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(s) != 7 {
+		return c, fmt.Errorf("invalid color %q, expected the #rrggbb format", s)
+	}
+	return c, nil
+}
+
+// cropImage returns the part of the image inside the rectangle.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// parseRect parses a rectangle in the x,y,w,h notation.
+func parseRect(s string) (image.Rectangle, error) {
+	var x, y, w, h int
+	if _, err := fmt.Sscanf(s, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid rectangle %q, expected the x,y,w,h format", s)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// parsePoint parses a point in the x,y notation.
+func parsePoint(s string) (image.Point, error) {
+	var p image.Point
+	if _, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y); err != nil {
+		return image.Point{}, fmt.Errorf("invalid point %q, expected the x,y format", s)
+	}
+	return p, nil
+}
+
+// parseSize parses an image size in the WxH notation.
+func parseSize(s string) (image.Point, error) {
+	var p image.Point
+	if _, err := fmt.Sscanf(s, "%dx%d", &p.X, &p.Y); err != nil || p.X <= 0 || p.Y <= 0 {
+		return image.Point{}, fmt.Errorf("invalid size %q, expected the WxH format", s)
+	}
+	return p, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDownscale(t *testing.T) {
+	img, src, dst, err := SyntheticImage(512, 384, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resized, scale := downscale(img, 256, Bilinear)
+	if got := resized.Bounds().Size(); got != image.Pt(256, 192) {
+		t.Fatalf("got the %v resized image, expected 256x192", got)
+	}
+	if scale != 2 {
+		t.Fatalf("got the scale %v, expected 2", scale)
+	}
+
+	// The copy survives the reduction: the reduced copied region still matches its reduced source.
+	reduced := imgToNRGBA(resized)
+	rsrc, shift := scaleRect(src, 1/scale), dst.Min.Sub(src.Min).Div(2)
+	var diff float64
+	for y := rsrc.Min.Y + 1; y < rsrc.Max.Y-1; y++ {
+		for x := rsrc.Min.X + 1; x < rsrc.Max.X-1; x++ {
+			a, b := reduced.NRGBAAt(x, y), reduced.NRGBAAt(x+shift.X, y+shift.Y)
+			diff += math.Abs(float64(a.R) - float64(b.R))
+		}
+	}
+	if diff /= float64((rsrc.Dx() - 2) * (rsrc.Dy() - 2)); diff > 1 {
+		t.Errorf("the reduced copy differs from its source by %.2f on average", diff)
+	}
+	// A region found at the reduced scale maps back to the original coordinates.
+	if got := scaleRect(rsrc, scale); got != src {
+		t.Errorf("the reduced %v region maps back to %v, expected %v", rsrc, got, src)
+	}
+
+	if same, scale := downscale(img, 0, Bilinear); same != image.Image(img) || scale != 1 {
+		t.Error("the image should not be resized without a maximum size")
+	}
+	if same, scale := downscale(img, 1024, Bilinear); same != image.Image(img) || scale != 1 {
+		t.Error("the image smaller than the maximum size should not be resized")
+	}
+}
+
+func TestParseRect(t *testing.T) {
+	r, err := parseRect("10,20,30,40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(10, 20, 40, 60); r != want {
+		t.Errorf("got the rectangle %v, expected %v", r, want)
+	}
+	for _, s := range []string{"", "10,20,30", "10,20,0,40", "10,20,30,-1", "a,b,c,d"} {
+		if _, err := parseRect(s); err == nil {
+			t.Errorf("no error for the invalid rectangle %q", s)
+		}
+	}
+}
+
+func TestDownscaleResampling(t *testing.T) {
+	// The columns alternate between black and white, so each pixel of the halved image covers a black
+	// and a white column.
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 1; x < 8; x += 2 {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	for _, c := range []struct {
+		resampling Resampling
+		want       uint8
+	}{
+		// The nearest pixel of the center of the resized pixel 1,1 is the white pixel 3,3.
+		{NearestNeighbor, 255},
+		// The interpolations blend the black and the white columns.
+		{Bilinear, 127},
+		{CatmullRom, 130},
+	} {
+		resized, scale := downscale(img, 4, c.resampling)
+		if resized.Bounds().Size() != image.Pt(4, 4) || scale != 2 {
+			t.Fatalf("%d: got the %v image scaled by %v, expected 4x4 scaled by 2", c.resampling, resized.Bounds().Size(), scale)
+		}
+		if got := color.GrayModel.Convert(resized.At(1, 1)).(color.Gray).Y; got != c.want {
+			t.Errorf("%d: the resized pixel is %d, expected %d", c.resampling, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes the build of the tool, so the reports can cite the exact version used.
+type BuildInfo struct {
+	// Version is the release version set at build time, or the module version (devel when neither is known).
+	Version string `json:"version"`
+	// GoVersion is the version of the Go toolchain the tool has been built with.
+	GoVersion string `json:"go_version"`
+	// Commit is the VCS revision the tool has been built from, when recorded.
+	Commit string `json:"commit,omitempty"`
+	// CommitTime is the time of the commit in RFC 3339 format, when recorded.
+	CommitTime string `json:"commit_time,omitempty"`
+	// Modified reports whether the working tree had uncommitted changes at build time.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// buildInfo returns the build information of the running binary.
+func buildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.CommitTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+}
+
+// String returns the build information on a single line, like "forensic 1.0.0 (go1.21.0, commit 1a2b3c4)".
+func (b BuildInfo) String() string {
+	s := fmt.Sprintf("forensic %s (%s", b.Version, b.GoVersion)
+	if b.Commit != "" {
+		s += ", commit " + b.Commit
+		if b.Modified {
+			s += ", modified"
+		}
+	}
+	return s + ")"
+}
+
+// printVersion writes the build information to w, JSON encoded when requested.
+func printVersion(w io.Writer, asJSON bool) error {
+	info := buildInfo()
+	if asJSON {
+		return json.NewEncoder(w).Encode(info)
+	}
+	_, err := fmt.Fprintln(w, info)
+	return err
+}
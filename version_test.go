@@ -0,0 +1,63 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+
+	Version = ""
+	if info := buildInfo(); info.Version == "" || info.GoVersion == "" {
+		t.Errorf("expected the version and the Go version to be known, got %+v", info)
+	}
+	Version = "1.2.3"
+	info := buildInfo()
+	if info.Version != "1.2.3" {
+		t.Errorf("expected the version set at build time, got %q", info.Version)
+	}
+	if s := info.String(); !strings.HasPrefix(s, "forensic 1.2.3 (") || !strings.Contains(s, info.GoVersion) {
+		t.Errorf("the version line %q doesn't hold the version and the Go version", s)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	for _, args := range []string{"version", "-version"} {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "FORENSIC_MAIN="+args)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: the tool failed: %v", args, err)
+		}
+		if line := strings.TrimSpace(string(out)); !strings.HasPrefix(line, "forensic ") || !strings.Contains(line, runtime.Version()) {
+			t.Errorf("%s: unexpected version output %q", args, line)
+		}
+	}
+
+	for _, args := range []string{"version -json", "-version -json"} {
+		cmd := exec.Command(os.Args[0])
+		cmd.Env = append(os.Environ(), "FORENSIC_MAIN="+args)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: the tool failed: %v", args, err)
+		}
+		var info BuildInfo
+		dec := json.NewDecoder(bytes.NewReader(out))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&info); err != nil {
+			t.Fatalf("%s: the output is not a JSON build info: %v\n%s", args, err, out)
+		}
+		if info.Version == "" || info.GoVersion != runtime.Version() {
+			t.Errorf("%s: unexpected build info %+v", args, info)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"syscall/js"
+)
+
+// detectJS is exposed to JavaScript as forensicDetect(bytes, config). It decodes the image bytes
+// provided as an Uint8Array, runs the detection and returns the JSON encoded result.
+// The optional config is a JSON string overriding the default detection settings.
+// On failure it returns a JSON object containing the error message.
+func detectJS(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return errorJSON("missing image bytes")
+	}
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+
+	cfg := DefaultConfig
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		if err := json.Unmarshal([]byte(args[1].String()), &cfg); err != nil {
+			return errorJSON(err.Error())
+		}
+	}
+
+	img, err := decodeImage(bytes.NewReader(data))
+	if err != nil {
+		return errorJSON(err.Error())
+	}
+
+	res, err := Detect(img, cfg)
+	if err != nil {
+		return errorJSON(err.Error())
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		return errorJSON(err.Error())
+	}
+	return string(out)
+}
+
+// errorJSON returns the JSON encoded error message.
+func errorJSON(msg string) string {
+	out, _ := json.Marshal(map[string]string{"error": msg})
+	return string(out)
+}
+
+func main() {
+	js.Global().Set("forensicDetect", js.FuncOf(detectJS))
+	// Keep the program running, so the exported function remains callable.
+	select {}
+}
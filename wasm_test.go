@@ -0,0 +1,35 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestWasmBuild builds the WebAssembly target, whose entrypoint is compiled only for the js/wasm platform,
+// so it would break unnoticed by the regular build.
+func TestWasmBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the WebAssembly build is slow")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+	dir, err := ioutil.TempDir("", "forensic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command(gobin, "build", "-o", filepath.Join(dir, "forensic.wasm"))
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the WebAssembly build failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// yuvChannels returns the pixels of the already converted YUV image, with the Y, U and V channels stored
+// in the red, green and blue channels. The YCbCr images provide their planes directly, and the grayscale
+// images are taken as the luminance with neutral chroma. The channels of the other images are assumed to
+// hold the Y, U and V components already.
+func yuvChannels(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	switch src := img.(type) {
+	case *image.YCbCr:
+		dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := src.YCbCrAt(x, y)
+				dst.SetNRGBA(x-b.Min.X, y-b.Min.Y, color.NRGBA{c.Y, c.Cb, c.Cr, 255})
+			}
+		}
+		return dst
+	case *image.Gray, *image.Gray16:
+		dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				g := color.GrayModel.Convert(src.At(x, y)).(color.Gray)
+				dst.SetNRGBA(x-b.Min.X, y-b.Min.Y, color.NRGBA{g.Y, 128, 128, 255})
+			}
+		}
+		return dst
+	}
+	return imgToNRGBA(img)
+}
+
+// checkYUVInput checks that the image can be taken as already converted to YUV. The YUV color space
+// has no alpha channel, so the transparent images are rejected.
+func checkYUVInput(img image.Image) error {
+	if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+		return fmt.Errorf("%w: the YUV input image cannot be transparent", ErrUnsupportedFormat)
+	}
+	return nil
+}
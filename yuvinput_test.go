@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestYUVChannels(t *testing.T) {
+	ycc := image.NewYCbCr(image.Rect(0, 0, 2, 1), image.YCbCrSubsampleRatio444)
+	copy(ycc.Y, []uint8{10, 200})
+	copy(ycc.Cb, []uint8{20, 100})
+	copy(ycc.Cr, []uint8{30, 150})
+	if got, want := yuvChannels(ycc).Pix, []uint8{10, 20, 30, 255, 200, 100, 150, 255}; !bytes.Equal(got, want) {
+		t.Errorf("the YCbCr planes are %v, expected %v", got, want)
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	copy(gray.Pix, []uint8{40, 250})
+	if got, want := yuvChannels(gray).Pix, []uint8{40, 128, 128, 255, 250, 128, 128, 255}; !bytes.Equal(got, want) {
+		t.Errorf("the grayscale image is %v, expected the luminance with neutral chroma %v", got, want)
+	}
+}
+
+func TestYUVInput(t *testing.T) {
+	img, _, _, err := SyntheticImage(128, 128, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := convertRGBImageToYUV(img)
+	cfg := DefaultConfig
+	cfg.BlurRadius = 0
+	yuvCfg := cfg
+	yuvCfg.InputYUV = true
+
+	// The pre-converted image is not converted again, so its features are those of the RGB image.
+	if !bytes.Equal(preprocess(yuvChannels(converted), yuvCfg).Pix, preprocess(imgToNRGBA(img), cfg).Pix) {
+		t.Error("the preprocessed YUV input differs from the converted RGB image")
+	}
+	want, err := Detect(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Detect(converted, yuvCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Forged || !reflect.DeepEqual(got.Regions, want.Regions) {
+		t.Errorf("expected the regions %v of the RGB image, got %v", want.Regions, got.Regions)
+	}
+
+	yuvCfg.Gamma = 2.2
+	if _, err := Detect(converted, yuvCfg); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected the gamma correction of a YUV input to be rejected, got %v", err)
+	}
+	yuvCfg.Gamma = 1
+	transparent := imgToNRGBA(converted)
+	transparent.SetNRGBA(0, 0, color.NRGBA{A: 0})
+	if _, err := Detect(transparent, yuvCfg); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected the transparent YUV input to be rejected, got %v", err)
+	}
+}
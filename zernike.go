@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image"
+	"math"
+)
+
+// defaultZernikeOrder is the default maximum order of the Zernike moments.
+const defaultZernikeOrder = 4
+
+// zernikeMoment is the n order and m repetition of a Zernike moment.
+type zernikeMoment struct {
+	n, m int
+}
+
+// zernikeMoments returns the moments up to the provided order, having a non-negative repetition.
+func zernikeMoments(order int) []zernikeMoment {
+	var moments []zernikeMoment
+	for n := 0; n <= order; n++ {
+		for m := n % 2; m <= n; m += 2 {
+			moments = append(moments, zernikeMoment{n, m})
+		}
+	}
+	return moments
+}
+
+// zernikeRadial computes the radial polynomial of the Zernike moment.
+func zernikeRadial(n, m int, r float64) float64 {
+	var sum float64
+	for s := 0; s <= (n-m)/2; s++ {
+		c := float64(factorial(n-s)) / float64(factorial(s)*factorial((n+m)/2-s)*factorial((n-m)/2-s))
+		if s%2 == 1 {
+			c = -c
+		}
+		sum += c * math.Pow(r, float64(n-2*s))
+	}
+	return sum
+}
+
+// factorial returns the factorial of a small non-negative number.
+func factorial(n int) int {
+	f := 1
+	for i := 2; i <= n; i++ {
+		f *= i
+	}
+	return f
+}
+
+// zernikeFeatures returns the Zernike moment magnitudes of the YUV block luminance, having its top left corner at bx, by.
+// The block is mapped over the unit disk, and since a rotation changes only the phase of the moments,
+// their magnitudes are rotation invariant.
+func zernikeFeatures(b *image.RGBA, bx, by int, blockSize, order int) []feature {
+	moments := zernikeMoments(order)
+	features := make([]feature, 0, len(moments))
+	min := b.Bounds().Min
+	size := float64(blockSize)
+
+	for _, zm := range moments {
+		var re, im float64
+		for y := 0; y < blockSize; y++ {
+			for x := 0; x < blockSize; x++ {
+				// Map the pixel centers into the unit disk.
+				xn := (2*float64(x) + 1 - size) / size
+				yn := (2*float64(y) + 1 - size) / size
+				r := math.Hypot(xn, yn)
+				if r > 1 {
+					continue
+				}
+				theta := math.Atan2(yn, xn)
+				l := float64(b.Pix[b.PixOffset(min.X+x, min.Y+y)])
+				rad := zernikeRadial(zm.n, zm.m, r)
+				re += l * rad * math.Cos(float64(zm.m)*theta)
+				im -= l * rad * math.Sin(float64(zm.m)*theta)
+			}
+		}
+		norm := float64(zm.n+1) / math.Pi * (4 / (size * size))
+		features = append(features, feature{x: bx, y: by, coef: norm * math.Hypot(re, im)})
+	}
+	return features
+}
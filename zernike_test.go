@@ -0,0 +1,72 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// renderBlock renders the luminance of the continuous pattern, rotated by the angle around the block center,
+// into a YUV block of the provided size, where the luminance is stored in the red component and the chroma is neutral.
+func renderBlock(size int, angle float64, pattern func(x, y float64) float64) *image.RGBA {
+	b := image.NewRGBA(image.Rect(0, 0, size, size))
+	c := float64(size-1) / 2
+	sin, cos := math.Sincos(angle)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-c, float64(y)-c
+			// The pixel of the rotated block holds the pattern at the inversely rotated position.
+			b.SetRGBA(x, y, color.RGBA{clamp255(pattern(cos*dx+sin*dy, -sin*dx+cos*dy)), 128, 128, 255})
+		}
+	}
+	return b
+}
+
+func TestZernikeRotationInvariance(t *testing.T) {
+	const size, order = 8, 4
+	// The quarter turns of a random block move the pixels exactly, so the magnitudes are identical.
+	rnd := rand.New(rand.NewSource(2))
+	a, b := image.NewRGBA(image.Rect(0, 0, size, size)), image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := uint8(rnd.Intn(256))
+			a.Pix[a.PixOffset(x, y)] = v
+			b.Pix[b.PixOffset(size-1-y, x)] = v
+		}
+	}
+	fa, fb := zernikeFeatures(a, 0, 0, size, order), zernikeFeatures(b, 0, 0, size, order)
+	if len(fa) != len(zernikeMoments(order)) {
+		t.Fatalf("%d features, expected %d", len(fa), len(zernikeMoments(order)))
+	}
+	for i := range fa {
+		if math.Abs(fa[i].coef-fb[i].coef) > 1e-9*math.Max(1, fa[i].coef) {
+			t.Errorf("the moment %v of the quarter turned block is %v, expected %v", zernikeMoments(order)[i], fb[i].coef, fa[i].coef)
+		}
+	}
+
+	// The arbitrary rotations resample the pixels, so the magnitudes of a smooth pattern are close.
+	pattern := func(x, y float64) float64 {
+		return 128 + 50*math.Cos(0.35*x+0.2*y) + 30*math.Sin(0.3*y-0.15*x*x/4)
+	}
+	const smooth = 16
+	ref := zernikeFeatures(renderBlock(smooth, 0, pattern), 0, 0, smooth, order)
+	other := zernikeFeatures(renderBlock(smooth, 0, func(x, y float64) float64 { return pattern(y+5, x-3) }), 0, 0, smooth, order)
+	var scale, otherDiff float64
+	for i := range ref {
+		scale = math.Max(scale, ref[i].coef)
+		otherDiff = math.Max(otherDiff, math.Abs(ref[i].coef-other[i].coef))
+	}
+	for _, deg := range []float64{30, 45, 110} {
+		rotated := zernikeFeatures(renderBlock(smooth, deg*math.Pi/180, pattern), 0, 0, smooth, order)
+		var diff float64
+		for i := range ref {
+			diff = math.Max(diff, math.Abs(ref[i].coef-rotated[i].coef))
+		}
+		if diff > 0.05*scale || diff > otherDiff/4 {
+			t.Errorf("the magnitudes of the block rotated by %v° differ by %.2f, the largest magnitude is %.2f and a different block differs by %.2f",
+				deg, diff, scale, otherDiff)
+		}
+	}
+}